@@ -0,0 +1,360 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/codegangsta/cli"
+	"github.com/docker/machine/drivers"
+	"golang.org/x/net/context"
+)
+
+// machineFilePathOptions are the options which name local files. Relative
+// paths in a machine file are relative to the directory of the file.
+var machineFilePathOptions = []string{
+	"user-data",
+	"provision-script",
+	"post-start-script",
+	"pre-remove-script",
+}
+
+// MachineFile lists machines to create with "machine create -f" or keep in
+// sync with "machine apply".
+type MachineFile struct {
+	Machines []MachineSpec `json:"machines"`
+
+	dir string
+}
+
+// MachineSpec describes a machine of a machine file. Options are the flags
+// of "machine create" without their leading dashes, e.g.
+// "amazonec2-instance-type".
+type MachineSpec struct {
+	Name    string                 `json:"name"`
+	Driver  string                 `json:"driver"`
+	Options map[string]interface{} `json:"options,omitempty"`
+	Labels  map[string]string      `json:"labels,omitempty"`
+	Engine  EngineSpec             `json:"engine"`
+}
+
+// EngineSpec holds the --engine-* options of a machine in a machine file.
+type EngineSpec struct {
+	Opt              []string `json:"opt,omitempty"`
+	Env              []string `json:"env,omitempty"`
+	InsecureRegistry []string `json:"insecure-registry,omitempty"`
+	Labels           []string `json:"label,omitempty"`
+	RegistryMirror   []string `json:"registry-mirror,omitempty"`
+	StorageDriver    string   `json:"storage-driver,omitempty"`
+}
+
+// LoadMachineFile reads a machine file and checks that every machine in it
+// can be created.
+func LoadMachineFile(path string) (*MachineFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file MachineFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("Error parsing machine file %s: %s", path, err)
+	}
+
+	if file.dir, err = filepath.Abs(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for _, spec := range file.Machines {
+		if _, err := ValidateHostName(spec.Name); err != nil {
+			return nil, fmt.Errorf("Invalid machine name %q in %s", spec.Name, path)
+		}
+		if names[spec.Name] {
+			return nil, fmt.Errorf("Machine %s is listed twice in %s", spec.Name, path)
+		}
+		names[spec.Name] = true
+
+		if _, err := file.driverOptions(spec); err != nil {
+			return nil, err
+		}
+	}
+
+	return &file, nil
+}
+
+// driverOptions resolves the options of a machine the way the command line
+// would: options which are not given take the default or the environment
+// variable of their flag.
+func (f *MachineFile) driverOptions(spec MachineSpec) (*cli.Context, error) {
+	if spec.Driver == "" {
+		return nil, fmt.Errorf("Machine %s has no driver", spec.Name)
+	}
+
+	driverFlags, err := drivers.GetDriverCreateFlags(spec.Driver)
+	if err != nil {
+		return nil, fmt.Errorf("Machine %s: %s", spec.Name, err)
+	}
+
+	set := newFlagSet(spec.Name, append(driverFlags, machineCreateFlags...))
+
+	keys := []string{}
+	for key := range spec.Options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if set.Lookup(key) == nil {
+			return nil, fmt.Errorf("Machine %s: unknown option %q for the %s driver", spec.Name, key, spec.Driver)
+		}
+
+		values, err := optionValues(spec.Options[key])
+		if err != nil {
+			return nil, fmt.Errorf("Machine %s: invalid value for option %q: %s", spec.Name, key, err)
+		}
+		for _, v := range values {
+			if isMachineFilePathOption(key) && !filepath.IsAbs(v) {
+				v = filepath.Join(f.dir, v)
+			}
+			if err := set.Set(key, v); err != nil {
+				return nil, fmt.Errorf("Machine %s: invalid value for option %q: %s", spec.Name, key, err)
+			}
+		}
+	}
+
+	labelKeys := []string{}
+	for key := range spec.Labels {
+		labelKeys = append(labelKeys, key)
+	}
+	sort.Strings(labelKeys)
+	for _, key := range labelKeys {
+		set.Set("label", key+"="+spec.Labels[key])
+	}
+
+	engineOptions := map[string][]string{
+		"engine-opt":               spec.Engine.Opt,
+		"engine-env":               spec.Engine.Env,
+		"engine-insecure-registry": spec.Engine.InsecureRegistry,
+		"engine-label":             spec.Engine.Labels,
+		"engine-registry-mirror":   spec.Engine.RegistryMirror,
+	}
+	for key, values := range engineOptions {
+		for _, v := range values {
+			set.Set(key, v)
+		}
+	}
+	if spec.Engine.StorageDriver != "" {
+		set.Set("engine-storage-driver", spec.Engine.StorageDriver)
+	}
+
+	c := cli.NewContext(nil, set, nil)
+
+	if _, err := ParseLabels(c.StringSlice("label")); err != nil {
+		return nil, fmt.Errorf("Machine %s: %s", spec.Name, err)
+	}
+	if _, err := NewEngineOptions(c); err != nil {
+		return nil, fmt.Errorf("Machine %s: %s", spec.Name, err)
+	}
+
+	return c, nil
+}
+
+// newFlagSet returns a flag set with the flags applied. Slice flags get a
+// value of their own, as their default value is shared by every flag set the
+// flag is applied to.
+func newFlagSet(name string, flags []cli.Flag) *flag.FlagSet {
+	set := flag.NewFlagSet(name, flag.ContinueOnError)
+	for _, f := range flags {
+		if sf, ok := f.(cli.StringSliceFlag); ok {
+			sf.Value = &cli.StringSlice{}
+			f = sf
+		}
+		f.Apply(set)
+	}
+	return set
+}
+
+// optionValues returns the command line values of an option, which is a
+// list for options that can be given several times.
+func optionValues(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case string:
+		return []string{v}, nil
+	case bool:
+		return []string{strconv.FormatBool(v)}, nil
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}, nil
+	case []interface{}:
+		values := []string{}
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("lists may only contain strings")
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("unsupported value %v", v)
+}
+
+func isMachineFilePathOption(key string) bool {
+	for _, k := range machineFilePathOptions {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// createMachines creates the machines, at most parallel at a time, and
+// returns the errors by machine name.
+func createMachines(ctx context.Context, store *Store, file *MachineFile, specs []MachineSpec, parallel int) map[string]error {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		errs   = map[string]error{}
+		tokens = make(chan struct{}, parallel)
+	)
+
+	for _, spec := range specs {
+		wg.Add(1)
+		go func(spec MachineSpec) {
+			defer wg.Done()
+			tokens <- struct{}{}
+			defer func() { <-tokens }()
+
+			log.Infof("Creating %s...", spec.Name)
+
+			err := createMachine(ctx, store, file, spec)
+			if err == nil {
+				log.Infof("%s has been created", spec.Name)
+				return
+			}
+
+			log.Errorf("Error creating %s: %s", spec.Name, err)
+			mu.Lock()
+			errs[spec.Name] = err
+			mu.Unlock()
+		}(spec)
+	}
+	wg.Wait()
+
+	return errs
+}
+
+func createMachine(ctx context.Context, store *Store, file *MachineFile, spec MachineSpec) error {
+	flags, err := file.driverOptions(spec)
+	if err != nil {
+		return err
+	}
+	_, err = store.Create(ctx, spec.Name, spec.Driver, flags)
+	return err
+}
+
+// applyPlan is what "machine apply" does to make the store match a machine
+// file.
+type applyPlan struct {
+	Create []MachineSpec
+	Drift  map[string][]string
+	Remove []string
+}
+
+// planApply compares the machines in the store with the machine file.
+// Machines in both are checked for drift in their driver, labels and engine
+// options; driver options are only used when a machine is created.
+func planApply(store *Store, file *MachineFile) (*applyPlan, error) {
+	hosts, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	existing := map[string]Host{}
+	for _, host := range hosts {
+		existing[host.Name] = host
+	}
+
+	plan := &applyPlan{Drift: map[string][]string{}}
+	inFile := map[string]bool{}
+
+	for _, spec := range file.Machines {
+		inFile[spec.Name] = true
+
+		host, ok := existing[spec.Name]
+		if !ok {
+			plan.Create = append(plan.Create, spec)
+			continue
+		}
+
+		flags, err := file.driverOptions(spec)
+		if err != nil {
+			return nil, err
+		}
+		if drift := machineDrift(host, spec, flags); len(drift) > 0 {
+			plan.Drift[spec.Name] = drift
+		}
+	}
+
+	for _, host := range hosts {
+		if !inFile[host.Name] {
+			plan.Remove = append(plan.Remove, host.Name)
+		}
+	}
+	sort.Strings(plan.Remove)
+
+	return plan, nil
+}
+
+// machineDrift describes how an existing machine differs from its spec.
+func machineDrift(host Host, spec MachineSpec, flags drivers.DriverOptions) []string {
+	drift := []string{}
+
+	if host.DriverName != spec.Driver {
+		drift = append(drift, fmt.Sprintf("driver is %s, file has %s", host.DriverName, spec.Driver))
+	}
+
+	labels, _ := ParseLabels(flags.StringSlice("label"))
+	keys := []string{}
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	for key := range host.Labels {
+		if _, ok := labels[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		want, inSpec := labels[key]
+		have, onHost := host.Labels[key]
+		switch {
+		case !onHost:
+			drift = append(drift, fmt.Sprintf("label %s is missing", key))
+		case !inSpec:
+			drift = append(drift, fmt.Sprintf("label %s is not in the file", key))
+		case want != have:
+			drift = append(drift, fmt.Sprintf("label %s is %q, file has %q", key, have, want))
+		}
+	}
+
+	engineOptions, _ := NewEngineOptions(flags)
+	have := strings.Join(append(host.EngineOptions.daemonArgs(), host.EngineOptions.Env...), " ")
+	want := strings.Join(append(engineOptions.daemonArgs(), engineOptions.Env...), " ")
+	if have != want {
+		drift = append(drift, fmt.Sprintf("engine options are %q, file has %q", have, want))
+	}
+
+	return drift
+}
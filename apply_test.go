@@ -0,0 +1,150 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func writeMachineFile(t *testing.T, contents string) (string, string) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(tmpDir, "machines.json")
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return tmpDir, path
+}
+
+func TestLoadMachineFile(t *testing.T) {
+	tmpDir, path := writeMachineFile(t, `{
+		"machines": [
+			{
+				"name": "web1",
+				"driver": "virtualbox",
+				"options": {
+					"virtualbox-memory": 2048,
+					"user-data": "cloud-config.yml"
+				},
+				"labels": {"team": "web"},
+				"engine": {
+					"insecure-registry": ["registry.local:5000"],
+					"storage-driver": "overlay"
+				}
+			}
+		]
+	}`)
+	defer os.RemoveAll(tmpDir)
+
+	file, err := LoadMachineFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Machines) != 1 {
+		t.Fatalf("expected 1 machine; received %d", len(file.Machines))
+	}
+
+	flags, err := file.driverOptions(file.Machines[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flags.Int("virtualbox-memory") != 2048 {
+		t.Fatalf("expected memory 2048; received %d", flags.Int("virtualbox-memory"))
+	}
+	if flags.Int("virtualbox-disk-size") != 20000 {
+		t.Fatalf("expected the default disk size; received %d", flags.Int("virtualbox-disk-size"))
+	}
+	if userData := filepath.Join(tmpDir, "cloud-config.yml"); flags.String("user-data") != userData {
+		t.Fatalf("expected user data %s; received %s", userData, flags.String("user-data"))
+	}
+	if labels := flags.StringSlice("label"); len(labels) != 1 || labels[0] != "team=web" {
+		t.Fatalf("expected label team=web; received %v", labels)
+	}
+	if flags.String("engine-storage-driver") != "overlay" {
+		t.Fatalf("expected storage driver overlay; received %s", flags.String("engine-storage-driver"))
+	}
+	if registries := flags.StringSlice("engine-insecure-registry"); len(registries) != 1 {
+		t.Fatalf("expected 1 insecure registry; received %v", registries)
+	}
+}
+
+func TestLoadMachineFileErrors(t *testing.T) {
+	files := map[string]string{
+		"unknown option":   `{"machines": [{"name": "a", "driver": "none", "options": {"amazonec2-region": "us-west-1"}}]}`,
+		"invalid value":    `{"machines": [{"name": "a", "driver": "virtualbox", "options": {"virtualbox-memory": "lots"}}]}`,
+		"unknown driver":   `{"machines": [{"name": "a", "driver": "bogus"}]}`,
+		"no driver":        `{"machines": [{"name": "a"}]}`,
+		"duplicate name":   `{"machines": [{"name": "a", "driver": "none"}, {"name": "a", "driver": "none"}]}`,
+		"invalid name":     `{"machines": [{"name": "a b", "driver": "none"}]}`,
+		"invalid label":    `{"machines": [{"name": "a", "driver": "none", "labels": {"": "x"}}]}`,
+		"invalid document": `machines: []`,
+	}
+
+	for desc, contents := range files {
+		tmpDir, path := writeMachineFile(t, contents)
+		if _, err := LoadMachineFile(path); err == nil {
+			t.Errorf("%s: expected error", desc)
+		}
+		os.RemoveAll(tmpDir)
+	}
+}
+
+func TestPlanApply(t *testing.T) {
+	if err := clearHosts(); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore("", "", "")
+	for _, name := range []string{"web1", "old"} {
+		if _, err := store.Create(context.Background(), name, "none", &DriverOptionsMock{
+			Data: map[string]interface{}{
+				"url":   "unix:///var/run/docker.sock",
+				"label": []string{"team=web"},
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tmpDir, path := writeMachineFile(t, `{
+		"machines": [
+			{"name": "web1", "driver": "none", "labels": {"team": "ops"}},
+			{"name": "web2", "driver": "none", "options": {"url": "unix:///var/run/docker.sock"}}
+		]
+	}`)
+	defer os.RemoveAll(tmpDir)
+
+	file, err := LoadMachineFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := planApply(store, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plan.Create) != 1 || plan.Create[0].Name != "web2" {
+		t.Fatalf("expected to create web2; received %v", plan.Create)
+	}
+	if len(plan.Remove) != 1 || plan.Remove[0] != "old" {
+		t.Fatalf("expected to remove old; received %v", plan.Remove)
+	}
+	drift := plan.Drift["web1"]
+	if len(drift) != 1 || !strings.Contains(drift[0], "label team") {
+		t.Fatalf("expected drift in label team; received %v", drift)
+	}
+
+	if errs := createMachines(context.Background(), store, file, plan.Create, 2); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if exists, err := store.Exists("web2"); err != nil || !exists {
+		t.Fatalf("expected web2 to be created: %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"text/tabwriter"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/codegangsta/cli"
+	"golang.org/x/net/context"
+)
+
+// hostSelectionFlags select the machines a lifecycle command acts on in
+// addition to the names given as arguments.
+var hostSelectionFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "all, a",
+		Usage: "Act on all machines",
+	},
+	cli.StringSliceFlag{
+		Name:  "filter",
+		Usage: "Act on the machines matching a filter, e.g. label=team=web (driver, name or label)",
+		Value: &cli.StringSlice{},
+	},
+	hostParallelFlag,
+	timeoutFlag,
+}
+
+var hostParallelFlag = cli.IntFlag{
+	Name:  "parallel",
+	Usage: "Number of machines to act on at the same time",
+	Value: 5,
+}
+
+// hostResult is the outcome of a command for one machine.
+type hostResult struct {
+	Name  string
+	Error error
+}
+
+// getHosts returns the machines named as arguments, all machines if --all
+// is given, or those matching --filter. Without any of them it returns the
+// active machine. The named machines which cannot be loaded are returned as
+// failed results, so the command still acts on the others.
+func getHosts(c *cli.Context) ([]Host, []hostResult, error) {
+	store := getStore(c)
+	names := c.Args()
+
+	filter, err := parseFilters(c.StringSlice("filter"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(filter.State) > 0 {
+		return nil, nil, fmt.Errorf("State filters are only supported by ls")
+	}
+	isFiltered := len(c.StringSlice("filter")) > 0
+
+	hosts := []Host{}
+	failed := []hostResult{}
+	switch {
+	case len(names) > 0:
+		for _, name := range names {
+			host, err := store.Load(name)
+			if err != nil {
+				failed = append(failed, hostResult{
+					Name:  name,
+					Error: fmt.Errorf("Error loading machine %s: %s", name, err),
+				})
+				continue
+			}
+			hosts = append(hosts, *host)
+		}
+	case c.Bool("all") || isFiltered:
+		if hosts, err = store.List(); err != nil {
+			return nil, nil, err
+		}
+	default:
+		host, err := store.GetActive()
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to get active host: %v", err)
+		}
+		if host == nil {
+			return nil, nil, fmt.Errorf("unable to get active host, active file not found")
+		}
+		hosts = append(hosts, *host)
+	}
+
+	return filterHosts(hosts, filter), failed, nil
+}
+
+// runHostCommand runs fn on the machines selected on the command line, with
+// the context of the command, and returns the status the command should
+// exit with. The outcome for each machine is printed when there is more
+// than one. The machines which could not be loaded come first, as failed.
+func runHostCommand(c *cli.Context, fn func(ctx context.Context, host *Host) error) int {
+	hosts, failed, err := getHosts(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(hosts) == 0 && len(failed) == 0 {
+		log.Warn("No machines match the filter")
+		return 0
+	}
+
+	ctx, cancel := commandContext(c)
+	defer cancel()
+
+	results := append(failed, runOnHosts(hosts, c.Int("parallel"), func(host *Host) error {
+		return fn(ctx, host)
+	})...)
+	if len(results) == 1 {
+		if err := results[0].Error; err != nil {
+			log.Error(err)
+		}
+	} else {
+		printHostResults(os.Stdout, results)
+	}
+
+	return resultsExitCode(results)
+}
+
+// runOnHosts calls fn for each host, at most parallel at a time, and
+// returns the results in the order of hosts.
+func runOnHosts(hosts []Host, parallel int, fn func(host *Host) error) []hostResult {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	results := make([]hostResult, len(hosts))
+	tokens := make(chan struct{}, parallel)
+
+	var wg sync.WaitGroup
+	for i := range hosts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tokens <- struct{}{}
+			defer func() { <-tokens }()
+
+			results[i] = hostResult{
+				Name:  hosts[i].Name,
+				Error: fn(&hosts[i]),
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// printHostResults prints a line for each machine with the outcome of the
+// command and returns the number of machines it failed for.
+func printHostResults(w io.Writer, results []hostResult) int {
+	failed := 0
+
+	tw := tabwriter.NewWriter(w, 5, 1, 3, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tRESULT")
+	for _, r := range results {
+		result := "OK"
+		if r.Error != nil {
+			result = fmt.Sprintf("Error: %s", r.Error)
+			failed++
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", r.Name, result)
+	}
+	tw.Flush()
+
+	return failed
+}
+
+// resultsExitCode returns the status a command exits with after acting on
+// several machines. A single failed machine passes on its own status, e.g.
+// the one of a failed hook script.
+func resultsExitCode(results []hostResult) int {
+	code := 0
+	for _, r := range results {
+		if r.Error == nil {
+			continue
+		}
+		if code != 0 {
+			return 1
+		}
+		code = exitCode(r.Error)
+	}
+	return code
+}
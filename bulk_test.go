@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunOnHosts(t *testing.T) {
+	hosts := []Host{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}}
+
+	var (
+		mu      sync.Mutex
+		running int
+		maxSeen int
+	)
+	results := runOnHosts(hosts, 2, func(host *Host) error {
+		mu.Lock()
+		running++
+		if running > maxSeen {
+			maxSeen = running
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+
+		if host.Name == "c" {
+			return errors.New("boom")
+		}
+		return nil
+	})
+
+	if maxSeen > 2 {
+		t.Fatalf("expected at most 2 hosts at a time; received %d", maxSeen)
+	}
+	for i, r := range results {
+		if r.Name != hosts[i].Name {
+			t.Fatalf("expected result %d for %s; received %s", i, hosts[i].Name, r.Name)
+		}
+		if (r.Error != nil) != (r.Name == "c") {
+			t.Fatalf("unexpected result for %s: %v", r.Name, r.Error)
+		}
+	}
+}
+
+func TestPrintHostResults(t *testing.T) {
+	var out bytes.Buffer
+	failed := printHostResults(&out, []hostResult{
+		{Name: "a"},
+		{Name: "b", Error: errors.New("boom")},
+	})
+
+	if failed != 1 {
+		t.Fatalf("expected 1 failure; received %d", failed)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines; received %q", out.String())
+	}
+	if !strings.HasPrefix(lines[1], "a") || !strings.HasSuffix(lines[1], "OK") {
+		t.Fatalf("unexpected line for a: %q", lines[1])
+	}
+	if !strings.HasPrefix(lines[2], "b") || !strings.HasSuffix(lines[2], "Error: boom") {
+		t.Fatalf("unexpected line for b: %q", lines[2])
+	}
+}
+
+func TestResultsExitCode(t *testing.T) {
+	hookErr := &HookError{Hook: "post-start", Script: "mount.sh", ExitCode: 4}
+
+	tests := []struct {
+		results  []hostResult
+		expected int
+	}{
+		{[]hostResult{{Name: "a"}, {Name: "b"}}, 0},
+		{[]hostResult{{Name: "a"}, {Name: "b", Error: hookErr}}, 4},
+		{[]hostResult{{Name: "a", Error: errors.New("boom")}}, 1},
+		{[]hostResult{{Name: "a", Error: hookErr}, {Name: "b", Error: hookErr}}, 1},
+	}
+
+	for _, test := range tests {
+		if code := resultsExitCode(test.results); code != test.expected {
+			t.Fatalf("expected exit code %d for %v; received %d", test.expected, test.results, code)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"sort"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/codegangsta/cli"
@@ -17,6 +18,7 @@ import (
 	_ "github.com/docker/machine/drivers/amazonec2"
 	_ "github.com/docker/machine/drivers/azure"
 	_ "github.com/docker/machine/drivers/digitalocean"
+	_ "github.com/docker/machine/drivers/generic"
 	_ "github.com/docker/machine/drivers/google"
 	_ "github.com/docker/machine/drivers/hyperv"
 	_ "github.com/docker/machine/drivers/none"
@@ -28,8 +30,10 @@ import (
 	_ "github.com/docker/machine/drivers/vmwarevcloudair"
 	_ "github.com/docker/machine/drivers/vmwarevsphere"
 	_ "github.com/docker/machine/drivers/pb"
+	_ "github.com/docker/machine/drivers/plugin/discovery"
 	"github.com/docker/machine/state"
 	"github.com/docker/machine/utils"
+	"golang.org/x/net/context"
 )
 
 type machineConfig struct {
@@ -40,11 +44,16 @@ type machineConfig struct {
 }
 
 type hostListItem struct {
-	Name       string
-	Active     bool
-	DriverName string
-	State      state.State
-	URL        string
+	Name          string
+	Active        bool
+	DriverName    string
+	State         state.State
+	URL           string
+	IP            string
+	DockerVersion string
+	CreatedAt     time.Time
+	CertExpiry    time.Time
+	Error         string
 }
 
 type hostListItemByName []hostListItem
@@ -119,6 +128,66 @@ func setupCertificates(caCertPath, caKeyPath, clientCertPath, clientKeyPath stri
 	return nil
 }
 
+// machineCreateFlags are the flags of "machine create" which apply to
+// machines of every driver.
+var machineCreateFlags = []cli.Flag{
+	cli.StringSliceFlag{
+		Name:  "label",
+		Usage: "Label to attach to the machine, e.g. team=web (can be given several times)",
+		Value: &cli.StringSlice{},
+	},
+	cli.StringSliceFlag{
+		Name:  "provision-script",
+		Usage: "Script to run on the machine once it has been provisioned (can be given several times)",
+		Value: &cli.StringSlice{},
+	},
+	cli.StringSliceFlag{
+		Name:  "post-start-script",
+		Usage: "Script to run on the machine whenever it has been started (can be given several times)",
+		Value: &cli.StringSlice{},
+	},
+	cli.StringSliceFlag{
+		Name:  "pre-remove-script",
+		Usage: "Script to run on the machine before it is removed (can be given several times)",
+		Value: &cli.StringSlice{},
+	},
+	cli.StringFlag{
+		Name:  "user-data",
+		Usage: "File passed to the provider as user data, e.g. for cloud-init",
+		Value: "",
+	},
+	cli.StringSliceFlag{
+		Name:  "engine-opt",
+		Usage: "Option to start the Docker daemon with, e.g. dns=8.8.8.8",
+		Value: &cli.StringSlice{},
+	},
+	cli.StringSliceFlag{
+		Name:  "engine-insecure-registry",
+		Usage: "Insecure registry to allow for the Docker daemon",
+		Value: &cli.StringSlice{},
+	},
+	cli.StringSliceFlag{
+		Name:  "engine-registry-mirror",
+		Usage: "Registry mirror for the Docker daemon",
+		Value: &cli.StringSlice{},
+	},
+	cli.StringFlag{
+		Name:  "engine-storage-driver",
+		Usage: "Storage driver for the Docker daemon",
+		Value: "",
+	},
+	cli.StringSliceFlag{
+		Name:  "engine-label",
+		Usage: "Label for the Docker daemon, e.g. storage=ssd",
+		Value: &cli.StringSlice{},
+	},
+	cli.StringSliceFlag{
+		Name:  "engine-env",
+		Usage: "Environment variable for the Docker daemon, e.g. HTTP_PROXY=http://proxy:3128",
+		Value: &cli.StringSlice{},
+	},
+}
+
 var Commands = []cli.Command{
 	{
 		Name:   "active",
@@ -126,7 +195,21 @@ var Commands = []cli.Command{
 		Action: cmdActive,
 	},
 	{
-		Flags: append(
+		Flags: append(append(
+			drivers.GetCreateFlags(),
+			cli.StringFlag{
+				Name:  "driver, d",
+				Usage: "Driver of the existing machine",
+				Value: "",
+			},
+			timeoutFlag,
+		), drivers.AdoptFlags...),
+		Name:   "adopt",
+		Usage:  "Import an existing machine that was created outside of machine",
+		Action: cmdAdopt,
+	},
+	{
+		Flags: append(append(
 			drivers.GetCreateFlags(),
 			cli.StringFlag{
 				Name: "driver, d",
@@ -136,11 +219,52 @@ var Commands = []cli.Command{
 				),
 				Value: "none",
 			},
-		),
+			cli.StringFlag{
+				Name:  "file, f",
+				Usage: "Create the machines listed in a machine file instead",
+				Value: "",
+			},
+			cli.StringFlag{
+				Name:  "profile",
+				Usage: "Profile to take the options from, options given explicitly win",
+				Value: "",
+			},
+			cli.IntFlag{
+				Name:  "parallel",
+				Usage: "Number of machines from the machine file to create at the same time",
+				Value: 5,
+			},
+			cli.BoolFlag{
+				Name:  "resume",
+				Usage: "Continue creating a machine for which create failed",
+			},
+			cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Check the options against the provider and show what would be created, without creating anything",
+			},
+			timeoutFlag,
+		), machineCreateFlags...),
 		Name:   "create",
 		Usage:  "Create a machine",
 		Action: cmdCreate,
 	},
+	{
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "parallel",
+				Usage: "Number of machines to create at the same time",
+				Value: 5,
+			},
+			cli.BoolFlag{
+				Name:  "prune",
+				Usage: "Remove machines which are not in the machine file",
+			},
+			timeoutFlag,
+		},
+		Name:   "apply",
+		Usage:  "Create the machines of a machine file which do not exist and report drift of the others",
+		Action: cmdApply,
+	},
 	{
 		Name:   "config",
 		Usage:  "Print the connection config for machine",
@@ -157,8 +281,9 @@ var Commands = []cli.Command{
 		Action: cmdIp,
 	},
 	{
+		Flags:  hostSelectionFlags,
 		Name:   "kill",
-		Usage:  "Kill a machine",
+		Usage:  "Kill machines",
 		Action: cmdKill,
 	},
 	{
@@ -167,25 +292,112 @@ var Commands = []cli.Command{
 				Name:  "quiet, q",
 				Usage: "Enable quiet mode",
 			},
+			cli.StringFlag{
+				Name:  "format, f",
+				Usage: "Output format: a Go template (e.g. '{{.Name}}\\t{{.IP}}') or 'json'",
+				Value: "",
+			},
+			cli.StringSliceFlag{
+				Name:  "filter",
+				Usage: "Filter output based on conditions, e.g. driver=virtualbox,state=Running,name=^dev",
+				Value: &cli.StringSlice{},
+			},
+			cli.DurationFlag{
+				Name:  "timeout, t",
+				Usage: "Timeout for getting the state of each machine",
+				Value: 10 * time.Second,
+			},
+			cli.IntFlag{
+				Name:  "parallel",
+				Usage: "Maximum number of machines to query at the same time",
+				Value: 10,
+			},
+			cli.BoolFlag{
+				Name:  "cached",
+				Usage: "Show the last known state of each machine without querying it",
+			},
 		},
 		Name:   "ls",
 		Usage:  "List machines",
 		Action: cmdLs,
 	},
 	{
+		Flags:  hostSelectionFlags,
+		Name:   "pause",
+		Usage:  "Pause machines",
+		Action: cmdPause,
+	},
+	{
+		Name:  "profile",
+		Usage: "Manage profiles of options for creating machines",
+		Subcommands: []cli.Command{
+			{
+				Flags: append(append(
+					drivers.GetCreateFlags(),
+					cli.StringFlag{
+						Name:  "driver, d",
+						Usage: "Driver to create machines with",
+						Value: "",
+					},
+				), machineCreateFlags...),
+				Name:   "save",
+				Usage:  "Save the given create options as a profile",
+				Action: cmdProfileSave,
+			},
+			{
+				Name:   "ls",
+				Usage:  "List profiles",
+				Action: cmdProfileLs,
+			},
+			{
+				Name:   "inspect",
+				Usage:  "Inspect a profile",
+				Action: cmdProfileInspect,
+			},
+			{
+				Name:   "rm",
+				Usage:  "Remove profiles",
+				Action: cmdProfileRm,
+			},
+		},
+	},
+	{
+		Flags:  hostSelectionFlags,
 		Name:   "restart",
-		Usage:  "Restart a machine",
+		Usage:  "Restart machines",
 		Action: cmdRestart,
 	},
+	{
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "cpus",
+				Usage: "Number of CPUs to give the machine",
+			},
+			cli.IntFlag{
+				Name:  "memory",
+				Usage: "Size of memory to give the machine in MB",
+			},
+		},
+		Name:   "resize",
+		Usage:  "Change the CPUs and memory of a stopped machine",
+		Action: cmdResize,
+	},
 	{
 		Flags: []cli.Flag{
 			cli.BoolFlag{
 				Name:  "force, f",
 				Usage: "Remove local configuration even if machine cannot be removed",
 			},
+			cli.StringSliceFlag{
+				Name:  "filter",
+				Usage: "Remove the machines matching a filter, e.g. label=team=web (driver, name or label)",
+				Value: &cli.StringSlice{},
+			},
+			hostParallelFlag,
+			timeoutFlag,
 		},
 		Name:   "rm",
-		Usage:  "Remove a machine",
+		Usage:  "Remove machines",
 		Action: cmdRm,
 	},
 	{
@@ -193,24 +405,88 @@ var Commands = []cli.Command{
 		Usage:  "Display the commands to set up the environment for the Docker client",
 		Action: cmdEnv,
 	},
+	{
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "since",
+				Usage: "Show the events since a time, e.g. 2015-03-01T10:00:00Z, or for a duration, e.g. 1h",
+				Value: "",
+			},
+			cli.BoolFlag{
+				Name:  "follow",
+				Usage: "Keep showing new events as they happen",
+			},
+			cli.StringFlag{
+				Name:  "format, f",
+				Usage: "Output format: a Go template (e.g. '{{.Machine}}\\t{{.Action}}') or 'json', one event per line",
+				Value: "",
+			},
+		},
+		Name:   "events",
+		Usage:  "Show who did what to the machines, or to those named, and when",
+		Action: cmdEvents,
+	},
 	{
 		Name:   "ssh",
 		Usage:  "Log into or run a command on a machine with SSH",
 		Action: cmdSsh,
 	},
 	{
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "restore",
+				Usage: "Roll the machine back to the snapshot instead of taking it",
+			},
+		},
+		Name:   "snapshot",
+		Usage:  "Take a snapshot of a machine, or restore one: snapshot [--restore] MACHINE NAME",
+		Action: cmdSnapshot,
+	},
+	{
+		Flags:  hostSelectionFlags,
 		Name:   "start",
-		Usage:  "Start a machine",
+		Usage:  "Start machines",
 		Action: cmdStart,
 	},
 	{
+		Flags: []cli.Flag{
+			cli.DurationFlag{
+				Name:  "timeout, t",
+				Usage: "Timeout for getting the state of the machine and pinging its Docker daemon",
+				Value: 10 * time.Second,
+			},
+			cli.StringFlag{
+				Name:  "format, f",
+				Usage: "Output format: a Go template (e.g. '{{.State}}\\t{{.DockerReachable}}') or 'json'",
+				Value: "",
+			},
+		},
+		Name:   "status",
+		Usage:  "Show the state of a machine in detail, including whether its Docker daemon answers",
+		Action: cmdStatus,
+	},
+	{
+		Flags:  hostSelectionFlags,
 		Name:   "stop",
-		Usage:  "Stop a machine",
+		Usage:  "Stop machines",
 		Action: cmdStop,
 	},
 	{
+		Flags:  hostSelectionFlags,
+		Name:   "unpause",
+		Usage:  "Resume paused machines",
+		Action: cmdUnpause,
+	},
+	{
+		Flags:  []cli.Flag{timeoutFlag},
+		Name:   "provision",
+		Usage:  "Re-apply the Docker daemon configuration to a machine, or finish creating it",
+		Action: cmdProvision,
+	},
+	{
+		Flags:  hostSelectionFlags,
 		Name:   "upgrade",
-		Usage:  "Upgrade a machine to the latest version of Docker",
+		Usage:  "Upgrade machines to the latest version of Docker",
 		Action: cmdUpgrade,
 	},
 	{
@@ -218,11 +494,32 @@ var Commands = []cli.Command{
 		Usage:  "Get the URL of a machine",
 		Action: cmdUrl,
 	},
+	{
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "state",
+				Usage: "State to wait for, e.g. Running or Stopped",
+				Value: "Running",
+			},
+			cli.BoolFlag{
+				Name:  "docker",
+				Usage: "Also wait for the Docker daemon of the machine to answer",
+			},
+			cli.DurationFlag{
+				Name:  "timeout",
+				Usage: "Give up and exit with a non-zero status after this long",
+				Value: 5 * time.Minute,
+			},
+		},
+		Name:   "wait",
+		Usage:  "Wait until a machine is in a state, and optionally until its Docker daemon answers",
+		Action: cmdWait,
+	},
 }
 
 func cmdActive(c *cli.Context) {
 	name := c.Args().First()
-	store := NewStore(c.GlobalString("storage-path"), c.GlobalString("tls-ca-cert"), c.GlobalString("tls-ca-key"))
+	store := getStore(c)
 
 	if name == "" {
 		host, err := store.GetActive()
@@ -250,22 +547,72 @@ func cmdCreate(c *cli.Context) {
 	driver := c.String("driver")
 	name := c.Args().First()
 
+	if c.String("file") != "" {
+		if name != "" {
+			log.Fatal("You cannot specify a machine name with a machine file")
+		}
+		if c.Bool("dry-run") {
+			log.Fatal("--dry-run is not supported with a machine file")
+		}
+		cmdCreateFromFile(c)
+		return
+	}
+
 	if name == "" {
 		cli.ShowCommandHelp(c, "create")
 		log.Fatal("You must specify a machine name")
 	}
 
+	if c.Bool("resume") {
+		resumeCreate(c, name)
+		return
+	}
+
+	store := getStore(c)
+
+	var flags drivers.DriverOptions = c
+	if profileName := c.String("profile"); profileName != "" {
+		profile, err := LoadProfile(profileName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if profile.Driver != "" && !c.IsSet("driver") {
+			driver = profile.Driver
+		}
+		flags = newProfileOptions(c, profile)
+	}
+
+	if c.Bool("dry-run") {
+		host, resources, err := store.Plan(name, driver, flags)
+		if err != nil {
+			log.Fatalf("Error checking machine: %s", err)
+		}
+		if err := printPlan(os.Stdout, host, resources); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	if err := setupCertificates(c.GlobalString("tls-ca-cert"), c.GlobalString("tls-ca-key"),
 		c.GlobalString("tls-client-cert"), c.GlobalString("tls-client-key")); err != nil {
 		log.Fatalf("Error generating certificates: %s", err)
 	}
 
-	store := NewStore(c.GlobalString("storage-path"), c.GlobalString("tls-ca-cert"), c.GlobalString("tls-ca-key"))
+	ctx, cancel := commandContext(c)
+	defer cancel()
 
-	host, err := store.Create(name, driver, c)
+	host, err := store.Create(ctx, name, driver, flags)
+	if hookErr, ok := err.(*HookError); ok {
+		log.Errorf("Error provisioning machine: %s", hookErr)
+		os.Exit(hookErr.ExitCode)
+	}
 	if err != nil {
 		log.Errorf("Error creating machine: %s", err)
-		log.Warn("You will want to check the provider to make sure the machine and associated resources were properly removed.")
+		if host != nil && host.CreateIncomplete {
+			log.Warnf("Once the cause is fixed, run \"%s create --resume %s\" to continue, or \"%s rm -f %s\" to remove the machine.", c.App.Name, name, c.App.Name, name)
+		} else {
+			log.Warn("You will want to check the provider to make sure the machine and associated resources were properly removed.")
+		}
 		log.Fatal("Error creating machine")
 	}
 	if err := store.SetActive(host); err != nil {
@@ -276,6 +623,206 @@ func cmdCreate(c *cli.Context) {
 	log.Infof("To point your Docker client at it, run this in your shell: $(%s env %s)", c.App.Name, name)
 }
 
+// resumeCreate continues "machine create" for a machine which was left
+// incomplete.
+func resumeCreate(c *cli.Context, name string) {
+	ctx, cancel := commandContext(c)
+	defer cancel()
+
+	host, err := getStore(c).Resume(ctx, name)
+	if hookErr, ok := err.(*HookError); ok {
+		log.Errorf("Error provisioning machine: %s", hookErr)
+		os.Exit(hookErr.ExitCode)
+	}
+	if err != nil {
+		log.Fatalf("Error creating machine: %s", err)
+	}
+	if err := getStore(c).SetActive(host); err != nil {
+		log.Fatalf("error setting active host: %v", err)
+	}
+
+	log.Infof("%q has been created and is now the active machine.", name)
+}
+
+func cmdCreateFromFile(c *cli.Context) {
+	file, err := LoadMachineFile(c.String("file"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := setupCertificates(c.GlobalString("tls-ca-cert"), c.GlobalString("tls-ca-key"),
+		c.GlobalString("tls-client-cert"), c.GlobalString("tls-client-key")); err != nil {
+		log.Fatalf("Error generating certificates: %s", err)
+	}
+
+	ctx, cancel := commandContext(c)
+	defer cancel()
+
+	errs := createMachines(ctx, getStore(c), file, file.Machines, c.Int("parallel"))
+	if len(errs) > 0 {
+		log.Fatalf("Error creating %d of %d machines", len(errs), len(file.Machines))
+	}
+}
+
+func cmdApply(c *cli.Context) {
+	path := c.Args().First()
+	if path == "" {
+		cli.ShowCommandHelp(c, "apply")
+		log.Fatal("You must specify a machine file")
+	}
+
+	file, err := LoadMachineFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	store := getStore(c)
+
+	plan, err := planApply(store, file)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, spec := range file.Machines {
+		for _, drift := range plan.Drift[spec.Name] {
+			log.Warnf("%s has drifted from %s: %s", spec.Name, path, drift)
+		}
+	}
+
+	ctx, cancel := commandContext(c)
+	defer cancel()
+
+	isError := false
+
+	if len(plan.Create) > 0 {
+		if err := setupCertificates(c.GlobalString("tls-ca-cert"), c.GlobalString("tls-ca-key"),
+			c.GlobalString("tls-client-cert"), c.GlobalString("tls-client-key")); err != nil {
+			log.Fatalf("Error generating certificates: %s", err)
+		}
+
+		if errs := createMachines(ctx, store, file, plan.Create, c.Int("parallel")); len(errs) > 0 {
+			isError = true
+		}
+	}
+
+	for _, name := range plan.Remove {
+		if !c.Bool("prune") {
+			log.Infof("%s is not in %s, pass --prune to remove it", name, path)
+			continue
+		}
+		log.Infof("Removing %s...", name)
+		if err := store.Remove(ctx, name, false); err != nil {
+			log.Errorf("Error removing machine %s: %s", name, err)
+			isError = true
+		}
+	}
+
+	if isError {
+		log.Fatal("There was an error applying the machine file")
+	}
+}
+
+func cmdProfileSave(c *cli.Context) {
+	name := c.Args().First()
+	if name == "" {
+		cli.ShowCommandHelp(c, "save")
+		log.Fatal("You must specify a profile name")
+	}
+
+	profile, err := NewProfile(name, c)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := SaveProfile(profile); err != nil {
+		log.Fatalf("Error saving profile: %s", err)
+	}
+
+	log.Infof("Profile %s has been saved.", name)
+}
+
+func cmdProfileLs(c *cli.Context) {
+	names, err := ListProfiles()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, name := range names {
+		fmt.Println(name)
+	}
+}
+
+func cmdProfileInspect(c *cli.Context) {
+	name := c.Args().First()
+	if name == "" {
+		cli.ShowCommandHelp(c, "inspect")
+		log.Fatal("You must specify a profile name")
+	}
+
+	profile, err := LoadProfile(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	prettyJSON, err := json.MarshalIndent(profile, "", "    ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(prettyJSON))
+}
+
+func cmdProfileRm(c *cli.Context) {
+	if len(c.Args()) == 0 {
+		cli.ShowCommandHelp(c, "rm")
+		log.Fatal("You must specify a profile name")
+	}
+
+	isError := false
+	for _, name := range c.Args() {
+		if err := RemoveProfile(name); err != nil {
+			log.Errorf("Error removing profile %s: %s", name, err)
+			isError = true
+		}
+	}
+	if isError {
+		log.Fatal("There was an error removing a profile.")
+	}
+}
+
+func cmdAdopt(c *cli.Context) {
+	driver := c.String("driver")
+	name := c.Args().First()
+
+	if name == "" {
+		cli.ShowCommandHelp(c, "adopt")
+		log.Fatal("You must specify a machine name")
+	}
+
+	if driver == "" {
+		cli.ShowCommandHelp(c, "adopt")
+		log.Fatal("You must specify the driver of the machine with --driver")
+	}
+
+	if err := setupCertificates(c.GlobalString("tls-ca-cert"), c.GlobalString("tls-ca-key"),
+		c.GlobalString("tls-client-cert"), c.GlobalString("tls-client-key")); err != nil {
+		log.Fatalf("Error generating certificates: %s", err)
+	}
+
+	store := getStore(c)
+
+	ctx, cancel := commandContext(c)
+	defer cancel()
+
+	host, err := store.Adopt(ctx, name, driver, c)
+	if err != nil {
+		log.Fatalf("Error adopting machine: %s", err)
+	}
+	if err := store.SetActive(host); err != nil {
+		log.Fatalf("error setting active host: %v", err)
+	}
+
+	log.Infof("%q has been adopted and is now the active machine.", name)
+	log.Infof("To point your Docker client at it, run this in your shell: $(%s env %s)", c.App.Name, name)
+}
+
 func cmdConfig(c *cli.Context) {
 	cfg, err := getMachineConfig(c)
 	if err != nil {
@@ -285,8 +832,19 @@ func cmdConfig(c *cli.Context) {
 		cfg.caCertPath, cfg.clientCertPath, cfg.clientKeyPath, cfg.machineUrl)
 }
 
+// hostInspect is the output of "machine inspect", the config of the host
+// along with what its driver can do.
+type hostInspect struct {
+	*Host
+	Capabilities []string
+}
+
 func cmdInspect(c *cli.Context) {
-	prettyJSON, err := json.MarshalIndent(getHost(c), "", "    ")
+	host := getHost(c)
+	prettyJSON, err := json.MarshalIndent(hostInspect{
+		Host:         host,
+		Capabilities: drivers.Capabilities(host.Driver),
+	}, "", "    ")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -304,93 +862,153 @@ func cmdIp(c *cli.Context) {
 }
 
 func cmdKill(c *cli.Context) {
-	if err := getHost(c).Driver.Kill(); err != nil {
-		log.Fatal(err)
+	if code := runHostCommand(c, func(ctx context.Context, host *Host) error {
+		return host.Kill()
+	}); code != 0 {
+		os.Exit(code)
 	}
 }
 
 func cmdLs(c *cli.Context) {
 	quiet := c.Bool("quiet")
-	store := NewStore(c.GlobalString("storage-path"), c.GlobalString("tls-ca-cert"), c.GlobalString("tls-ca-key"))
+	format := c.String("format")
+	store := getStore(c)
 
-	hostList, err := store.List()
+	filter, err := parseFilters(c.StringSlice("filter"))
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	w := tabwriter.NewWriter(os.Stdout, 5, 1, 3, ' ', 0)
-
-	if !quiet {
-		fmt.Fprintln(w, "NAME\tACTIVE\tDRIVER\tSTATE\tURL")
+	hostList, err := store.List()
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	items := []hostListItem{}
-	hostListItems := make(chan hostListItem)
-
-	for _, host := range hostList {
-		if !quiet {
-			tmpHost, err := store.GetActive()
-			if err != nil {
-				log.Errorf("There's a problem with the active host: %s", err)
-			}
+	hostList = filterHosts(hostList, filter)
 
-			if tmpHost == nil {
-				log.Errorf("There's a problem finding the active host")
-			}
+	w := tabwriter.NewWriter(os.Stdout, 5, 1, 3, ' ', 0)
 
-			go getHostState(host, *store, hostListItems)
-		} else {
+	if quiet && len(filter.State) == 0 {
+		for _, host := range hostList {
 			fmt.Fprintf(w, "%s\n", host.Name)
 		}
+		w.Flush()
+		return
 	}
 
 	if !quiet {
-		for i := 0; i < len(hostList); i++ {
-			items = append(items, <-hostListItems)
+		tmpHost, err := store.GetActive()
+		if err != nil {
+			log.Errorf("There's a problem with the active host: %s", err)
+		}
+
+		if tmpHost == nil && len(hostList) > 0 {
+			log.Errorf("There's a problem finding the active host")
 		}
 	}
 
-	close(hostListItems)
+	opts := hostListOptions{
+		Timeout:       c.Duration("timeout"),
+		Parallel:      c.Int("parallel"),
+		Cached:        c.Bool("cached"),
+		DockerVersion: format == "json" || strings.Contains(format, ".DockerVersion"),
+	}
+
+	if opts.DockerVersion && !opts.Cached {
+		tlsConfig, err := utils.GetDockerTLSConfig(c.GlobalString("tls-ca-cert"),
+			c.GlobalString("tls-client-cert"), c.GlobalString("tls-client-key"))
+		if err != nil {
+			log.Debugf("unable to load client certificates: %s", err)
+		}
+		opts.DockerTLS = tlsConfig
+	}
+
+	items := filterHostListItems(getHostListItems(hostList, *store, opts), filter)
 
 	sort.Sort(hostListItemByName(items))
 
-	for _, item := range items {
-		activeString := ""
-		if item.Active {
-			activeString = "*"
+	if quiet {
+		for _, item := range items {
+			fmt.Fprintf(w, "%s\n", item.Name)
 		}
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
-			item.Name, activeString, item.DriverName, item.State, item.URL)
+	} else if err := printHostList(w, items, format); err != nil {
+		log.Fatal(err)
 	}
 
 	w.Flush()
 }
 
 func cmdRestart(c *cli.Context) {
-	if err := getHost(c).Driver.Restart(); err != nil {
+	if code := runHostCommand(c, func(ctx context.Context, host *Host) error {
+		return host.Restart(ctx)
+	}); code != 0 {
+		os.Exit(code)
+	}
+}
+
+func cmdPause(c *cli.Context) {
+	if code := runHostCommand(c, func(ctx context.Context, host *Host) error {
+		return host.Pause()
+	}); code != 0 {
+		os.Exit(code)
+	}
+}
+
+func cmdUnpause(c *cli.Context) {
+	if code := runHostCommand(c, func(ctx context.Context, host *Host) error {
+		return host.Unpause()
+	}); code != 0 {
+		os.Exit(code)
+	}
+}
+
+func cmdResize(c *cli.Context) {
+	cpus, memory := c.Int("cpus"), c.Int("memory")
+	if cpus <= 0 && memory <= 0 {
+		log.Fatal("Specify the new size of the machine with --cpus or --memory")
+	}
+
+	if err := getHost(c).Resize(cpus, memory); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func cmdSnapshot(c *cli.Context) {
+	if len(c.Args()) != 2 {
+		cli.ShowCommandHelp(c, "snapshot")
+		log.Fatal("You must specify a machine and the name of the snapshot")
+	}
+	host := getHost(c)
+	name := c.Args()[1]
+
+	if c.Bool("restore") {
+		if err := host.RestoreSnapshot(name); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("Restored %s to snapshot %s", host.Name, name)
+		return
+	}
+
+	if err := host.Snapshot(name); err != nil {
 		log.Fatal(err)
 	}
+	log.Infof("Took snapshot %s of %s", name, host.Name)
 }
 
 func cmdRm(c *cli.Context) {
-	if len(c.Args()) == 0 {
+	if len(c.Args()) == 0 && len(c.StringSlice("filter")) == 0 {
 		cli.ShowCommandHelp(c, "rm")
 		log.Fatal("You must specify a machine name")
 	}
 
 	force := c.Bool("force")
+	store := getStore(c)
 
-	isError := false
-
-	store := NewStore(c.GlobalString("storage-path"), c.GlobalString("tls-ca-cert"), c.GlobalString("tls-ca-key"))
-	for _, host := range c.Args() {
-		if err := store.Remove(host, force); err != nil {
-			log.Errorf("Error removing machine %s: %s", host, err)
-			isError = true
-		}
-	}
-	if isError {
-		log.Fatal("There was an error removing a machine. To force remove it, pass the -f option. Warning: this might leave it running on the provider.")
+	if code := runHostCommand(c, func(ctx context.Context, host *Host) error {
+		return store.Remove(ctx, host.Name, force)
+	}); code != 0 {
+		log.Error("There was an error removing a machine. To force remove it, pass the -f option. Warning: this might leave it running on the provider.")
+		os.Exit(code)
 	}
 }
 
@@ -403,13 +1021,58 @@ func cmdEnv(c *cli.Context) {
 		utils.GetMachineClientCertDir(), cfg.machineUrl)
 }
 
+func cmdEvents(c *cli.Context) {
+	since, err := parseSince(c.String("since"), time.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 5, 1, 3, ' ', 0)
+	printer, err := newEventPrinter(w, c.String("format"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	printer.header()
+
+	path := getStore(c).eventLogPath()
+	var offset int64
+	for {
+		var events []Event
+		events, offset, err = readEvents(path, offset, since)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, event := range filterEvents(events, c.Args()) {
+			if err := printer.print(event); err != nil {
+				log.Fatal(err)
+			}
+		}
+		w.Flush()
+
+		if !c.Bool("follow") {
+			return
+		}
+		time.Sleep(eventPollInterval)
+	}
+}
+
+func cmdStatus(c *cli.Context) {
+	status := getHostStatus(getHost(c), c.Duration("timeout"))
+
+	w := tabwriter.NewWriter(os.Stdout, 5, 1, 3, ' ', 0)
+	if err := printHostStatus(w, status, c.String("format")); err != nil {
+		log.Fatal(err)
+	}
+	w.Flush()
+}
+
 func cmdSsh(c *cli.Context) {
 	var (
 		err    error
 		sshCmd *exec.Cmd
 	)
 	name := c.Args().First()
-	store := NewStore(c.GlobalString("storage-path"), c.GlobalString("tls-ca-cert"), c.GlobalString("tls-ca-key"))
+	store := getStore(c)
 
 	if name == "" {
 		host, err := store.GetActive()
@@ -433,6 +1096,7 @@ func cmdSsh(c *cli.Context) {
 	if err != nil {
 		log.Fatal(err)
 	}
+	log.Debugf("executing: %v", strings.Join(sshCmd.Args, " "))
 
 	sshCmd.Stdin = os.Stdin
 	sshCmd.Stdout = os.Stdout
@@ -443,20 +1107,41 @@ func cmdSsh(c *cli.Context) {
 }
 
 func cmdStart(c *cli.Context) {
-	if err := getHost(c).Start(); err != nil {
-		log.Fatal(err)
+	if code := runHostCommand(c, func(ctx context.Context, host *Host) error {
+		return host.Start(ctx)
+	}); code != 0 {
+		os.Exit(code)
 	}
 }
 
 func cmdStop(c *cli.Context) {
-	if err := getHost(c).Stop(); err != nil {
+	if code := runHostCommand(c, func(ctx context.Context, host *Host) error {
+		return host.Stop(ctx)
+	}); code != 0 {
+		os.Exit(code)
+	}
+}
+
+func cmdProvision(c *cli.Context) {
+	host := getHost(c)
+	if host.CreateIncomplete {
+		resumeCreate(c, host.Name)
+		return
+	}
+
+	ctx, cancel := commandContext(c)
+	defer cancel()
+
+	if err := host.Provision(ctx); err != nil {
 		log.Fatal(err)
 	}
 }
 
 func cmdUpgrade(c *cli.Context) {
-	if err := getHost(c).Upgrade(); err != nil {
-		log.Fatal(err)
+	if code := runHostCommand(c, func(ctx context.Context, host *Host) error {
+		return host.Upgrade()
+	}); code != 0 {
+		os.Exit(code)
 	}
 }
 
@@ -469,6 +1154,24 @@ func cmdUrl(c *cli.Context) {
 	fmt.Println(url)
 }
 
+func cmdWait(c *cli.Context) {
+	want, err := parseState(c.String("state"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if c.Bool("docker") && want != state.Running {
+		log.Fatal("--docker can only be used to wait for the Running state")
+	}
+
+	host := getHost(c)
+	ctx, cancel := commandContext(c)
+	defer cancel()
+
+	if err := waitForHost(ctx, host, want, c.Bool("docker")); err != nil {
+		log.Fatal(err)
+	}
+}
+
 func cmdNotFound(c *cli.Context, command string) {
 	log.Fatalf(
 		"%s: '%s' is not a %s command. See '%s --help'.",
@@ -479,9 +1182,17 @@ func cmdNotFound(c *cli.Context, command string) {
 	)
 }
 
+// getStore returns the store configured by the global flags.
+func getStore(c *cli.Context) *Store {
+	store := NewStore(c.GlobalString("storage-path"), c.GlobalString("tls-ca-cert"), c.GlobalString("tls-ca-key"))
+	store.ClientCertPath = c.GlobalString("tls-client-cert")
+	store.ClientKeyPath = c.GlobalString("tls-client-key")
+	return store
+}
+
 func getHost(c *cli.Context) *Host {
 	name := c.Args().First()
-	store := NewStore(c.GlobalString("storage-path"), c.GlobalString("tls-ca-cert"), c.GlobalString("tls-ca-key"))
+	store := getStore(c)
 
 	if name == "" {
 		host, err := store.GetActive()
@@ -503,9 +1214,19 @@ func getHost(c *cli.Context) *Host {
 }
 
 func getHostState(host Host, store Store, hostListItems chan<- hostListItem) {
+	if host.CreateIncomplete {
+		hostListItems <- getIncompleteHostListItem(host, store)
+		return
+	}
+
+	errs := []string{}
+
 	currentState, err := host.Driver.GetState()
 	if err != nil {
-		log.Errorf("error getting state for host %s: %s", host.Name, err)
+		currentState = stateFromError(err)
+		if currentState != state.Unknown {
+			errs = append(errs, fmt.Sprintf("error getting state: %s", err))
+		}
 	}
 
 	url, err := host.GetURL()
@@ -513,7 +1234,7 @@ func getHostState(host Host, store Store, hostListItems chan<- hostListItem) {
 		if err == drivers.ErrHostIsNotRunning {
 			url = ""
 		} else {
-			log.Errorf("error getting URL for host %s: %s", host.Name, err)
+			errs = append(errs, fmt.Sprintf("error getting URL: %s", err))
 		}
 	}
 
@@ -529,12 +1250,16 @@ func getHostState(host Host, store Store, hostListItems chan<- hostListItem) {
 		DriverName: host.Driver.DriverName(),
 		State:      currentState,
 		URL:        url,
+		IP:         getHostFromURL(url),
+		CreatedAt:  host.CreatedAt,
+		CertExpiry: getCertExpiry(host),
+		Error:      strings.Join(errs, "; "),
 	}
 }
 
 func getMachineConfig(c *cli.Context) (*machineConfig, error) {
 	name := c.Args().First()
-	store := NewStore(c.GlobalString("storage-path"), c.GlobalString("tls-ca-cert"), c.GlobalString("tls-ca-key"))
+	store := getStore(c)
 	var machine *Host
 
 	if name == "" {
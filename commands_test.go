@@ -7,6 +7,7 @@ import (
 
 	drivers "github.com/docker/machine/drivers"
 	"github.com/docker/machine/state"
+	"golang.org/x/net/context"
 )
 
 type FakeDriver struct {
@@ -37,19 +38,19 @@ func (d *FakeDriver) PreCreateCheck() error {
 	return nil
 }
 
-func (d *FakeDriver) Create() error {
+func (d *FakeDriver) Create(ctx context.Context) error {
 	return nil
 }
 
-func (d *FakeDriver) Remove() error {
+func (d *FakeDriver) Remove(ctx context.Context) error {
 	return nil
 }
 
-func (d *FakeDriver) Start() error {
+func (d *FakeDriver) Start(ctx context.Context) error {
 	return nil
 }
 
-func (d *FakeDriver) Stop() error {
+func (d *FakeDriver) Stop(ctx context.Context) error {
 	return nil
 }
 
@@ -61,22 +62,6 @@ func (d *FakeDriver) Kill() error {
 	return nil
 }
 
-func (d *FakeDriver) Upgrade() error {
-	return nil
-}
-
-func (d *FakeDriver) StartDocker() error {
-	return nil
-}
-
-func (d *FakeDriver) StopDocker() error {
-	return nil
-}
-
-func (d *FakeDriver) GetDockerConfigDir() string {
-	return ""
-}
-
 func (d *FakeDriver) GetSSHCommand(args ...string) (*exec.Cmd, error) {
 	return &exec.Cmd{}, nil
 }
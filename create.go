@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/state"
+	"github.com/docker/machine/utils"
+	"golang.org/x/net/context"
+)
+
+// sshBackoff is how often a new host is checked for SSH once its driver
+// has created it, and how long it may take to accept connections.
+var sshBackoff = utils.Backoff{
+	Interval:    2 * time.Second,
+	MaxInterval: 10 * time.Second,
+	Jitter:      0.2,
+	Timeout:     2 * time.Minute,
+}
+
+// The steps of "machine create", in order. The last one which completed is
+// kept in the host config as CreateStep.
+const (
+	createStepInfrastructure = "infrastructure"
+	createStepSSH            = "ssh"
+	createStepDocker         = "docker"
+	createStepCerts          = "certs"
+	createStepProvision      = "provision"
+)
+
+type createStep struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+func (h *Host) createSteps() []createStep {
+	return []createStep{
+		{createStepInfrastructure, h.Driver.Create},
+		{createStepSSH, h.waitForSSH},
+		{createStepDocker, func(ctx context.Context) error {
+			return h.installDocker()
+		}},
+		{createStepCerts, h.configureTLS},
+		{createStepProvision, func(ctx context.Context) error {
+			return h.runHook("provision", h.Hooks.Provision)
+		}},
+	}
+}
+
+// runCreateSteps runs the steps of "machine create" after CreateStep and
+// saves the host config after each of them, as well as after a failed one
+// so whatever the driver has created is kept track of. No step is started
+// once ctx is done.
+func (h *Host) runCreateSteps(ctx context.Context) error {
+	if err := h.SaveConfig(); err != nil {
+		return err
+	}
+	defer h.openOperationLog(eventCreate).close()
+
+	steps := h.createSteps()
+
+	next := 0
+	if h.CreateStep != "" {
+		for i, step := range steps {
+			if step.Name == h.CreateStep {
+				next = i + 1
+			}
+		}
+		if next == 0 {
+			return fmt.Errorf("Unknown create step %q", h.CreateStep)
+		}
+	}
+
+	progress := utils.NewLoggerProgress(h.logger())
+	for _, step := range steps[next:] {
+		h.logger().Debugf("Running create step %s for %s", step.Name, h.Name)
+
+		err := ctx.Err()
+		if err == nil {
+			progress.Step(fmt.Sprintf("Creating %s: %s", h.Name, step.Name), -1)
+			err = step.Run(ctx)
+			progress.Done(err)
+		}
+		if err != nil {
+			if saveErr := h.SaveConfig(); saveErr != nil {
+				h.logger().Warnf("Error saving machine %s: %s", h.Name, saveErr)
+			}
+			return err
+		}
+
+		h.CreateStep = step.Name
+		if err := h.SaveConfig(); err != nil {
+			return err
+		}
+	}
+
+	h.CreateIncomplete = false
+	h.CreateStep = ""
+
+	return h.SaveConfig()
+}
+
+// resumeCreate continues a create which failed, from the step after the
+// last one which completed.
+func (h *Host) resumeCreate(ctx context.Context) (err error) {
+	defer h.recordEvent(eventCreate, time.Now(), &err)
+
+	if h.CreateStep == "" {
+		// The infrastructure step may have failed after the provider made
+		// the host, e.g. while waiting for it, and creating it again would
+		// leave a second one behind.
+		if st, err := h.Driver.GetState(); err == nil && st != state.None {
+			return fmt.Errorf("Machine %s exists on the provider (%s) although its creation failed, remove it with \"rm\" or \"rm -f\" and create it again", h.Name, st)
+		}
+
+		h.logger().Infof("Creating %s from the start...", h.Name)
+
+		if h.UserData != "" {
+			if err := setUserData(h.Driver, h.UserData); err != nil {
+				return err
+			}
+		}
+	} else {
+		h.logger().Infof("Resuming the creation of %s after step %s...", h.Name, h.CreateStep)
+	}
+
+	return h.runCreateSteps(ctx)
+}
+
+// waitForSSH waits until a command can be run on the host over SSH.
+func (h *Host) waitForSSH(ctx context.Context) error {
+	if h.Driver.DriverName() == "none" {
+		return nil
+	}
+
+	h.logger().Infof("Waiting for SSH on %s...", h.Name)
+
+	return utils.WaitFor(ctx, "SSH on "+h.Name, sshBackoff, func() error {
+		cmd, err := h.Driver.GetSSHCommand("exit 0")
+		if err != nil {
+			return utils.Permanent(err)
+		}
+		h.logger().Debugf("executing: %v", strings.Join(cmd.Args, " "))
+		return cmd.Run()
+	})
+}
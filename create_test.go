@@ -0,0 +1,130 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/state"
+	"golang.org/x/net/context"
+)
+
+func TestResumeCreate(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	script := writeScript(t, tmpDir, "provision.sh", "#!/bin/sh\nexit 3\n")
+
+	host := &Host{
+		Name:             "test",
+		Driver:           &localDriver{},
+		Hooks:            HookOptions{Provision: []string{script}},
+		CreateIncomplete: true,
+		CreateStep:       createStepCerts,
+		storePath:        tmpDir,
+	}
+
+	if _, ok := host.resumeCreate(context.Background()).(*HookError); !ok {
+		t.Fatal("expected the provision script to fail")
+	}
+	if !host.CreateIncomplete || host.CreateStep != createStepCerts {
+		t.Fatalf("expected create to stay after step %s; received %s", createStepCerts, host.CreateStep)
+	}
+
+	item := getIncompleteHostListItem(*host, *NewStore(tmpDir, "", ""))
+	if item.State != state.Incomplete {
+		t.Fatalf("expected state Incomplete; received %s", item.State)
+	}
+
+	writeScript(t, tmpDir, "provision.sh", "#!/bin/sh\nexit 0\n")
+
+	if err := host.resumeCreate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if host.CreateIncomplete || host.CreateStep != "" {
+		t.Fatalf("expected create to be complete; received step %s", host.CreateStep)
+	}
+}
+
+type userDataDriver struct {
+	localDriver
+	UserData []byte
+}
+
+func (d *userDataDriver) SetUserData(userData []byte) {
+	d.UserData = userData
+}
+
+func TestResumeCreateUserData(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	userData := writeScript(t, tmpDir, "cloud-config.yml", "#cloud-config\n")
+
+	driver := &userDataDriver{}
+	host := &Host{
+		Name:             "test",
+		Driver:           driver,
+		UserData:         userData,
+		CreateIncomplete: true,
+		storePath:        tmpDir,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	host.resumeCreate(ctx)
+
+	if string(driver.UserData) != "#cloud-config\n" {
+		t.Fatalf("expected the user data to be passed to the driver again; received %q", driver.UserData)
+	}
+}
+
+func TestResumeCreateExistingInstance(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	host := &Host{
+		Name:             "test",
+		Driver:           &localDriver{FakeDriver{MockState: state.Running}},
+		CreateIncomplete: true,
+		storePath:        tmpDir,
+	}
+
+	err = host.resumeCreate(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "rm -f") {
+		t.Fatalf("expected to be told to remove the machine; received %v", err)
+	}
+	if !host.CreateIncomplete || host.CreateStep != "" {
+		t.Fatalf("expected create not to run; received step %s", host.CreateStep)
+	}
+}
+
+func TestResumeCreateUnknownStep(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	host := &Host{
+		Name:             "test",
+		Driver:           &localDriver{},
+		CreateIncomplete: true,
+		CreateStep:       "bogus",
+		storePath:        tmpDir,
+	}
+
+	if err := host.resumeCreate(context.Background()); err == nil {
+		t.Fatal("expected error for an unknown step")
+	}
+}
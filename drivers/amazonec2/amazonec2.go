@@ -6,9 +6,9 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"os/exec"
 	"path"
-	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/codegangsta/cli"
@@ -16,6 +16,8 @@ import (
 	"github.com/docker/machine/drivers/amazonec2/amz"
 	"github.com/docker/machine/ssh"
 	"github.com/docker/machine/state"
+	"github.com/docker/machine/utils"
+	"golang.org/x/net/context"
 )
 
 const (
@@ -24,9 +26,9 @@ const (
 	defaultInstanceType      = "t2.micro"
 	defaultRootSize          = 16
 	ipRange                  = "0.0.0.0/0"
-	dockerConfigDir          = "/etc/docker"
 	machineSecurityGroupName = "docker-machine"
 	dockerPort               = 2376
+	defaultSSHUser           = "ubuntu"
 )
 
 type Driver struct {
@@ -49,10 +51,13 @@ type Driver struct {
 	VpcId             string
 	SubnetId          string
 	Zone              string
+	SSHUser           string
+	Labels            map[string]string
 	CaCertPath        string
 	PrivateKeyPath    string
 	storePath         string
 	keyPath           string
+	userData          []byte
 }
 
 type CreateFlags struct {
@@ -190,6 +195,16 @@ func (d *Driver) DriverName() string {
 	return driverName
 }
 
+// SetLabels sets the labels which are added as tags to the instance.
+func (d *Driver) SetLabels(labels map[string]string) {
+	d.Labels = labels
+}
+
+// SetUserData sets the user data passed to the instance on launch.
+func (d *Driver) SetUserData(userData []byte) {
+	d.userData = userData
+}
+
 func (d *Driver) checkPrereqs() error {
 	// check for existing keypair
 	key, err := d.getClient().GetKeyPair(d.MachineName)
@@ -207,7 +222,7 @@ func (d *Driver) PreCreateCheck() error {
 	return d.checkPrereqs()
 }
 
-func (d *Driver) Create() error {
+func (d *Driver) Create(ctx context.Context) error {
 	if err := d.checkPrereqs(); err != nil {
 		return err
 	}
@@ -218,42 +233,17 @@ func (d *Driver) Create() error {
 		return fmt.Errorf("unable to create key pair: %s", err)
 	}
 
-	if err := d.configureSecurityGroup(d.SecurityGroupName); err != nil {
+	if err := d.configureSecurityGroup(ctx, d.SecurityGroupName); err != nil {
 		return err
 	}
 
-	bdm := &amz.BlockDeviceMapping{
-		DeviceName:          "/dev/sda1",
-		VolumeSize:          d.RootSize,
-		DeleteOnTermination: true,
-		VolumeType:          "gp2",
-	}
-
-	// get the subnet id
-	regionZone := d.Region + d.Zone
-	subnetId := d.SubnetId
-
-	if d.SubnetId == "" {
-		subnets, err := d.getClient().GetSubnets()
-		if err != nil {
-			return err
-		}
-
-		for _, s := range subnets {
-			if s.AvailabilityZone == regionZone {
-				subnetId = s.SubnetId
-				break
-			}
-		}
-
-	}
-
-	if subnetId == "" {
-		return fmt.Errorf("unable to find a subnet in the zone: %s", regionZone)
+	subnetId, err := d.getSubnetId()
+	if err != nil {
+		return err
 	}
 
 	log.Debugf("launching instance in subnet %s", subnetId)
-	instance, err := d.getClient().RunInstance(d.AMI, d.InstanceType, d.Zone, 1, 1, d.SecurityGroupId, d.KeyName, subnetId, bdm)
+	instance, err := d.getClient().RunInstance(d.AMI, d.InstanceType, d.Zone, 1, 1, d.SecurityGroupId, d.KeyName, subnetId, d.blockDeviceMapping(), d.userData)
 
 	if err != nil {
 		return fmt.Errorf("Error launching instance: %s", err)
@@ -261,7 +251,9 @@ func (d *Driver) Create() error {
 
 	d.InstanceId = instance.InstanceId
 
-	d.waitForInstance()
+	if err := d.waitForInstance(ctx); err != nil {
+		return err
+	}
 
 	log.Debugf("created instance ID %s, IP address %s",
 		d.InstanceId,
@@ -269,14 +261,16 @@ func (d *Driver) Create() error {
 
 	log.Infof("Waiting for SSH on %s:%d", d.IPAddress, 22)
 
-	if err := ssh.WaitForTCP(fmt.Sprintf("%s:%d", d.IPAddress, 22)); err != nil {
+	if err := ssh.WaitForTCP(ctx, fmt.Sprintf("%s:%d", d.IPAddress, 22)); err != nil {
 		return err
 	}
 
 	log.Debug("Settings tags for instance")
-	tags := map[string]string{
-		"Name": d.MachineName,
+	tags := map[string]string{}
+	for k, v := range d.Labels {
+		tags[k] = v
 	}
+	tags["Name"] = d.MachineName
 
 	if err = d.getClient().CreateTags(d.InstanceId, tags); err != nil {
 		return err
@@ -297,19 +291,92 @@ func (d *Driver) Create() error {
 		return err
 	}
 
-	log.Debugf("Installing Docker")
+	return nil
+}
 
-	cmd, err = d.GetSSHCommand("if [ ! -e /usr/bin/docker ]; then curl -sL https://get.docker.com | sh -; fi")
+// Adopt takes over an instance that was launched outside of machine. The
+// instance is looked up by the --instance-id flag and accessed with the
+// private key given by --ssh-key.
+func (d *Driver) Adopt(ctx context.Context, flags drivers.DriverOptions) error {
+	region, err := validateAwsRegion(flags.String("amazonec2-region"))
 	if err != nil {
 		return err
+	}
 
+	d.AccessKey = flags.String("amazonec2-access-key")
+	d.SecretKey = flags.String("amazonec2-secret-key")
+	d.SessionToken = flags.String("amazonec2-session-token")
+	d.Region = region
+	d.InstanceId = flags.String("instance-id")
+	d.SSHUser = flags.String("ssh-user")
+	sshKey := flags.String("ssh-key")
+
+	if d.AccessKey == "" {
+		return fmt.Errorf("amazonec2 driver requires the --amazonec2-access-key option")
 	}
-	if err := cmd.Run(); err != nil {
+
+	if d.SecretKey == "" {
+		return fmt.Errorf("amazonec2 driver requires the --amazonec2-secret-key option")
+	}
+
+	if d.InstanceId == "" {
+		return fmt.Errorf("amazonec2 driver requires the --instance-id option to adopt an instance")
+	}
+
+	if sshKey == "" {
+		return fmt.Errorf("amazonec2 driver requires the --ssh-key option to adopt an instance")
+	}
+
+	if _, err := ioutil.ReadFile(sshKey); err != nil {
+		return fmt.Errorf("unable to read ssh key: %s", err)
+	}
+
+	log.Infof("Looking up instance %s...", d.InstanceId)
+
+	inst, err := d.getInstance()
+	if err != nil {
 		return err
+	}
 
+	if inst.InstanceState.Name != "running" {
+		return fmt.Errorf("instance %s is %s; only running instances can be adopted", d.InstanceId, inst.InstanceState.Name)
 	}
 
-	return nil
+	d.AMI = inst.ImageId
+	d.InstanceType = inst.InstanceType
+	d.IPAddress = inst.IpAddress
+	d.SubnetId = inst.SubnetId
+	d.VpcId = inst.VpcId
+	if az := inst.Placement.AvailabilityZone; len(az) > len(d.Region) {
+		d.Zone = az[len(d.Region):]
+	}
+	for _, iface := range inst.NetworkInterfaceSet {
+		if len(iface.GroupSet) > 0 {
+			d.SecurityGroupId = iface.GroupSet[0].GroupId
+			d.SecurityGroupName = iface.GroupSet[0].GroupName
+			break
+		}
+	}
+
+	if d.IPAddress == "" {
+		return fmt.Errorf("instance %s does not have a public IP address", d.InstanceId)
+	}
+
+	// the key pair was not created by machine, so KeyName is left empty
+	// to make sure it is not deleted when the machine is removed
+	if err := os.MkdirAll(d.storePath, 0700); err != nil {
+		return err
+	}
+	if err := utils.CopyFile(sshKey, d.sshKeyPath()); err != nil {
+		return fmt.Errorf("unable to copy ssh key: %s", err)
+	}
+	if err := os.Chmod(d.sshKeyPath(), 0600); err != nil {
+		return err
+	}
+
+	log.Infof("Waiting for SSH on %s:%d", d.IPAddress, 22)
+
+	return ssh.WaitForTCP(ctx, fmt.Sprintf("%s:%d", d.IPAddress, 22))
 }
 
 func (d *Driver) GetURL() (string, error) {
@@ -332,12 +399,20 @@ func (d *Driver) GetIP() (string, error) {
 	return d.IPAddress, nil
 }
 
-func (d *Driver) GetState() (state.State, error) {
+func (d *Driver) GetProviderState() (string, error) {
 	inst, err := d.getInstance()
+	if err != nil {
+		return "", err
+	}
+	return inst.InstanceState.Name, nil
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	providerState, err := d.GetProviderState()
 	if err != nil {
 		return state.Error, err
 	}
-	switch inst.InstanceState.Name {
+	switch providerState {
 	case "pending":
 		return state.Starting, nil
 	case "running":
@@ -348,33 +423,35 @@ func (d *Driver) GetState() (state.State, error) {
 		return state.Stopping, nil
 	case "stopped":
 		return state.Stopped, nil
+	case "terminated":
+		return state.None, nil
 	}
-	return state.None, nil
+	return state.Unknown, nil
 }
 
-func (d *Driver) Start() error {
+func (d *Driver) Start(ctx context.Context) error {
 	if err := d.getClient().StartInstance(d.InstanceId); err != nil {
 		return err
 	}
 
-	if err := d.waitForInstance(); err != nil {
+	if err := d.waitForInstance(ctx); err != nil {
 		return err
 	}
 
-	if err := d.updateDriver(); err != nil {
+	if err := d.updateDriver(ctx); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (d *Driver) Stop() error {
+func (d *Driver) Stop(ctx context.Context) error {
 	if err := d.getClient().StopInstance(d.InstanceId, false); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (d *Driver) Remove() error {
+func (d *Driver) Remove(ctx context.Context) error {
 
 	if err := d.terminate(); err != nil {
 		return fmt.Errorf("unable to terminate instance: %s", err)
@@ -402,56 +479,12 @@ func (d *Driver) Kill() error {
 	return nil
 }
 
-func (d *Driver) StartDocker() error {
-	log.Debug("Starting Docker...")
-
-	cmd, err := d.GetSSHCommand("sudo service docker start")
-	if err != nil {
-		return err
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (d *Driver) StopDocker() error {
-	log.Debug("Stopping Docker...")
-
-	cmd, err := d.GetSSHCommand("sudo service docker stop")
-	if err != nil {
-		return err
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (d *Driver) GetDockerConfigDir() string {
-	return dockerConfigDir
-}
-
-func (d *Driver) Upgrade() error {
-	log.Debugf("Upgrading Docker")
-
-	cmd, err := d.GetSSHCommand("sudo apt-get update && sudo apt-get install --upgrade lxc-docker")
-	if err != nil {
-		return err
-
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-
-	}
-
-	return cmd.Run()
-}
-
 func (d *Driver) GetSSHCommand(args ...string) (*exec.Cmd, error) {
-	return ssh.GetSSHCommand(d.IPAddress, 22, "ubuntu", d.sshKeyPath(), args...), nil
+	user := d.SSHUser
+	if user == "" {
+		user = defaultSSHUser
+	}
+	return ssh.GetSSHCommand(d.IPAddress, 22, user, d.sshKeyPath(), args...), nil
 }
 
 func (d *Driver) getClient() *amz.EC2 {
@@ -463,27 +496,22 @@ func (d *Driver) sshKeyPath() string {
 	return path.Join(d.storePath, "id_rsa")
 }
 
-func (d *Driver) updateDriver() error {
-	inst, err := d.getInstance()
-	if err != nil {
-		return err
-	}
-	// wait for ipaddress
-	for {
-		i, err := d.getInstance()
+// updateDriver waits for the instance to get an IP address and records
+// it.
+func (d *Driver) updateDriver(ctx context.Context) error {
+	return utils.WaitFor(ctx, "an IP address for instance "+d.InstanceId, utils.DefaultBackoff, func() error {
+		inst, err := d.getInstance()
 		if err != nil {
 			return err
 		}
-		if i.IpAddress == "" {
-			time.Sleep(1 * time.Second)
-			continue
+		if inst.IpAddress == "" {
+			return fmt.Errorf("instance %s has no IP address yet", d.InstanceId)
 		}
 
 		d.InstanceId = inst.InstanceId
 		d.IPAddress = inst.IpAddress
-		break
-	}
-	return nil
+		return nil
+	})
 }
 
 func (d *Driver) publicSSHKeyPath() string {
@@ -499,19 +527,27 @@ func (d *Driver) getInstance() (*amz.EC2Instance, error) {
 	return &instance, nil
 }
 
-func (d *Driver) waitForInstance() error {
-	for {
+func (d *Driver) waitForInstance(ctx context.Context) error {
+	err := utils.WaitFor(ctx, "instance "+d.InstanceId+" to run", utils.DefaultBackoff, func() error {
+		// Errors are retried, as a new instance may not be known to the
+		// API yet
 		st, err := d.GetState()
 		if err != nil {
 			return err
 		}
-		if st == state.Running {
-			break
+		switch st {
+		case state.Running:
+			return nil
+		case state.None:
+			return utils.Permanent(fmt.Errorf("instance %s was terminated", d.InstanceId))
 		}
-		time.Sleep(1 * time.Second)
+		return fmt.Errorf("instance %s is %s", d.InstanceId, st)
+	})
+	if err != nil {
+		return err
 	}
 
-	if err := d.updateDriver(); err != nil {
+	if err := d.updateDriver(ctx); err != nil {
 		return err
 	}
 
@@ -554,24 +590,138 @@ func (d *Driver) terminate() error {
 	return nil
 }
 
-func (d *Driver) configureSecurityGroup(groupName string) error {
-	log.Debugf("configuring security group in %s", d.VpcId)
+// Plan checks the configuration against EC2 with a dry run of the launch,
+// which verifies the credentials, region, AMI, instance type and subnet, and
+// returns what Create would make.
+func (d *Driver) Plan() ([]drivers.PlannedResource, error) {
+	resources := []drivers.PlannedResource{
+		{Action: "create", Type: "key pair", Name: d.MachineName},
+	}
+
+	securityGroup, err := d.findSecurityGroup(d.SecurityGroupName)
+	if err != nil {
+		return nil, err
+	}
+
+	securityGroupId := ""
+	if securityGroup == nil {
+		resources = append(resources, drivers.PlannedResource{
+			Action: "create",
+			Type:   "security group",
+			Name:   d.SecurityGroupName,
+			Detail: fmt.Sprintf("in %s, ports 22 and %d open to %s", d.VpcId, dockerPort, ipRange),
+		})
+	} else {
+		securityGroupId = securityGroup.GroupId
+		detail := securityGroupId
+		for _, perm := range configureSecurityGroupPermissions(securityGroup) {
+			detail += fmt.Sprintf(", opening port %d to %s", perm.FromPort, perm.IpRange)
+		}
+		resources = append(resources, drivers.PlannedResource{
+			Action: "use",
+			Type:   "security group",
+			Name:   d.SecurityGroupName,
+			Detail: detail,
+		})
+	}
+
+	subnetId, err := d.getSubnetId()
+	if err != nil {
+		return nil, err
+	}
+	resources = append(resources, drivers.PlannedResource{
+		Action: "use",
+		Type:   "subnet",
+		Name:   subnetId,
+		Detail: "in " + d.Region + d.Zone,
+	})
+
+	bdm := d.blockDeviceMapping()
+	if err := d.getClient().CheckRunInstance(d.AMI, d.InstanceType, d.Zone, securityGroupId, "", subnetId, bdm, d.userData); err != nil {
+		return nil, fmt.Errorf("Error checking instance launch: %s", err)
+	}
+
+	resources = append(resources,
+		drivers.PlannedResource{
+			Action: "create",
+			Type:   "instance",
+			Name:   d.MachineName,
+			Detail: fmt.Sprintf("%s from %s", d.InstanceType, d.AMI),
+		},
+		drivers.PlannedResource{
+			Action: "create",
+			Type:   "volume",
+			Name:   bdm.DeviceName,
+			Detail: fmt.Sprintf("%d GB %s, deleted with the instance", bdm.VolumeSize, bdm.VolumeType),
+		},
+	)
+
+	return resources, nil
+}
+
+func (d *Driver) blockDeviceMapping() *amz.BlockDeviceMapping {
+	return &amz.BlockDeviceMapping{
+		DeviceName:          "/dev/sda1",
+		VolumeSize:          d.RootSize,
+		DeleteOnTermination: true,
+		VolumeType:          "gp2",
+	}
+}
+
+// getSubnetId returns the subnet given with --amazonec2-subnet-id, or else
+// the first one in the zone.
+func (d *Driver) getSubnetId() (string, error) {
+	regionZone := d.Region + d.Zone
+	subnetId := d.SubnetId
+
+	if d.SubnetId == "" {
+		subnets, err := d.getClient().GetSubnets()
+		if err != nil {
+			return "", err
+		}
+
+		for _, s := range subnets {
+			if s.AvailabilityZone == regionZone {
+				subnetId = s.SubnetId
+				break
+			}
+		}
+
+	}
 
-	var securityGroup *amz.SecurityGroup
+	if subnetId == "" {
+		return "", fmt.Errorf("unable to find a subnet in the zone: %s", regionZone)
+	}
+
+	return subnetId, nil
+}
 
+// findSecurityGroup returns the security group with the given name, or nil
+// if there is none.
+func (d *Driver) findSecurityGroup(groupName string) (*amz.SecurityGroup, error) {
 	groups, err := d.getClient().GetSecurityGroups()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	for _, grp := range groups {
-		if grp.GroupName == groupName {
+	for i := range groups {
+		if groups[i].GroupName == groupName {
 			log.Debugf("found existing security group (%s) in %s", groupName, d.VpcId)
-			securityGroup = &grp
-			break
+			return &groups[i], nil
 		}
 	}
 
+	return nil, nil
+}
+
+func (d *Driver) configureSecurityGroup(ctx context.Context, groupName string) error {
+	log.Debugf("configuring security group in %s", d.VpcId)
+
+	securityGroup, err := d.findSecurityGroup(groupName)
+	if err != nil {
+		return err
+	}
+
 	// if not found, create
 	if securityGroup == nil {
 		log.Debugf("creating security group (%s) in %s", groupName, d.VpcId)
@@ -582,13 +732,11 @@ func (d *Driver) configureSecurityGroup(groupName string) error {
 		securityGroup = group
 		// wait until created (dat eventual consistency)
 		log.Debugf("waiting for group (%s) to become available", group.GroupId)
-		for {
+		if err := utils.WaitFor(ctx, "security group "+group.GroupId, utils.DefaultBackoff, func() error {
 			_, err := d.getClient().GetSecurityGroupById(group.GroupId)
-			if err == nil {
-				break
-			}
-			log.Debug(err)
-			time.Sleep(1 * time.Second)
+			return err
+		}); err != nil {
+			return err
 		}
 	}
 
@@ -659,6 +807,11 @@ func (d *Driver) deleteSecurityGroup() error {
 }
 
 func (d *Driver) deleteKeyPair() error {
+	if d.KeyName == "" {
+		log.Debugf("no key pair managed by machine, skipping removal")
+		return nil
+	}
+
 	log.Debugf("deleting key pair: %s", d.KeyName)
 
 	if err := d.getClient().DeleteKeyPair(d.KeyName); err != nil {
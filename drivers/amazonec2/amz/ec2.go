@@ -112,11 +112,12 @@ func newAwsApiResponseError(r http.Response) error {
 	if err := getDecodedResponse(r, &errorResponse); err != nil {
 		return fmt.Errorf("Error decoding error response: %s", err)
 	}
-	msg := ""
+	apiErr := &ApiError{StatusCode: r.StatusCode}
 	for _, e := range errorResponse.Errors {
-		msg += fmt.Sprintf("%s\n", e.Message)
+		apiErr.Codes = append(apiErr.Codes, e.Code)
+		apiErr.Message += fmt.Sprintf("%s\n", e.Message)
 	}
-	return fmt.Errorf("Non-200 API response: code=%d message=%s", r.StatusCode, msg)
+	return apiErr
 }
 
 func newAwsApiCallError(err error) error {
@@ -166,18 +167,49 @@ func (e *EC2) awsApiCall(v url.Values) (*http.Response, error) {
 	return resp, nil
 }
 
-func (e *EC2) RunInstance(amiId string, instanceType string, zone string, minCount int, maxCount int, securityGroup string, keyName string, subnetId string, bdm *BlockDeviceMapping) (EC2Instance, error) {
+func (e *EC2) RunInstance(amiId string, instanceType string, zone string, minCount int, maxCount int, securityGroup string, keyName string, subnetId string, bdm *BlockDeviceMapping, userData []byte) (EC2Instance, error) {
 	instance := Instance{}
+	v := e.runInstanceValues(amiId, instanceType, zone, minCount, maxCount, securityGroup, keyName, subnetId, bdm, userData)
+
+	resp, err := e.awsApiCall(v)
+
+	if err != nil {
+		return instance.info, newAwsApiCallError(err)
+	}
+	defer resp.Body.Close()
+
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return instance.info, fmt.Errorf("Error reading AWS response body")
+	}
+	unmarshalledResponse := RunInstancesResponse{}
+	err = xml.Unmarshal(contents, &unmarshalledResponse)
+	if err != nil {
+		return instance.info, fmt.Errorf("Error unmarshalling AWS response XML: %s", err)
+	}
+
+	instance.info = unmarshalledResponse.Instances[0]
+	return instance.info, nil
+}
+
+// runInstanceValues returns the parameters of a RunInstances call. The key
+// pair and security group are left out when empty, so the defaults of the
+// account are used.
+func (e *EC2) runInstanceValues(amiId string, instanceType string, zone string, minCount int, maxCount int, securityGroup string, keyName string, subnetId string, bdm *BlockDeviceMapping, userData []byte) url.Values {
 	v := url.Values{}
 	v.Set("Action", "RunInstances")
 	v.Set("ImageId", amiId)
 	v.Set("Placement.AvailabilityZone", e.Region+zone)
 	v.Set("MinCount", strconv.Itoa(minCount))
 	v.Set("MaxCount", strconv.Itoa(maxCount))
-	v.Set("KeyName", keyName)
+	if keyName != "" {
+		v.Set("KeyName", keyName)
+	}
 	v.Set("InstanceType", instanceType)
 	v.Set("NetworkInterface.0.DeviceIndex", "0")
-	v.Set("NetworkInterface.0.SecurityGroupId.0", securityGroup)
+	if securityGroup != "" {
+		v.Set("NetworkInterface.0.SecurityGroupId.0", securityGroup)
+	}
 	v.Set("NetworkInterface.0.SubnetId", subnetId)
 	v.Set("NetworkInterface.0.AssociatePublicIpAddress", "1")
 
@@ -193,25 +225,29 @@ func (e *EC2) RunInstance(amiId string, instanceType string, zone string, minCou
 		v.Set("BlockDeviceMapping.0.Ebs.DeleteOnTermination", strconv.Itoa(deleteOnTerm))
 	}
 
-	resp, err := e.awsApiCall(v)
-
-	if err != nil {
-		return instance.info, newAwsApiCallError(err)
+	if len(userData) > 0 {
+		v.Set("UserData", base64.StdEncoding.EncodeToString(userData))
 	}
-	defer resp.Body.Close()
 
-	contents, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return instance.info, fmt.Errorf("Error reading AWS response body")
+	return v
+}
+
+// CheckRunInstance asks EC2 whether RunInstance would succeed with the same
+// arguments, without launching anything. It checks the credentials and
+// permissions as well as the AMI, instance type, zone and subnet.
+func (e *EC2) CheckRunInstance(amiId string, instanceType string, zone string, securityGroup string, keyName string, subnetId string, bdm *BlockDeviceMapping, userData []byte) error {
+	v := e.runInstanceValues(amiId, instanceType, zone, 1, 1, securityGroup, keyName, subnetId, bdm, userData)
+	v.Set("DryRun", "true")
+
+	resp, err := e.awsApiCall(v)
+	if err == nil {
+		resp.Body.Close()
+		return nil
 	}
-	unmarshalledResponse := RunInstancesResponse{}
-	err = xml.Unmarshal(contents, &unmarshalledResponse)
-	if err != nil {
-		return instance.info, fmt.Errorf("Error unmarshalling AWS response XML: %s", err)
+	if apiErr, ok := err.(*ApiError); ok && apiErr.HasCode(ErrorDryRunOperation) {
+		return nil
 	}
-
-	instance.info = unmarshalledResponse.Instances[0]
-	return instance.info, nil
+	return newAwsApiCallError(err)
 }
 
 func (e *EC2) DeleteKeyPair(name string) error {
@@ -1,5 +1,7 @@
 package amz
 
+import "fmt"
+
 type ErrorResponse struct {
 	Errors []struct {
 		Code    string
@@ -7,3 +9,24 @@ type ErrorResponse struct {
 	} `xml:"Errors>Error"`
 	RequestID string
 }
+
+// ApiError is returned for a response of the EC2 API which is not a 200.
+type ApiError struct {
+	StatusCode int
+	Codes      []string
+	Message    string
+}
+
+func (e *ApiError) Error() string {
+	return fmt.Sprintf("Non-200 API response: code=%d message=%s", e.StatusCode, e.Message)
+}
+
+// HasCode returns whether the response has an error with the given code.
+func (e *ApiError) HasCode(code string) bool {
+	for _, c := range e.Codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
@@ -1,5 +1,6 @@
 package amz
 
 const (
-	ErrorDuplicateGroup = "InvalidGroup.Duplicate"
+	ErrorDuplicateGroup  = "InvalidGroup.Duplicate"
+	ErrorDryRunOperation = "DryRunOperation"
 )
@@ -2,12 +2,10 @@ package azure
 
 import (
 	"fmt"
-	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
-	"time"
 
 	azure "github.com/MSOpenTech/azure-sdk-for-go"
 	"github.com/MSOpenTech/azure-sdk-for-go/clients/vmClient"
@@ -18,10 +16,7 @@ import (
 	"github.com/docker/machine/drivers"
 	"github.com/docker/machine/ssh"
 	"github.com/docker/machine/state"
-)
-
-const (
-	dockerConfigDir = "/etc/docker"
+	"golang.org/x/net/context"
 )
 
 type Driver struct {
@@ -181,7 +176,7 @@ func (driver *Driver) PreCreateCheck() error {
 	return nil
 }
 
-func (driver *Driver) Create() error {
+func (driver *Driver) Create(ctx context.Context) error {
 	if err := driver.setUserSubscription(); err != nil {
 		return err
 	}
@@ -216,19 +211,7 @@ func (driver *Driver) Create() error {
 	log.Info("Waiting for SSH...")
 	log.Debugf("Host: %s SSH Port: %d", driver.getHostname(), driver.SSHPort)
 
-	if err := ssh.WaitForTCP(fmt.Sprintf("%s:%d", driver.getHostname(), driver.SSHPort)); err != nil {
-		return err
-	}
-
-	cmd, err := driver.GetSSHCommand("if [ ! -e /usr/bin/docker ]; then curl -sL https://get.docker.com | sh -; fi")
-	if err != nil {
-		return err
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-
-	return nil
+	return ssh.WaitForTCP(ctx, fmt.Sprintf("%s:%d", driver.getHostname(), driver.SSHPort))
 }
 
 func (driver *Driver) runSSHCommand(command string, retries int) error {
@@ -287,7 +270,7 @@ func (driver *Driver) GetState() (state.State, error) {
 	return state.None, nil
 }
 
-func (driver *Driver) Start() error {
+func (driver *Driver) Start(ctx context.Context) error {
 	err := driver.setUserSubscription()
 	if err != nil {
 		return err
@@ -308,18 +291,14 @@ func (driver *Driver) Start() error {
 	if err != nil {
 		return err
 	}
-	err = driver.waitForSSH()
-	if err != nil {
-		return err
-	}
-	err = driver.waitForDocker()
+	err = driver.waitForSSH(ctx)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (driver *Driver) Stop() error {
+func (driver *Driver) Stop(ctx context.Context) error {
 	err := driver.setUserSubscription()
 	if err != nil {
 		return err
@@ -342,7 +321,7 @@ func (driver *Driver) Stop() error {
 	return nil
 }
 
-func (driver *Driver) Remove() error {
+func (driver *Driver) Remove(ctx context.Context) error {
 	err := driver.setUserSubscription()
 	if err != nil {
 		return err
@@ -384,11 +363,7 @@ func (driver *Driver) Restart() error {
 	if err != nil {
 		return err
 	}
-	err = driver.waitForSSH()
-	if err != nil {
-		return err
-	}
-	err = driver.waitForDocker()
+	err = driver.waitForSSH(context.Background())
 	if err != nil {
 		return err
 	}
@@ -418,38 +393,6 @@ func (driver *Driver) Kill() error {
 	return nil
 }
 
-func (d *Driver) StartDocker() error {
-	log.Debug("Starting Docker...")
-
-	cmd, err := d.GetSSHCommand("sudo service docker start")
-	if err != nil {
-		return err
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (d *Driver) StopDocker() error {
-	log.Debug("Stopping Docker...")
-
-	cmd, err := d.GetSSHCommand("sudo service docker stop")
-	if err != nil {
-		return err
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (d *Driver) GetDockerConfigDir() string {
-	return dockerConfigDir
-}
-
 func (driver *Driver) GetSSHCommand(args ...string) (*exec.Cmd, error) {
 	err := driver.setUserSubscription()
 	if err != nil {
@@ -468,22 +411,6 @@ func (driver *Driver) GetSSHCommand(args ...string) (*exec.Cmd, error) {
 	return ssh.GetSSHCommand(driver.getHostname(), driver.SSHPort, driver.UserName, driver.sshKeyPath(), args...), nil
 }
 
-func (driver *Driver) Upgrade() error {
-	log.Debugf("Upgrading Docker")
-
-	cmd, err := driver.GetSSHCommand("sudo apt-get update && sudo apt-get install --upgrade lxc-docker")
-	if err != nil {
-		return err
-
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-
-	}
-
-	return cmd.Run()
-}
-
 func generateVMName() string {
 	randomID := utils.TruncateID(utils.GenerateRandomID())
 	return fmt.Sprintf("docker-host-%s", randomID)
@@ -524,9 +451,9 @@ func (driver *Driver) addDockerEndpoint(vmConfig *vmClient.Role) error {
 	return nil
 }
 
-func (driver *Driver) waitForSSH() error {
+func (driver *Driver) waitForSSH(ctx context.Context) error {
 	log.Infof("Waiting for SSH...")
-	err := ssh.WaitForTCP(fmt.Sprintf("%s:%v", driver.getHostname(), driver.SSHPort))
+	err := ssh.WaitForTCP(ctx, fmt.Sprintf("%s:%v", driver.getHostname(), driver.SSHPort))
 	if err != nil {
 		return err
 	}
@@ -534,35 +461,6 @@ func (driver *Driver) waitForSSH() error {
 	return nil
 }
 
-func (driver *Driver) waitForDocker() error {
-	log.Infof("Waiting for docker daemon on host to be available...")
-	maxRepeats := 48
-	url := fmt.Sprintf("%s:%v", driver.getHostname(), driver.DockerPort)
-	success := waitForDockerEndpoint(url, maxRepeats)
-	if !success {
-		return fmt.Errorf("Can not run docker daemon on remote machine. Please try again.")
-	}
-	return nil
-}
-
-func waitForDockerEndpoint(url string, maxRepeats int) bool {
-	counter := 0
-	for {
-		conn, err := net.Dial("tcp", url)
-		if err != nil {
-			time.Sleep(10 * time.Second)
-			counter++
-			if counter == maxRepeats {
-				return false
-			}
-			continue
-		}
-		defer conn.Close()
-		break
-	}
-	return true
-}
-
 func (driver *Driver) generateCertForAzure() error {
 	if err := ssh.GenerateSSHKey(driver.sshKeyPath()); err != nil {
 		return err
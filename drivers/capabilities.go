@@ -0,0 +1,140 @@
+package drivers
+
+import (
+	"fmt"
+)
+
+// Names of the operations a driver may or may not support, as listed by
+// Capabilities.
+const (
+	CapabilityStart    = "start"
+	CapabilityStop     = "stop"
+	CapabilityRestart  = "restart"
+	CapabilityKill     = "kill"
+	CapabilityRemove   = "remove"
+	CapabilityState    = "state"
+	CapabilitySSH      = "ssh"
+	CapabilityAdopt    = "adopt"
+	CapabilityUpgrade  = "upgrade"
+	CapabilityLabels   = "labels"
+	CapabilityUserData = "user-data"
+	CapabilityPlan     = "plan"
+	CapabilityPause    = "pause"
+	CapabilityResize   = "resize"
+	CapabilitySnapshot = "snapshot"
+
+	CapabilityProviderState = "provider-state"
+)
+
+// AllCapabilities lists the names of all the operations a driver may
+// support, sorted.
+var AllCapabilities = []string{
+	CapabilityAdopt,
+	CapabilityKill,
+	CapabilityLabels,
+	CapabilityPause,
+	CapabilityPlan,
+	CapabilityProviderState,
+	CapabilityRemove,
+	CapabilityResize,
+	CapabilityRestart,
+	CapabilitySnapshot,
+	CapabilitySSH,
+	CapabilityStart,
+	CapabilityState,
+	CapabilityStop,
+	CapabilityUpgrade,
+	CapabilityUserData,
+}
+
+// ErrNotSupported is returned by drivers for operations they do not
+// support, e.g. starting a host which can only be powered on by other
+// means.
+type ErrNotSupported struct {
+	Driver    string
+	Operation string
+}
+
+func (e *ErrNotSupported) Error() string {
+	return fmt.Sprintf("The %s driver does not support %s", e.Driver, e.Operation)
+}
+
+// NotSupported returns the error for the operation of d which d does not
+// support.
+func NotSupported(d Driver, operation string) error {
+	return &ErrNotSupported{Driver: d.DriverName(), Operation: operation}
+}
+
+// IsNotSupported returns whether err reports an operation a driver does not
+// support.
+func IsNotSupported(err error) bool {
+	_, ok := err.(*ErrNotSupported)
+	return ok
+}
+
+// Restricted is implemented by drivers which do not support some of the
+// operations of Driver, or of the optional interfaces they implement. They
+// return ErrNotSupported from them.
+type Restricted interface {
+	// Unsupported returns the capabilities the driver lacks
+	Unsupported() []string
+}
+
+// Capabilities returns the operations d supports, sorted by name: those of
+// Driver and of the optional interfaces it implements, less those it
+// reports as Unsupported.
+func Capabilities(d Driver) []string {
+	unsupported := map[string]bool{}
+	if r, ok := d.(Restricted); ok {
+		for _, capability := range r.Unsupported() {
+			unsupported[capability] = true
+		}
+	}
+
+	capabilities := []string{}
+	for _, capability := range AllCapabilities {
+		if !unsupported[capability] && implements(d, capability) {
+			capabilities = append(capabilities, capability)
+		}
+	}
+	return capabilities
+}
+
+// Supports returns whether d supports the operation capability.
+func Supports(d Driver, capability string) bool {
+	for _, c := range Capabilities(d) {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// implements returns whether d has the methods of capability.
+func implements(d Driver, capability string) bool {
+	var ok bool
+	switch capability {
+	case CapabilityAdopt:
+		_, ok = d.(Adopter)
+	case CapabilityUpgrade:
+		_, ok = d.(Upgrader)
+	case CapabilityLabels:
+		_, ok = d.(Labeler)
+	case CapabilityUserData:
+		_, ok = d.(UserDataSetter)
+	case CapabilityPlan:
+		_, ok = d.(Planner)
+	case CapabilityPause:
+		_, ok = d.(Pauser)
+	case CapabilityResize:
+		_, ok = d.(Resizer)
+	case CapabilitySnapshot:
+		_, ok = d.(Snapshotter)
+	case CapabilityProviderState:
+		_, ok = d.(ProviderStater)
+	default:
+		// The operations of Driver itself
+		ok = true
+	}
+	return ok
+}
@@ -0,0 +1,66 @@
+package drivers
+
+import (
+	"reflect"
+	"testing"
+)
+
+type pausingDriver struct {
+	namedDriver
+}
+
+func (d pausingDriver) Pause() error {
+	return nil
+}
+
+func (d pausingDriver) Unpause() error {
+	return nil
+}
+
+func (d pausingDriver) Unsupported() []string {
+	return []string{CapabilityStart, CapabilityKill}
+}
+
+func TestCapabilities(t *testing.T) {
+	expected := []string{
+		CapabilityKill,
+		CapabilityRemove,
+		CapabilityRestart,
+		CapabilitySSH,
+		CapabilityStart,
+		CapabilityState,
+		CapabilityStop,
+	}
+	if capabilities := Capabilities(namedDriver{name: "plain"}); !reflect.DeepEqual(capabilities, expected) {
+		t.Fatalf("expected %v; received %v", expected, capabilities)
+	}
+
+	d := pausingDriver{namedDriver{name: "pausing"}}
+	expected = []string{
+		CapabilityPause,
+		CapabilityRemove,
+		CapabilityRestart,
+		CapabilitySSH,
+		CapabilityState,
+		CapabilityStop,
+	}
+	if capabilities := Capabilities(d); !reflect.DeepEqual(capabilities, expected) {
+		t.Fatalf("expected %v; received %v", expected, capabilities)
+	}
+	if Supports(d, CapabilityStart) {
+		t.Fatal("expected start not to be supported")
+	}
+}
+
+func TestNotSupported(t *testing.T) {
+	err := NotSupported(namedDriver{name: "foo"}, CapabilityStart)
+	if !IsNotSupported(err) {
+		t.Fatalf("expected ErrNotSupported; received %T", err)
+	}
+	if err.Error() != "The foo driver does not support start" {
+		t.Fatalf("unexpected message %q", err)
+	}
+	if IsNotSupported(ErrHostIsNotRunning) {
+		t.Fatal("expected other errors not to be ErrNotSupported")
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"io/ioutil"
 	"os/exec"
 	"path/filepath"
-	"time"
 
 	"code.google.com/p/goauth2/oauth"
 	log "github.com/Sirupsen/logrus"
@@ -15,10 +14,8 @@ import (
 	"github.com/docker/machine/drivers"
 	"github.com/docker/machine/ssh"
 	"github.com/docker/machine/state"
-)
-
-const (
-	dockerConfigDir = "/etc/docker"
+	"github.com/docker/machine/utils"
+	"golang.org/x/net/context"
 )
 
 type Driver struct {
@@ -35,6 +32,7 @@ type Driver struct {
 	PrivateKeyPath string
 	DriverKeyPath  string
 	storePath      string
+	userData       []byte
 }
 
 func init() {
@@ -82,6 +80,11 @@ func (d *Driver) DriverName() string {
 	return "digitalocean"
 }
 
+// SetUserData sets the user data passed to the droplet on creation.
+func (d *Driver) SetUserData(userData []byte) {
+	d.userData = userData
+}
+
 func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.AccessToken = flags.String("digitalocean-access-token")
 	d.Image = flags.String("digitalocean-image")
@@ -99,7 +102,7 @@ func (d *Driver) PreCreateCheck() error {
 	return nil
 }
 
-func (d *Driver) Create() error {
+func (d *Driver) Create(ctx context.Context) error {
 	log.Infof("Creating SSH key...")
 
 	key, err := d.createSSHKey()
@@ -114,11 +117,12 @@ func (d *Driver) Create() error {
 	client := d.getClient()
 
 	createRequest := &godo.DropletCreateRequest{
-		Image:   d.Image,
-		Name:    d.MachineName,
-		Region:  d.Region,
-		Size:    d.Size,
-		SSHKeys: []interface{}{d.SSHKeyID},
+		Image:    d.Image,
+		Name:     d.MachineName,
+		Region:   d.Region,
+		Size:     d.Size,
+		SSHKeys:  []interface{}{d.SSHKeyID},
+		UserData: string(d.userData),
 	}
 
 	newDroplet, _, err := client.Droplets.Create(createRequest)
@@ -128,7 +132,7 @@ func (d *Driver) Create() error {
 
 	d.DropletID = newDroplet.Droplet.ID
 
-	for {
+	if err := utils.WaitFor(ctx, fmt.Sprintf("an IP address for droplet %d", d.DropletID), utils.DefaultBackoff, func() error {
 		newDroplet, _, err = client.Droplets.Get(d.DropletID)
 		if err != nil {
 			return err
@@ -139,11 +143,12 @@ func (d *Driver) Create() error {
 			}
 		}
 
-		if d.IPAddress != "" {
-			break
+		if d.IPAddress == "" {
+			return fmt.Errorf("droplet %d has no IP address yet", d.DropletID)
 		}
-
-		time.Sleep(1 * time.Second)
+		return nil
+	}); err != nil {
+		return err
 	}
 
 	log.Debugf("Created droplet ID %d, IP address %s",
@@ -152,7 +157,7 @@ func (d *Driver) Create() error {
 
 	log.Infof("Waiting for SSH...")
 
-	if err := ssh.WaitForTCP(fmt.Sprintf("%s:%d", d.IPAddress, 22)); err != nil {
+	if err := ssh.WaitForTCP(ctx, fmt.Sprintf("%s:%d", d.IPAddress, 22)); err != nil {
 		return err
 	}
 
@@ -171,19 +176,99 @@ func (d *Driver) Create() error {
 		return err
 	}
 
-	log.Debugf("Installing Docker")
+	return nil
+}
+
+// Plan checks the access token, region, size and image against the Digital
+// Ocean API and returns what Create would make.
+func (d *Driver) Plan() ([]drivers.PlannedResource, error) {
+	client := d.getClient()
 
-	cmd, err = d.GetSSHCommand("if [ ! -e /usr/bin/docker ]; then curl -sL https://get.docker.com | sh -; fi")
+	regions, _, err := client.Regions.List(&godo.ListOptions{PerPage: 200})
 	if err != nil {
-		return err
+		return nil, err
+	}
 
+	var region *godo.Region
+	for i := range regions {
+		if regions[i].Slug == d.Region {
+			region = &regions[i]
+			break
+		}
+	}
+	if region == nil || !region.Available {
+		return nil, fmt.Errorf("Region %s is not available", d.Region)
 	}
-	if err := cmd.Run(); err != nil {
-		return err
 
+	hasSize := false
+	for _, size := range region.Sizes {
+		if size == d.Size {
+			hasSize = true
+			break
+		}
+	}
+	if !hasSize {
+		return nil, fmt.Errorf("Size %s is not available in region %s", d.Size, d.Region)
 	}
 
-	return nil
+	image, err := d.findImage()
+	if err != nil {
+		return nil, err
+	}
+	if image == nil {
+		return nil, fmt.Errorf("Image %s does not exist", d.Image)
+	}
+
+	hasRegion := false
+	for _, r := range image.Regions {
+		if r == d.Region {
+			hasRegion = true
+			break
+		}
+	}
+	if !hasRegion {
+		return nil, fmt.Errorf("Image %s is not available in region %s", d.Image, d.Region)
+	}
+
+	return []drivers.PlannedResource{
+		{Action: "create", Type: "SSH key", Name: d.MachineName},
+		{
+			Action: "create",
+			Type:   "droplet",
+			Name:   d.MachineName,
+			Detail: fmt.Sprintf("%s from %s in %s", d.Size, d.Image, d.Region),
+		},
+	}, nil
+}
+
+// findImage returns the image with the slug given by --digitalocean-image,
+// or nil if there is none.
+func (d *Driver) findImage() (*godo.Image, error) {
+	client := d.getClient()
+	opt := &godo.ListOptions{PerPage: 200}
+
+	for {
+		images, resp, err := client.Images.List(opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range images {
+			if images[i].Slug == d.Image {
+				return &images[i], nil
+			}
+		}
+
+		if resp.Links == nil || resp.Links.IsLastPage() {
+			return nil, nil
+		}
+
+		page, err := resp.Links.CurrentPage()
+		if err != nil {
+			return nil, err
+		}
+		opt.Page = page + 1
+	}
 }
 
 func (d *Driver) createSSHKey() (*godo.Key, error) {
@@ -224,33 +309,43 @@ func (d *Driver) GetIP() (string, error) {
 	return d.IPAddress, nil
 }
 
-func (d *Driver) GetState() (state.State, error) {
+func (d *Driver) GetProviderState() (string, error) {
 	droplet, _, err := d.getClient().Droplets.Get(d.DropletID)
+	if err != nil {
+		return "", err
+	}
+	return droplet.Droplet.Status, nil
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	status, err := d.GetProviderState()
 	if err != nil {
 		return state.Error, err
 	}
-	switch droplet.Droplet.Status {
+	switch status {
 	case "new":
 		return state.Starting, nil
 	case "active":
 		return state.Running, nil
 	case "off":
 		return state.Stopped, nil
+	case "archive":
+		return state.None, nil
 	}
-	return state.None, nil
+	return state.Unknown, nil
 }
 
-func (d *Driver) Start() error {
+func (d *Driver) Start(ctx context.Context) error {
 	_, _, err := d.getClient().DropletActions.PowerOn(d.DropletID)
 	return err
 }
 
-func (d *Driver) Stop() error {
+func (d *Driver) Stop(ctx context.Context) error {
 	_, _, err := d.getClient().DropletActions.Shutdown(d.DropletID)
 	return err
 }
 
-func (d *Driver) Remove() error {
+func (d *Driver) Remove(ctx context.Context) error {
 	client := d.getClient()
 	if resp, err := client.Keys.DeleteByID(d.SSHKeyID); err != nil {
 		if resp.StatusCode == 404 {
@@ -279,54 +374,6 @@ func (d *Driver) Kill() error {
 	return err
 }
 
-func (d *Driver) StartDocker() error {
-	log.Debug("Starting Docker...")
-
-	cmd, err := d.GetSSHCommand("sudo service docker start")
-	if err != nil {
-		return err
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (d *Driver) StopDocker() error {
-	log.Debug("Stopping Docker...")
-
-	cmd, err := d.GetSSHCommand("sudo service docker stop")
-	if err != nil {
-		return err
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (d *Driver) GetDockerConfigDir() string {
-	return dockerConfigDir
-}
-
-func (d *Driver) Upgrade() error {
-	log.Debugf("Upgrading Docker")
-
-	cmd, err := d.GetSSHCommand("sudo apt-get update && sudo apt-get install --upgrade lxc-docker")
-	if err != nil {
-		return err
-
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-
-	}
-
-	return cmd.Run()
-}
-
 func (d *Driver) GetSSHCommand(args ...string) (*exec.Cmd, error) {
 	return ssh.GetSSHCommand(d.IPAddress, 22, "root", d.sshKeyPath(), args...), nil
 }
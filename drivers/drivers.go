@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"os/exec"
 	"sort"
+	"sync"
 
+	log "github.com/Sirupsen/logrus"
 	"github.com/codegangsta/cli"
 	"github.com/docker/machine/state"
+	"golang.org/x/net/context"
 )
 
 // Driver defines how a host is created and controlled. Different types of
@@ -35,17 +38,19 @@ type Driver interface {
 	// PreCreate allows for pre-create operations to make sure a driver is ready for creation
 	PreCreateCheck() error
 
-	// Create a host using the driver's config
-	Create() error
+	// Create a host using the driver's config. When ctx is done, Create
+	// stops waiting for the provider and returns; whatever was created is
+	// then removed with Remove.
+	Create(ctx context.Context) error
 
 	// Remove a host
-	Remove() error
+	Remove(ctx context.Context) error
 
 	// Start a host
-	Start() error
+	Start(ctx context.Context) error
 
 	// Stop a host gracefully
-	Stop() error
+	Stop(ctx context.Context) error
 
 	// Restart a host. This may just call Stop(); Start() if the provider does not
 	// have any special restart behaviour.
@@ -54,22 +59,121 @@ type Driver interface {
 	// Kill stops a host forcefully
 	Kill() error
 
-	// RestartDocker restarts a Docker daemon on the machine
-	StartDocker() error
+	// GetSSHCommand returns a command for SSH pointing at the correct user, host
+	// and keys for the host with args appended. If no args are passed, it will
+	// initiate an interactive SSH session as if SSH were passed no args.
+	GetSSHCommand(args ...string) (*exec.Cmd, error)
+}
+
+// Adopter is implemented by drivers which are able to take over a host that
+// was created outside of machine, e.g. an existing cloud instance.
+type Adopter interface {
+	// Adopt configures the driver from the flags passed to "machine adopt"
+	// and looks up the existing host on the provider. Once it returns, the
+	// host must be reachable over SSH. The machine directory does not exist
+	// when Adopt is called: the flags should be checked before the driver
+	// creates it to store files such as the SSH key. When ctx is done, Adopt
+	// stops waiting for the host and returns.
+	Adopt(ctx context.Context, flags DriverOptions) error
+}
 
-	// RestartDocker restarts a Docker daemon on the machine
-	StopDocker() error
+// AdoptFlags are the flags "machine adopt" adds to the create flags of the
+// drivers, for Adopt to find the existing host with.
+var AdoptFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "instance-id",
+		Usage: "Provider ID of the existing instance",
+		Value: "",
+	},
+	cli.StringFlag{
+		Name:  "ssh-user",
+		Usage: "SSH user for the existing instance",
+		Value: "",
+	},
+	cli.StringFlag{
+		Name:  "ssh-key",
+		Usage: "SSH private key for the existing instance",
+		Value: "",
+	},
+}
 
-	// Upgrade the version of Docker on the host to the latest version
+// Upgrader is implemented by drivers which upgrade Docker by replacing the
+// image the host boots from, e.g. the boot2docker ISO. Docker on other hosts
+// is upgraded by the provisioner.
+type Upgrader interface {
+	// Upgrade the host to the latest version of its image
 	Upgrade() error
+}
 
-	// GetDockerConfigDir returns the config directory for storing daemon configs
-	GetDockerConfigDir() string
+// Labeler is implemented by drivers which can attach the labels given with
+// "machine create --label" to the host on the provider, e.g. as tags.
+type Labeler interface {
+	// SetLabels is called before Create with the labels of the host.
+	SetLabels(labels map[string]string)
+}
 
-	// GetSSHCommand returns a command for SSH pointing at the correct user, host
-	// and keys for the host with args appended. If no args are passed, it will
-	// initiate an interactive SSH session as if SSH were passed no args.
-	GetSSHCommand(args ...string) (*exec.Cmd, error)
+// UserDataSetter is implemented by drivers which can pass the file given
+// with "machine create --user-data" to the provider, e.g. for cloud-init.
+type UserDataSetter interface {
+	// SetUserData is called before Create with the contents of the file.
+	SetUserData(userData []byte)
+}
+
+// Pauser is implemented by drivers which can suspend a running host without
+// shutting it down, for "machine pause".
+type Pauser interface {
+	// Pause suspends the host
+	Pause() error
+
+	// Unpause resumes a host suspended by Pause
+	Unpause() error
+}
+
+// Resizer is implemented by drivers which can change the resources of an
+// existing host, for "machine resize".
+type Resizer interface {
+	// Resize gives the host cpus CPUs and memory MB of memory. A value of 0
+	// leaves the resource as it is.
+	Resize(cpus int, memory int) error
+}
+
+// Snapshotter is implemented by drivers which can save the disks of a host
+// and roll them back later, for "machine snapshot".
+type Snapshotter interface {
+	// Snapshot saves the current state of the host as name
+	Snapshot(name string) error
+
+	// RestoreSnapshot rolls the host back to the snapshot name
+	RestoreSnapshot(name string) error
+}
+
+// ProviderStater is implemented by drivers which can tell the state of a
+// host as their provider reports it, for "machine status".
+type ProviderStater interface {
+	// GetProviderState returns the state of the host in the terms of the
+	// provider, e.g. "pending" or "poweroff", which GetState maps to a
+	// state.State.
+	GetProviderState() (string, error)
+}
+
+// Planner is implemented by drivers which can check their configuration
+// against the provider and report what Create would make, for "machine
+// create --dry-run". Plan must not create or change anything.
+type Planner interface {
+	// Plan is called after PreCreateCheck and returns the provider
+	// resources Create would create or use.
+	Plan() ([]PlannedResource, error)
+}
+
+// PlannedResource is a provider resource reported by Plan, e.g. a key pair
+// Create would import or an existing network it would attach the host to.
+type PlannedResource struct {
+	// Action is "create" for resources Create would make and "use" for
+	// existing ones.
+	Action string
+	Type   string
+	Name   string
+	Detail string
 }
 
 // RegisteredDriver is used to register a driver with the Register function.
@@ -83,14 +187,19 @@ type RegisteredDriver struct {
 	GetCreateFlags func() []cli.Flag
 }
 
-var ErrHostIsNotRunning = errors.New("host is not running")
+var (
+	ErrHostIsNotRunning  = errors.New("host is not running")
+	ErrAdoptNotSupported = errors.New("driver does not support adopting existing hosts")
+)
 
 var (
 	drivers map[string]*RegisteredDriver
+	plugins map[string]*RegisteredDriver
 )
 
 func init() {
 	drivers = make(map[string]*RegisteredDriver)
+	plugins = make(map[string]*RegisteredDriver)
 }
 
 // Register a driver
@@ -103,13 +212,64 @@ func Register(name string, registeredDriver *RegisteredDriver) error {
 	return nil
 }
 
-// NewDriver creates a new driver of type "name"
+// RegisterPlugin registers a driver served by a plugin. A driver registered
+// with Register under the same name takes precedence, whichever of them is
+// registered first.
+func RegisterPlugin(name string, registeredDriver *RegisteredDriver) error {
+	if _, exists := plugins[name]; exists {
+		return fmt.Errorf("Name already registered %s", name)
+	}
+
+	plugins[name] = registeredDriver
+	return nil
+}
+
+// registered returns the drivers and the plugins which are not shadowed by
+// a driver, by name.
+func registered() map[string]*RegisteredDriver {
+	all := make(map[string]*RegisteredDriver, len(drivers)+len(plugins))
+	for name, driver := range plugins {
+		all[name] = driver
+	}
+	for name, driver := range drivers {
+		all[name] = driver
+	}
+	return all
+}
+
+// NewDriver creates a new driver of type "name" for the machine machineName
 func NewDriver(name string, machineName string, storePath string, caCert string, privateKey string) (Driver, error) {
-	driver, exists := drivers[name]
+	driver, exists := registered()[name]
 	if !exists {
 		return nil, fmt.Errorf("hosts: Unknown driver %q", name)
 	}
-	return driver.New(machineName, storePath, caCert, privateKey)
+	d, err := driver.New(machineName, storePath, caCert, privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	loggersMu.Lock()
+	loggers[d] = log.WithField("machine", machineName)
+	loggersMu.Unlock()
+	return d, nil
+}
+
+var (
+	loggersMu sync.Mutex
+	loggers   = map[Driver]*log.Entry{}
+)
+
+// Logger returns the logger to log about the host of d with. Its entries
+// have the name of the machine in their "machine" field, so that they only
+// end up in the operation logs of that machine when several run at once.
+// Drivers which were not created by NewDriver get the standard logger.
+func Logger(d Driver) *log.Entry {
+	loggersMu.Lock()
+	defer loggersMu.Unlock()
+	if logger, ok := loggers[d]; ok {
+		return logger
+	}
+	return log.WithFields(log.Fields{})
 }
 
 // GetCreateFlags runs GetCreateFlags for all of the drivers and
@@ -117,8 +277,7 @@ func NewDriver(name string, machineName string, storePath string, caCert string,
 func GetCreateFlags() []cli.Flag {
 	flags := []cli.Flag{}
 
-	for driverName := range drivers {
-		driver := drivers[driverName]
+	for _, driver := range registered() {
 		for _, f := range driver.GetCreateFlags() {
 			flags = append(flags, f)
 		}
@@ -129,10 +288,21 @@ func GetCreateFlags() []cli.Flag {
 	return flags
 }
 
+// GetDriverCreateFlags returns the flags the driver "name" adds to "machine
+// create"
+func GetDriverCreateFlags(name string) ([]cli.Flag, error) {
+	driver, exists := registered()[name]
+	if !exists {
+		return nil, fmt.Errorf("hosts: Unknown driver %q", name)
+	}
+	return driver.GetCreateFlags(), nil
+}
+
 // GetDriverNames returns a slice of all registered driver names
 func GetDriverNames() []string {
-	names := make([]string, 0, len(drivers))
-	for k := range drivers {
+	all := registered()
+	names := make([]string, 0, len(all))
+	for k := range all {
 		names = append(names, k)
 	}
 	sort.Strings(names)
@@ -143,4 +313,5 @@ type DriverOptions interface {
 	String(key string) string
 	Int(key string) int
 	Bool(key string) bool
+	StringSlice(key string) []string
 }
@@ -75,3 +75,59 @@ func TestGetCreateFlags(t *testing.T) {
 		}
 	}
 }
+
+type namedDriver struct {
+	Driver
+	name string
+}
+
+func (d namedDriver) DriverName() string {
+	return d.name
+}
+
+func TestRegisterPlugin(t *testing.T) {
+	newRegistered := func(name string) *RegisteredDriver {
+		return &RegisteredDriver{
+			New: func(machineName string, storePath string, caCert string, privateKey string) (Driver, error) {
+				return namedDriver{name: name}, nil
+			},
+			GetCreateFlags: func() []cli.Flag {
+				return nil
+			},
+		}
+	}
+	defer func() {
+		delete(drivers, "shadowed")
+		delete(plugins, "shadowed")
+		delete(plugins, "plugin")
+	}()
+
+	if err := RegisterPlugin("shadowed", newRegistered("plugin")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Register("shadowed", newRegistered("builtin")); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterPlugin("plugin", newRegistered("plugin")); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterPlugin("plugin", newRegistered("plugin")); err == nil {
+		t.Fatal("expected registering a plugin twice to fail")
+	}
+
+	d, err := NewDriver("shadowed", "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.DriverName() != "builtin" {
+		t.Fatalf("expected the built-in driver to take precedence, got %s", d.DriverName())
+	}
+
+	d, err = NewDriver("plugin", "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.DriverName() != "plugin" {
+		t.Fatalf("expected the plugin driver, got %s", d.DriverName())
+	}
+}
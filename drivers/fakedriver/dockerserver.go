@@ -0,0 +1,102 @@
+package fakedriver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+)
+
+// DockerServer is a Docker daemon on localhost which answers /_ping and
+// /version over TLS. Like a real daemon on a host provisioned by machine,
+// it uses the certificates machine wrote to CertDir on the SSHServer of the
+// host, and refuses connections until they are there.
+type DockerServer struct {
+	Port int
+
+	// CertDir is where machine writes the certificates of the daemon on
+	// the host, e.g. /var/lib/boot2docker.
+	CertDir string
+
+	// Version is the version of Docker reported by /version.
+	Version string
+
+	sshServer *SSHServer
+	listener  net.Listener
+}
+
+// NewDockerServer starts a Docker daemon on a free port of localhost for
+// the host simulated by sshServer.
+func NewDockerServer(sshServer *SSHServer) (*DockerServer, error) {
+	s := &DockerServer{
+		CertDir:   "/var/lib/boot2docker",
+		Version:   "1.6.0",
+		sshServer: sshServer,
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s.Port = listener.Addr().(*net.TCPAddr).Port
+	s.listener = tls.NewListener(listener, &tls.Config{
+		GetConfigForClient: s.tlsConfig,
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/_ping", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "OK")
+	})
+	mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"Version":    s.Version,
+			"ApiVersion": "1.18",
+			"Os":         "linux",
+			"Arch":       "amd64",
+		})
+	})
+
+	go http.Serve(s.listener, mux)
+
+	return s, nil
+}
+
+// Close stops the server.
+func (s *DockerServer) Close() error {
+	return s.listener.Close()
+}
+
+// tlsConfig reads the certificates of the daemon from the host for each
+// connection, as they change whenever the host is provisioned.
+func (s *DockerServer) tlsConfig(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+	caCert, ok := s.sshServer.File(path.Join(s.CertDir, "ca.pem"))
+	if !ok {
+		return nil, fmt.Errorf("no CA on the host")
+	}
+	serverCert, ok := s.sshServer.File(path.Join(s.CertDir, "server.pem"))
+	if !ok {
+		return nil, fmt.Errorf("no server certificate on the host")
+	}
+	serverKey, ok := s.sshServer.File(path.Join(s.CertDir, "server-key.pem"))
+	if !ok {
+		return nil, fmt.Errorf("no server key on the host")
+	}
+
+	keyPair, err := tls.X509KeyPair([]byte(serverCert), []byte(serverKey))
+	if err != nil {
+		return nil, err
+	}
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM([]byte(caCert)) {
+		return nil, fmt.Errorf("unable to parse the CA on the host")
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{keyPair},
+		ClientCAs:    certPool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}, nil
+}
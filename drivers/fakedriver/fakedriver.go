@@ -0,0 +1,405 @@
+// Package fakedriver provides a driver which simulates a host, for testing
+// machine without a hypervisor or a cloud account. It is not built into
+// machine; tests register it as the "fake" driver by importing the package.
+//
+// The simulated host runs the SSH and Docker servers of this package, which
+// the driver points machine at, so everything machine does on a host can be
+// run against it.
+package fakedriver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/docker/machine/drivers"
+	"github.com/docker/machine/ssh"
+	"github.com/docker/machine/state"
+	"golang.org/x/net/context"
+)
+
+// Names of the operations of the driver, which can be made to fail with
+// FailOn. Those which act on the host are recorded in its calls.
+const (
+	OpPreCreateCheck = "pre-create-check"
+	OpCreate         = "create"
+	OpAdopt          = "adopt"
+	OpRemove         = "remove"
+	OpStart          = "start"
+	OpStop           = "stop"
+	OpRestart        = "restart"
+	OpKill           = "kill"
+	OpPause          = "pause"
+	OpUnpause        = "unpause"
+	OpGetState       = "state"
+	OpGetIP          = "ip"
+	OpGetURL         = "url"
+)
+
+// stateFile is where the simulated provider keeps the host, in the machine
+// directory.
+const stateFile = "fakedriver.json"
+
+// Driver is a driver for a simulated host. The host is created running at
+// Address and loses its IP address while it is stopped.
+type Driver struct {
+	MachineName string
+	Address     string
+	SSHUser     string
+	SSHPort     int
+	DockerPort  int
+
+	// Password stands for the credentials of a provider account. It is
+	// logged on create, as drivers are wont to, to test that secrets are
+	// redacted.
+	Password string
+
+	// FailOn lists the operations which fail with a FailureError.
+	FailOn []string
+
+	// Latency is how long each operation takes.
+	Latency time.Duration
+
+	storePath string
+}
+
+// FailureError is returned by the operations listed in FailOn.
+type FailureError struct {
+	Op string
+}
+
+func (e *FailureError) Error() string {
+	return fmt.Sprintf("fake %s failure", e.Op)
+}
+
+// fakeHost is the host as the simulated provider knows it.
+type fakeHost struct {
+	State     state.State
+	IPAddress string
+	Calls     []string
+}
+
+func init() {
+	drivers.Register("fake", &drivers.RegisteredDriver{
+		New:            NewDriver,
+		GetCreateFlags: GetCreateFlags,
+	})
+}
+
+// GetCreateFlags registers the flags this driver adds to
+// "docker hosts create"
+func GetCreateFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "fake-address",
+			Usage: "IP address the host gets when it is running",
+			Value: "127.0.0.1",
+		},
+		cli.StringFlag{
+			Name:  "fake-ssh-user",
+			Usage: "SSH user",
+			Value: "docker",
+		},
+		cli.IntFlag{
+			Name:  "fake-ssh-port",
+			Usage: "Port of the SSH server of the host",
+			Value: 22,
+		},
+		cli.IntFlag{
+			Name:  "fake-docker-port",
+			Usage: "Port of the Docker server of the host",
+			Value: 2376,
+		},
+		cli.StringSliceFlag{
+			Name:  "fake-fail-on",
+			Usage: "Operation of the driver which fails, e.g. create or start",
+			Value: &cli.StringSlice{},
+		},
+		cli.IntFlag{
+			Name:  "fake-latency",
+			Usage: "Milliseconds each operation of the driver takes",
+		},
+		cli.StringFlag{
+			Name:  "fake-password",
+			Usage: "Password of the simulated provider account",
+		},
+	}
+}
+
+func NewDriver(machineName string, storePath string, caCert string, privateKey string) (drivers.Driver, error) {
+	return &Driver{MachineName: machineName, storePath: storePath}, nil
+}
+
+func (d *Driver) DriverName() string {
+	return "fake"
+}
+
+func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.Address = flags.String("fake-address")
+	d.SSHUser = flags.String("fake-ssh-user")
+	d.SSHPort = flags.Int("fake-ssh-port")
+	d.DockerPort = flags.Int("fake-docker-port")
+	d.FailOn = flags.StringSlice("fake-fail-on")
+	d.Latency = time.Duration(flags.Int("fake-latency")) * time.Millisecond
+	d.Password = flags.String("fake-password")
+
+	if d.Address == "" {
+		return fmt.Errorf("fake driver requires the --fake-address option")
+	}
+
+	return nil
+}
+
+// Calls returns the operations run on the host so far, oldest first.
+func (d *Driver) Calls() ([]string, error) {
+	host, err := d.loadHost()
+	if err != nil {
+		return nil, err
+	}
+	return host.Calls, nil
+}
+
+// run waits for the latency of the driver and fails op if it is in FailOn.
+// It gives up waiting when ctx is done.
+func (d *Driver) run(ctx context.Context, op string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d.Latency):
+	}
+	for _, f := range d.FailOn {
+		if f == op {
+			return &FailureError{Op: op}
+		}
+	}
+	return nil
+}
+
+// update runs op on the host, which is changed by fn and recorded.
+func (d *Driver) update(ctx context.Context, op string, fn func(h *fakeHost) error) error {
+	host, err := d.loadHost()
+	if err != nil {
+		return err
+	}
+	host.Calls = append(host.Calls, op)
+
+	err = d.run(ctx, op)
+	if err == nil {
+		err = fn(host)
+	}
+
+	if saveErr := d.saveHost(host); saveErr != nil {
+		return saveErr
+	}
+	return err
+}
+
+func (d *Driver) loadHost() (*fakeHost, error) {
+	host := &fakeHost{}
+	data, err := ioutil.ReadFile(filepath.Join(d.storePath, stateFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return host, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, host); err != nil {
+		return nil, err
+	}
+	return host, nil
+}
+
+func (d *Driver) saveHost(host *fakeHost) error {
+	data, err := json.Marshal(host)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(d.storePath, stateFile), data, 0600)
+}
+
+func (d *Driver) GetURL() (string, error) {
+	ip, err := d.getIP(OpGetURL)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("tcp://%s:%d", ip, d.DockerPort), nil
+}
+
+func (d *Driver) GetIP() (string, error) {
+	return d.getIP(OpGetIP)
+}
+
+func (d *Driver) getIP(op string) (string, error) {
+	if err := d.run(context.Background(), op); err != nil {
+		return "", err
+	}
+	host, err := d.loadHost()
+	if err != nil {
+		return "", err
+	}
+	if host.IPAddress == "" {
+		return "", drivers.ErrHostIsNotRunning
+	}
+	return host.IPAddress, nil
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	if err := d.run(context.Background(), OpGetState); err != nil {
+		return state.Error, err
+	}
+	host, err := d.loadHost()
+	if err != nil {
+		return state.Error, err
+	}
+	return host.State, nil
+}
+
+// GetProviderState returns the state of the host in lower case, as a
+// provider would, or "not-found" once it is removed.
+func (d *Driver) GetProviderState() (string, error) {
+	st, err := d.GetState()
+	if err != nil {
+		return "", err
+	}
+	if st == state.None {
+		return "not-found", nil
+	}
+	return strings.ToLower(st.String()), nil
+}
+
+// PreCreateCheck is not recorded, as it runs before the machine directory
+// exists.
+func (d *Driver) PreCreateCheck() error {
+	return d.run(context.Background(), OpPreCreateCheck)
+}
+
+func (d *Driver) Create(ctx context.Context) error {
+	drivers.Logger(d).Debugf("Creating fake host with %+v", *d)
+	return d.update(ctx, OpCreate, func(h *fakeHost) error {
+		if h.State != state.None {
+			return fmt.Errorf("host %s already exists", d.MachineName)
+		}
+		if err := ssh.GenerateSSHKey(d.sshKeyPath()); err != nil {
+			return err
+		}
+		h.State = state.Running
+		h.IPAddress = d.Address
+		return nil
+	})
+}
+
+// Adopt takes over the simulated host given by --instance-id, which is
+// running at Address. The flags are checked before anything is written to
+// the machine directory, as a real provider would be asked first.
+func (d *Driver) Adopt(ctx context.Context, flags drivers.DriverOptions) error {
+	if err := d.SetConfigFromFlags(flags); err != nil {
+		return err
+	}
+	if flags.String("instance-id") == "" {
+		return fmt.Errorf("fake driver requires the --instance-id option to adopt a host")
+	}
+
+	if err := os.MkdirAll(d.storePath, 0700); err != nil {
+		return err
+	}
+	return d.update(ctx, OpAdopt, func(h *fakeHost) error {
+		if err := ssh.GenerateSSHKey(d.sshKeyPath()); err != nil {
+			return err
+		}
+		h.State = state.Running
+		h.IPAddress = d.Address
+		return nil
+	})
+}
+
+func (d *Driver) Remove(ctx context.Context) error {
+	return d.update(ctx, OpRemove, func(h *fakeHost) error {
+		h.State = state.None
+		h.IPAddress = ""
+		return nil
+	})
+}
+
+func (d *Driver) Start(ctx context.Context) error {
+	return d.update(ctx, OpStart, func(h *fakeHost) error {
+		switch h.State {
+		case state.None:
+			return fmt.Errorf("host %s does not exist", d.MachineName)
+		case state.Running:
+			return fmt.Errorf("host %s is already running", d.MachineName)
+		}
+		h.State = state.Running
+		h.IPAddress = d.Address
+		return nil
+	})
+}
+
+func (d *Driver) Stop(ctx context.Context) error {
+	return d.update(ctx, OpStop, d.stop)
+}
+
+func (d *Driver) Restart() error {
+	return d.update(context.Background(), OpRestart, func(h *fakeHost) error {
+		if h.State != state.Running {
+			return drivers.ErrHostIsNotRunning
+		}
+		return nil
+	})
+}
+
+func (d *Driver) Kill() error {
+	return d.update(context.Background(), OpKill, d.stop)
+}
+
+// Pause suspends the host, which keeps its IP address.
+func (d *Driver) Pause() error {
+	return d.update(context.Background(), OpPause, func(h *fakeHost) error {
+		if h.State != state.Running {
+			return drivers.ErrHostIsNotRunning
+		}
+		h.State = state.Paused
+		return nil
+	})
+}
+
+func (d *Driver) Unpause() error {
+	return d.update(context.Background(), OpUnpause, func(h *fakeHost) error {
+		if h.State != state.Paused {
+			return fmt.Errorf("host %s is not paused", d.MachineName)
+		}
+		h.State = state.Running
+		return nil
+	})
+}
+
+func (d *Driver) stop(h *fakeHost) error {
+	if h.State != state.Running {
+		return drivers.ErrHostIsNotRunning
+	}
+	h.State = state.Stopped
+	h.IPAddress = ""
+	return nil
+}
+
+func (d *Driver) GetSSHCommand(args ...string) (*exec.Cmd, error) {
+	host, err := d.loadHost()
+	if err != nil {
+		return nil, err
+	}
+	if host.State != state.Running {
+		return nil, drivers.ErrHostIsNotRunning
+	}
+	return ssh.GetSSHCommand(host.IPAddress, d.SSHPort, d.SSHUser, d.sshKeyPath(), args...), nil
+}
+
+// sshKeyPath is the key machine uses for the host, although the SSH server
+// of this package lets anyone in.
+func (d *Driver) sshKeyPath() string {
+	return filepath.Join(d.storePath, "id_rsa")
+}
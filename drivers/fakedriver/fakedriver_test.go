@@ -0,0 +1,208 @@
+package fakedriver
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/drivers"
+	"github.com/docker/machine/state"
+	"golang.org/x/net/context"
+)
+
+func newTestDriver(t *testing.T) (*Driver, func()) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &Driver{
+		MachineName: "test",
+		Address:     "127.0.0.1",
+		SSHUser:     "docker",
+		DockerPort:  2376,
+		storePath:   tmpDir,
+	}
+	return d, func() { os.RemoveAll(tmpDir) }
+}
+
+func TestDriverLifecycle(t *testing.T) {
+	d, cleanup := newTestDriver(t)
+	defer cleanup()
+
+	if err := d.Start(context.Background()); err == nil {
+		t.Fatal("expected starting a host which does not exist to fail")
+	}
+
+	if err := d.Create(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := d.GetState(); st != state.Running {
+		t.Fatalf("expected state Running; received %s", st)
+	}
+	url, err := d.GetURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "tcp://127.0.0.1:2376" {
+		t.Fatalf("unexpected URL %s", url)
+	}
+
+	if err := d.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := d.GetState(); st != state.Stopped {
+		t.Fatalf("expected state Stopped; received %s", st)
+	}
+	if _, err := d.GetIP(); err != drivers.ErrHostIsNotRunning {
+		t.Fatalf("expected ErrHostIsNotRunning; received %v", err)
+	}
+	if _, err := d.GetSSHCommand("uptime"); err != drivers.ErrHostIsNotRunning {
+		t.Fatalf("expected ErrHostIsNotRunning; received %v", err)
+	}
+	if err := d.Kill(); err != drivers.ErrHostIsNotRunning {
+		t.Fatalf("expected ErrHostIsNotRunning; received %v", err)
+	}
+
+	if err := d.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Remove(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := d.GetState(); st != state.None {
+		t.Fatalf("expected state None; received %s", st)
+	}
+
+	calls, err := d.Calls()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{OpStart, OpCreate, OpStop, OpKill, OpStart, OpRestart, OpRemove}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("expected calls %v; received %v", expected, calls)
+	}
+}
+
+func TestDriverFailOn(t *testing.T) {
+	d, cleanup := newTestDriver(t)
+	defer cleanup()
+
+	d.FailOn = []string{OpCreate, OpGetState}
+	d.Latency = 10 * time.Millisecond
+
+	start := time.Now()
+	err := d.Create(context.Background())
+	if _, ok := err.(*FailureError); !ok {
+		t.Fatalf("expected a FailureError; received %v", err)
+	}
+	if time.Since(start) < d.Latency {
+		t.Fatal("expected create to take the latency of the driver")
+	}
+	if _, err := d.GetState(); err == nil {
+		t.Fatal("expected getting the state to fail")
+	}
+
+	d.FailOn = nil
+	if st, _ := d.GetState(); st != state.None {
+		t.Fatalf("expected the failed create to leave state None; received %s", st)
+	}
+	if calls, _ := d.Calls(); !reflect.DeepEqual(calls, []string{OpCreate}) {
+		t.Fatalf("expected the failed create to be recorded; received %v", calls)
+	}
+}
+
+func TestDriverCancel(t *testing.T) {
+	d, cleanup := newTestDriver(t)
+	defer cleanup()
+
+	d.Latency = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := d.Create(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected create to give up at the deadline; received %v", err)
+	}
+
+	d.Latency = 0
+	if st, _ := d.GetState(); st != state.None {
+		t.Fatalf("expected the cancelled create to leave state None; received %s", st)
+	}
+}
+
+func TestSSHServerRun(t *testing.T) {
+	s := &SSHServer{files: map[string]string{}}
+	s.Handle("cat /etc/os-release", Boot2DockerOSRelease, 0)
+	s.Handle("docker start", "failed", 1)
+
+	if output, _ := s.run("cat /etc/os-release", nil); output != Boot2DockerOSRelease {
+		t.Fatalf("unexpected os-release %q", output)
+	}
+	if output, status := s.run("sudo /etc/init.d/docker start", nil); output != "failed" || status != 1 {
+		t.Fatalf("expected the handled response; received %q, %d", output, status)
+	}
+
+	s.run("echo \"line 1\nline 2\" | sudo tee /etc/foo", nil)
+	s.run("echo \"line 3\" | sudo tee -a /etc/foo", nil)
+	if contents, _ := s.File("/etc/foo"); contents != "line 1\nline 2\nline 3\n" {
+		t.Fatalf("unexpected contents %q", contents)
+	}
+
+	s.run(`sudo mkdir -p "/etc" && sudo tee "/etc/bar" >/dev/null`, strings.NewReader("$(reboot)\n"))
+	if contents, _ := s.File("/etc/bar"); contents != "$(reboot)\n" {
+		t.Fatalf("unexpected contents %q", contents)
+	}
+
+	if _, status := s.run("true", nil); status != 0 {
+		t.Fatalf("expected other commands to succeed; received %d", status)
+	}
+	if commands := s.Commands(); len(commands) != 6 {
+		t.Fatalf("expected 6 commands; received %v", commands)
+	}
+}
+
+func TestSSHServer(t *testing.T) {
+	if _, err := exec.LookPath("ssh"); err != nil {
+		t.Skip("ssh is not installed")
+	}
+
+	s, err := NewSSHServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Handle("exit 3", "", 3)
+
+	d, cleanup := newTestDriver(t)
+	defer cleanup()
+	d.SSHPort = s.Port
+	if err := d.Create(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd, err := d.GetSSHCommand("cat /etc/os-release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != Boot2DockerOSRelease {
+		t.Fatalf("unexpected os-release %q", output)
+	}
+
+	cmd, err = d.GetSSHCommand("exit 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Run(); err == nil {
+		t.Fatal("expected the command to fail")
+	}
+}
@@ -0,0 +1,228 @@
+package fakedriver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	cssh "golang.org/x/crypto/ssh"
+)
+
+// Boot2DockerOSRelease is the /etc/os-release of the hosts simulated by
+// SSHServer unless told otherwise, so they are provisioned as boot2docker.
+const Boot2DockerOSRelease = `NAME=Boot2Docker
+VERSION=1.6.0
+ID=boot2docker
+ID_LIKE=tcl
+VERSION_ID=1.6.0
+PRETTY_NAME="Boot2Docker 1.6.0 (TCL 5.4); master : a270c71 - Thu Apr 16 19:50:36 UTC 2015"
+`
+
+// teeCommand and stdinTeeCommand match the commands machine writes files on
+// hosts with, from an echo or from standard input.
+var (
+	teeCommand      = regexp.MustCompile(`(?s)echo "(.*)" \| sudo tee (-a )?(\S+)$`)
+	stdinTeeCommand = regexp.MustCompile(`sudo tee (-a )?"(\S+)" >/dev/null$`)
+)
+
+// SSHServer is an SSH server on localhost which simulates the shell of a
+// host. It lets anyone in, records the commands it is asked to run and
+// keeps the files written with tee, but does not run anything.
+type SSHServer struct {
+	Port int
+
+	listener net.Listener
+	config   *cssh.ServerConfig
+
+	mu        sync.Mutex
+	responses []response
+	commands  []string
+	files     map[string]string
+}
+
+// response is the output and exit status of the commands containing
+// command.
+type response struct {
+	command    string
+	output     string
+	exitStatus int
+}
+
+// NewSSHServer starts an SSH server on a free port of localhost.
+func NewSSHServer() (*SSHServer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := cssh.NewSignerFromKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &cssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SSHServer{
+		Port:     listener.Addr().(*net.TCPAddr).Port,
+		listener: listener,
+		config:   config,
+		files:    map[string]string{},
+	}
+	s.Handle("cat /etc/os-release", Boot2DockerOSRelease, 0)
+
+	go s.serve()
+
+	return s, nil
+}
+
+// Close stops the server.
+func (s *SSHServer) Close() error {
+	return s.listener.Close()
+}
+
+// Handle makes the commands which contain command print output and exit
+// with exitStatus. The last matching call to Handle wins.
+func (s *SSHServer) Handle(command string, output string, exitStatus int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responses = append(s.responses, response{command, output, exitStatus})
+}
+
+// Commands returns the commands run on the server so far, oldest first.
+func (s *SSHServer) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.commands...)
+}
+
+// File returns the contents of the file written to path with tee.
+func (s *SSHServer) File(path string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	contents, ok := s.files[path]
+	return contents, ok
+}
+
+// run simulates running command on the host, which is given stdin.
+func (s *SSHServer) run(command string, stdin io.Reader) (string, int) {
+	var input []byte
+	if stdin != nil && stdinTeeCommand.MatchString(command) {
+		input, _ = ioutil.ReadAll(stdin)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.commands = append(s.commands, command)
+
+	for i := len(s.responses) - 1; i >= 0; i-- {
+		if r := s.responses[i]; strings.Contains(command, r.command) {
+			return r.output, r.exitStatus
+		}
+	}
+
+	if m := teeCommand.FindStringSubmatch(command); m != nil {
+		contents := m[1] + "\n"
+		if m[2] != "" {
+			contents = s.files[m[3]] + contents
+		}
+		s.files[m[3]] = contents
+		return m[1] + "\n", 0
+	}
+
+	if m := stdinTeeCommand.FindStringSubmatch(command); m != nil {
+		contents := string(input)
+		if m[1] != "" {
+			contents = s.files[m[2]] + contents
+		}
+		s.files[m[2]] = contents
+		return "", 0
+	}
+
+	// The service manager detection of the provisioners
+	if strings.Contains(command, "initctl") {
+		return "sysvinit\n", 0
+	}
+
+	return "", 0
+}
+
+func (s *SSHServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *SSHServer) serveConn(conn net.Conn) {
+	_, channels, requests, err := cssh.NewServerConn(conn, s.config)
+	if err != nil {
+		log.Debugf("fake SSH server: handshake failed: %s", err)
+		return
+	}
+	go cssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(cssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			log.Debugf("fake SSH server: %s", err)
+			continue
+		}
+		go s.serveSession(channel, requests)
+	}
+}
+
+// serveSession runs the command of an exec request. Other requests, e.g.
+// for an interactive shell, are refused.
+func (s *SSHServer) serveSession(channel cssh.Channel, requests <-chan *cssh.Request) {
+	defer channel.Close()
+
+	for req := range requests {
+		if req.Type != "exec" {
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+			continue
+		}
+
+		var exec struct {
+			Command string
+		}
+		if err := cssh.Unmarshal(req.Payload, &exec); err != nil {
+			req.Reply(false, nil)
+			continue
+		}
+		req.Reply(true, nil)
+
+		output, exitStatus := s.run(exec.Command, channel)
+		io.WriteString(channel, output)
+
+		status := struct {
+			Status uint32
+		}{uint32(exitStatus)}
+		channel.SendRequest("exit-status", false, cssh.Marshal(&status))
+		return
+	}
+}
@@ -0,0 +1,211 @@
+package generic
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/codegangsta/cli"
+	"github.com/docker/machine/drivers"
+	"github.com/docker/machine/ssh"
+	"github.com/docker/machine/state"
+	"github.com/docker/machine/utils"
+	"golang.org/x/net/context"
+)
+
+const (
+	dockerPort = 2376
+)
+
+// Driver is the driver used to manage an existing Linux host over SSH. It
+// does not create any infrastructure; Docker is installed and configured on
+// the host it is pointed at by the provisioner.
+type Driver struct {
+	MachineName    string
+	IPAddress      string
+	SSHUser        string
+	SSHPort        int
+	SSHKey         string
+	CaCertPath     string
+	PrivateKeyPath string
+	storePath      string
+}
+
+func init() {
+	drivers.Register("generic", &drivers.RegisteredDriver{
+		New:            NewDriver,
+		GetCreateFlags: GetCreateFlags,
+	})
+}
+
+// GetCreateFlags registers the flags this driver adds to
+// "docker hosts create"
+func GetCreateFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "generic-ip-address",
+			Usage: "IP Address of machine",
+			Value: "",
+		},
+		cli.StringFlag{
+			Name:  "generic-ssh-user",
+			Usage: "SSH user",
+			Value: "root",
+		},
+		cli.StringFlag{
+			Name:  "generic-ssh-key",
+			Usage: "SSH private key path",
+			Value: filepath.Join(utils.GetHomeDir(), ".ssh", "id_rsa"),
+		},
+		cli.IntFlag{
+			Name:  "generic-ssh-port",
+			Usage: "SSH port",
+			Value: 22,
+		},
+	}
+}
+
+func NewDriver(machineName string, storePath string, caCert string, privateKey string) (drivers.Driver, error) {
+	return &Driver{MachineName: machineName, storePath: storePath, CaCertPath: caCert, PrivateKeyPath: privateKey}, nil
+}
+
+func (d *Driver) DriverName() string {
+	return "generic"
+}
+
+func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.IPAddress = flags.String("generic-ip-address")
+	d.SSHUser = flags.String("generic-ssh-user")
+	d.SSHKey = flags.String("generic-ssh-key")
+	d.SSHPort = flags.Int("generic-ssh-port")
+
+	if d.IPAddress == "" {
+		return fmt.Errorf("generic driver requires the --generic-ip-address option")
+	}
+
+	if d.SSHKey == "" {
+		return fmt.Errorf("generic driver requires the --generic-ssh-key option")
+	}
+
+	return nil
+}
+
+func (d *Driver) PreCreateCheck() error {
+	if _, err := os.Stat(d.SSHKey); err != nil {
+		return fmt.Errorf("unable to read SSH key %s: %s", d.SSHKey, err)
+	}
+	return nil
+}
+
+func (d *Driver) Create(ctx context.Context) error {
+	log.Infof("Importing SSH key...")
+
+	if err := utils.CopyFile(d.SSHKey, d.sshKeyPath()); err != nil {
+		return fmt.Errorf("unable to copy ssh key: %s", err)
+	}
+
+	if err := os.Chmod(d.sshKeyPath(), 0600); err != nil {
+		return err
+	}
+
+	log.Infof("Waiting for SSH on %s:%d", d.IPAddress, d.SSHPort)
+
+	if err := ssh.WaitForTCP(ctx, fmt.Sprintf("%s:%d", d.IPAddress, d.SSHPort)); err != nil {
+		return err
+	}
+
+	log.Debugf("Setting hostname: %s", d.MachineName)
+	cmd, err := d.GetSSHCommand(fmt.Sprintf(
+		"echo \"127.0.0.1 %s\" | sudo tee -a /etc/hosts && sudo hostname %s && echo \"%s\" | sudo tee /etc/hostname",
+		d.MachineName,
+		d.MachineName,
+		d.MachineName,
+	))
+	if err != nil {
+		return err
+	}
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (d *Driver) GetURL() (string, error) {
+	ip, err := d.GetIP()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("tcp://%s:%d", ip, dockerPort), nil
+}
+
+func (d *Driver) GetIP() (string, error) {
+	if d.IPAddress == "" {
+		return "", fmt.Errorf("IP address is not set")
+	}
+	return d.IPAddress, nil
+}
+
+// GetState reports the host as running when its SSH port accepts
+// connections. There is no provider API to ask for anything more precise.
+func (d *Driver) GetState() (state.State, error) {
+	addr := net.JoinHostPort(d.IPAddress, strconv.Itoa(d.SSHPort))
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		return state.Stopped, nil
+	}
+	conn.Close()
+	return state.Running, nil
+}
+
+// Start is not supported, generic hosts must be powered on by other means.
+func (d *Driver) Start(ctx context.Context) error {
+	return drivers.NotSupported(d, drivers.CapabilityStart)
+}
+
+func (d *Driver) Stop(ctx context.Context) error {
+	log.Debug("Stopping host...")
+
+	cmd, err := d.GetSSHCommand("sudo shutdown -h now")
+	if err != nil {
+		return err
+	}
+	return cmd.Run()
+}
+
+// Remove only forgets about the host; the server itself is left untouched
+// as it was not created by machine.
+func (d *Driver) Remove(ctx context.Context) error {
+	return nil
+}
+
+func (d *Driver) Restart() error {
+	log.Debug("Restarting host...")
+
+	cmd, err := d.GetSSHCommand("sudo shutdown -r now")
+	if err != nil {
+		return err
+	}
+	return cmd.Run()
+}
+
+func (d *Driver) Kill() error {
+	return d.Stop(context.Background())
+}
+
+func (d *Driver) GetSSHCommand(args ...string) (*exec.Cmd, error) {
+	return ssh.GetSSHCommand(d.IPAddress, d.SSHPort, d.SSHUser, d.sshKeyPath(), args...), nil
+}
+
+func (d *Driver) Unsupported() []string {
+	return []string{drivers.CapabilityStart}
+}
+
+func (d *Driver) sshKeyPath() string {
+	return filepath.Join(d.storePath, "id_rsa")
+}
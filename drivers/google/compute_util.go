@@ -3,10 +3,12 @@ package google
 import (
 	"fmt"
 	"io/ioutil"
-	"time"
+	"sort"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/machine/ssh"
+	"github.com/docker/machine/utils"
+	"golang.org/x/net/context"
 	raw "google.golang.org/api/compute/v1"
 )
 
@@ -63,21 +65,21 @@ func (c *ComputeUtil) disk() (*raw.Disk, error) {
 }
 
 // deleteDisk deletes the persistent disk.
-func (c *ComputeUtil) deleteDisk() error {
+func (c *ComputeUtil) deleteDisk(ctx context.Context) error {
 	log.Infof("Deleting disk.")
 	op, err := c.service.Disks.Delete(c.project, c.zone, c.diskName()).Do()
 	if err != nil {
 		return err
 	}
 	log.Infof("Waiting for disk to delete.")
-	return c.waitForRegionalOp(op.Name)
+	return c.waitForRegionalOp(ctx, op.Name)
 }
 
 func (c *ComputeUtil) firewallRule() (*raw.Firewall, error) {
 	return c.service.Firewalls.Get(c.project, firewallRule).Do()
 }
 
-func (c *ComputeUtil) createFirewallRule() error {
+func (c *ComputeUtil) createFirewallRule(ctx context.Context) error {
 	log.Infof("Creating firewall rule.")
 	rule := &raw.Firewall{
 		Allowed: []*raw.FirewallAllowed{
@@ -100,7 +102,7 @@ func (c *ComputeUtil) createFirewallRule() error {
 	if err != nil {
 		return err
 	}
-	return c.waitForGlobalOp(op.Name)
+	return c.waitForGlobalOp(ctx, op.Name)
 }
 
 // instance retrieves the instance.
@@ -109,11 +111,11 @@ func (c *ComputeUtil) instance() (*raw.Instance, error) {
 }
 
 // createInstance creates a GCE VM instance.
-func (c *ComputeUtil) createInstance(d *Driver) error {
+func (c *ComputeUtil) createInstance(ctx context.Context, d *Driver) error {
 	log.Infof("Creating instance.")
 	// The rule will either exist or be nil in case of an error.
 	if rule, _ := c.firewallRule(); rule == nil {
-		if err := c.createFirewallRule(); err != nil {
+		if err := c.createFirewallRule(ctx); err != nil {
 			return err
 		}
 	}
@@ -143,6 +145,9 @@ func (c *ComputeUtil) createInstance(d *Driver) error {
 				firewallTargetTag,
 			},
 		},
+		Metadata: &raw.Metadata{
+			Items: userDataItems(d.userData),
+		},
 	}
 	disk, err := c.disk()
 	if disk == nil || err != nil {
@@ -161,7 +166,7 @@ func (c *ComputeUtil) createInstance(d *Driver) error {
 		return err
 	}
 	log.Infof("Waiting for Instance...")
-	if err = c.waitForRegionalOp(op.Name); err != nil {
+	if err = c.waitForRegionalOp(ctx, op.Name); err != nil {
 		return err
 	}
 
@@ -170,7 +175,9 @@ func (c *ComputeUtil) createInstance(d *Driver) error {
 		return err
 	}
 	ip := instance.NetworkInterfaces[0].AccessConfigs[0].NatIP
-	c.waitForSSH(ip)
+	if err := c.waitForSSH(ctx, ip); err != nil {
+		return err
+	}
 
 	// Update the SSH Key
 	sshKey, err := ioutil.ReadFile(d.publicSSHKeyPath)
@@ -178,20 +185,27 @@ func (c *ComputeUtil) createInstance(d *Driver) error {
 		return err
 	}
 	log.Infof("Uploading SSH Key")
+	items := append(userDataItems(d.userData), &raw.MetadataItems{
+		Key:   "sshKeys",
+		Value: c.userName + ":" + string(sshKey) + "\n",
+	})
+	// The API version in use has no instance labels, the machine labels are
+	// kept in the instance metadata instead.
+	for _, key := range sortedKeys(d.Labels) {
+		items = append(items, &raw.MetadataItems{
+			Key:   key,
+			Value: d.Labels[key],
+		})
+	}
 	op, err = c.service.Instances.SetMetadata(c.project, c.zone, c.instanceName, &raw.Metadata{
 		Fingerprint: instance.Metadata.Fingerprint,
-		Items: []*raw.MetadataItems{
-			{
-				Key:   "sshKeys",
-				Value: c.userName + ":" + string(sshKey) + "\n",
-			},
-		},
+		Items:       items,
 	}).Do()
 	if err != nil {
 		return err
 	}
 	log.Infof("Waiting for SSH Key")
-	err = c.waitForRegionalOp(op.Name)
+	err = c.waitForRegionalOp(ctx, op.Name)
 	if err != nil {
 		return err
 	}
@@ -211,46 +225,18 @@ func (c *ComputeUtil) createInstance(d *Driver) error {
 		return err
 	}
 
-	log.Debugf("Installing Docker")
-
-	cmd, err = d.GetSSHCommand("if [ ! -e /usr/bin/docker ]; then curl -sL https://get.docker.com | sudo sh -; fi")
-	if err != nil {
-		return err
-
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-
-	}
-
-	return nil
-}
-
-func (c *ComputeUtil) updateDocker(d *Driver) error {
-	log.Debugf("Upgrading Docker")
-
-	cmd, err := d.GetSSHCommand("sudo apt-get update && sudo apt-get install --upgrade lxc-docker")
-	if err != nil {
-		return err
-
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-
-	}
-
 	return nil
 }
 
 // deleteInstance deletes the instance, leaving the persistent disk.
-func (c *ComputeUtil) deleteInstance() error {
+func (c *ComputeUtil) deleteInstance(ctx context.Context) error {
 	log.Infof("Deleting instance.")
 	op, err := c.service.Instances.Delete(c.project, c.zone, c.instanceName).Do()
 	if err != nil {
 		return err
 	}
 	log.Infof("Waiting for instance to delete.")
-	return c.waitForRegionalOp(op.Name)
+	return c.waitForRegionalOp(ctx, op.Name)
 }
 
 func (c *ComputeUtil) executeCommands(commands []string, ip, sshKeyPath string) error {
@@ -263,41 +249,40 @@ func (c *ComputeUtil) executeCommands(commands []string, ip, sshKeyPath string)
 	return nil
 }
 
-func (c *ComputeUtil) waitForOp(opGetter func() (*raw.Operation, error)) error {
-	for {
+func (c *ComputeUtil) waitForOp(ctx context.Context, name string, opGetter func() (*raw.Operation, error)) error {
+	return utils.WaitFor(ctx, fmt.Sprintf("operation %q", name), utils.DefaultBackoff, func() error {
 		op, err := opGetter()
 		if err != nil {
 			return err
 		}
 		log.Debugf("operation %q status: %s", op.Name, op.Status)
-		if op.Status == "DONE" {
-			if op.Error != nil {
-				return fmt.Errorf("Operation error: %v", *op.Error.Errors[0])
-			}
-			break
+		if op.Status != "DONE" {
+			return fmt.Errorf("operation %q is %s", op.Name, op.Status)
 		}
-		time.Sleep(1 * time.Second)
-	}
-	return nil
+		if op.Error != nil {
+			return utils.Permanent(fmt.Errorf("Operation error: %v", *op.Error.Errors[0]))
+		}
+		return nil
+	})
 }
 
 // waitForOp waits for the GCE Operation to finish.
-func (c *ComputeUtil) waitForRegionalOp(name string) error {
-	return c.waitForOp(func() (*raw.Operation, error) {
+func (c *ComputeUtil) waitForRegionalOp(ctx context.Context, name string) error {
+	return c.waitForOp(ctx, name, func() (*raw.Operation, error) {
 		return c.service.ZoneOperations.Get(c.project, c.zone, name).Do()
 	})
 }
 
-func (c *ComputeUtil) waitForGlobalOp(name string) error {
-	return c.waitForOp(func() (*raw.Operation, error) {
+func (c *ComputeUtil) waitForGlobalOp(ctx context.Context, name string) error {
+	return c.waitForOp(ctx, name, func() (*raw.Operation, error) {
 		return c.service.GlobalOperations.Get(c.project, name).Do()
 	})
 }
 
 // waitForSSH waits for SSH to become ready on the instance.
-func (c *ComputeUtil) waitForSSH(ip string) error {
+func (c *ComputeUtil) waitForSSH(ctx context.Context, ip string) error {
 	log.Infof("Waiting for SSH...")
-	return ssh.WaitForTCP(fmt.Sprintf("%s:22", ip))
+	return ssh.WaitForTCP(ctx, fmt.Sprintf("%s:22", ip))
 }
 
 // ip retrieves and returns the external IP address of the instance.
@@ -311,3 +296,27 @@ func (c *ComputeUtil) ip() (string, error) {
 	}
 	return c.ipAddress, nil
 }
+
+// sortedKeys returns the keys of m in a stable order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// userDataItems returns the metadata item cloud-init reads the user data
+// from. The instance is created with it so it is there on the first boot.
+func userDataItems(userData []byte) []*raw.MetadataItems {
+	if len(userData) == 0 {
+		return []*raw.MetadataItems{}
+	}
+	return []*raw.MetadataItems{
+		{
+			Key:   "user-data",
+			Value: string(userData),
+		},
+	}
+}
@@ -12,10 +12,7 @@ import (
 	"github.com/codegangsta/cli"
 	"github.com/docker/machine/drivers"
 	"github.com/docker/machine/ssh"
-)
-
-const (
-	dockerConfigDir = "/etc/docker"
+	"golang.org/x/net/context"
 )
 
 // Driver is a struct compatible with the docker.hosts.drivers.Driver interface.
@@ -29,8 +26,10 @@ type Driver struct {
 	Project          string
 	CaCertPath       string
 	PrivateKeyPath   string
+	Labels           map[string]string
 	sshKeyPath       string
 	publicSSHKeyPath string
+	userData         []byte
 }
 
 // CreateFlags are the command line flags used to create a driver.
@@ -102,6 +101,16 @@ func (driver *Driver) DriverName() string {
 	return "google"
 }
 
+// SetLabels sets the labels which are added to the instance metadata.
+func (driver *Driver) SetLabels(labels map[string]string) {
+	driver.Labels = labels
+}
+
+// SetUserData sets the user data which is added to the instance metadata.
+func (driver *Driver) SetUserData(userData []byte) {
+	driver.userData = userData
+}
+
 // SetConfigFromFlags initializes the driver based on the command line flags.
 func (driver *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	driver.Zone = flags.String("google-zone")
@@ -124,7 +133,7 @@ func (d *Driver) PreCreateCheck() error {
 }
 
 // Create creates a GCE VM instance acting as a docker host.
-func (driver *Driver) Create() error {
+func (driver *Driver) Create(ctx context.Context) error {
 	c, err := newComputeUtil(driver)
 	if err != nil {
 		return err
@@ -141,7 +150,70 @@ func (driver *Driver) Create() error {
 		return err
 	}
 
-	return c.createInstance(driver)
+	return c.createInstance(ctx, driver)
+}
+
+// Plan checks the project, zone and machine type against GCE and returns
+// what Create would make. The OAuth token is kept with the machine, so it is
+// asked for again when the machine is created.
+func (driver *Driver) Plan() ([]drivers.PlannedResource, error) {
+	c, err := newComputeUtil(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	if instance, _ := c.instance(); instance != nil {
+		return nil, fmt.Errorf("Instance %v already exists.", driver.MachineName)
+	}
+	if _, err := c.service.Zones.Get(c.project, c.zone).Do(); err != nil {
+		return nil, fmt.Errorf("Error checking zone %s: %s", c.zone, err)
+	}
+	if _, err := c.service.MachineTypes.Get(c.project, c.zone, driver.MachineType).Do(); err != nil {
+		return nil, fmt.Errorf("Error checking machine type %s: %s", driver.MachineType, err)
+	}
+
+	resources := []drivers.PlannedResource{}
+
+	// Both the rule and the disk are nil when they do not exist.
+	if rule, _ := c.firewallRule(); rule == nil {
+		resources = append(resources, drivers.PlannedResource{
+			Action: "create",
+			Type:   "firewall rule",
+			Name:   firewallRule,
+			Detail: fmt.Sprintf("port %s open to 0.0.0.0/0 for tag %s", port, firewallTargetTag),
+		})
+	} else {
+		resources = append(resources, drivers.PlannedResource{
+			Action: "use",
+			Type:   "firewall rule",
+			Name:   firewallRule,
+		})
+	}
+
+	if disk, _ := c.disk(); disk == nil {
+		resources = append(resources, drivers.PlannedResource{
+			Action: "create",
+			Type:   "disk",
+			Name:   c.diskName(),
+			Detail: fmt.Sprintf("%d GB from %s", driver.DiskSize, imageName),
+		})
+	} else {
+		resources = append(resources, drivers.PlannedResource{
+			Action: "use",
+			Type:   "disk",
+			Name:   c.diskName(),
+			Detail: fmt.Sprintf("%d GB", disk.SizeGb),
+		})
+	}
+
+	resources = append(resources, drivers.PlannedResource{
+		Action: "create",
+		Type:   "instance",
+		Name:   driver.MachineName,
+		Detail: fmt.Sprintf("%s in %s", driver.MachineType, driver.Zone),
+	})
+
+	return resources, nil
 }
 
 // GetURL returns the URL of the remote docker daemon.
@@ -193,25 +265,25 @@ func (driver *Driver) GetState() (state.State, error) {
 }
 
 // Start creates a GCE instance and attaches it to the existing disk.
-func (driver *Driver) Start() error {
+func (driver *Driver) Start(ctx context.Context) error {
 	c, err := newComputeUtil(driver)
 	if err != nil {
 		return err
 	}
-	return c.createInstance(driver)
+	return c.createInstance(ctx, driver)
 }
 
 // Stop deletes the GCE instance, but keeps the disk.
-func (driver *Driver) Stop() error {
+func (driver *Driver) Stop(ctx context.Context) error {
 	c, err := newComputeUtil(driver)
 	if err != nil {
 		return err
 	}
-	return c.deleteInstance()
+	return c.deleteInstance(ctx)
 }
 
 // Remove deletes the GCE instance and the disk.
-func (driver *Driver) Remove() error {
+func (driver *Driver) Remove(ctx context.Context) error {
 	c, err := newComputeUtil(driver)
 	if err != nil {
 		return err
@@ -221,11 +293,11 @@ func (driver *Driver) Remove() error {
 		return err
 	}
 	if s == state.Running {
-		if err := c.deleteInstance(); err != nil {
+		if err := c.deleteInstance(ctx); err != nil {
 			return err
 		}
 	}
-	return c.deleteDisk()
+	return c.deleteDisk(ctx)
 }
 
 // Restart deletes and recreates the GCE instance, keeping the disk.
@@ -234,48 +306,16 @@ func (driver *Driver) Restart() error {
 	if err != nil {
 		return err
 	}
-	if err := c.deleteInstance(); err != nil {
+	if err := c.deleteInstance(context.Background()); err != nil {
 		return err
 	}
 
-	return c.createInstance(driver)
+	return c.createInstance(context.Background(), driver)
 }
 
 // Kill deletes the GCE instance, but keeps the disk.
 func (driver *Driver) Kill() error {
-	return driver.Stop()
-}
-
-func (d *Driver) StartDocker() error {
-	log.Debug("Starting Docker...")
-
-	cmd, err := d.GetSSHCommand("sudo service docker start")
-	if err != nil {
-		return err
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (d *Driver) StopDocker() error {
-	log.Debug("Stopping Docker...")
-
-	cmd, err := d.GetSSHCommand("sudo service docker stop")
-	if err != nil {
-		return err
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (d *Driver) GetDockerConfigDir() string {
-	return dockerConfigDir
+	return driver.Stop(context.Background())
 }
 
 // GetSSHCommand returns a command that will run over SSH on the GCE instance.
@@ -286,12 +326,3 @@ func (driver *Driver) GetSSHCommand(args ...string) (*exec.Cmd, error) {
 	}
 	return ssh.GetSSHCommand(ip, 22, driver.UserName, driver.sshKeyPath, args...), nil
 }
-
-// Upgrade upgrades the docker daemon on the host to the latest version.
-func (driver *Driver) Upgrade() error {
-	c, err := newComputeUtil(driver)
-	if err != nil {
-		return err
-	}
-	return c.updateDocker(driver)
-}
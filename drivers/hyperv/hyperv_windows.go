@@ -8,7 +8,6 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/codegangsta/cli"
@@ -16,10 +15,7 @@ import (
 	"github.com/docker/machine/ssh"
 	"github.com/docker/machine/state"
 	"github.com/docker/machine/utils"
-)
-
-const (
-	dockerConfigDir = "/var/lib/boot2docker"
+	"golang.org/x/net/context"
 )
 
 type Driver struct {
@@ -128,7 +124,7 @@ func (d *Driver) GetState() (state.State, error) {
 	return state.None, nil
 }
 
-func (d *Driver) Create() error {
+func (d *Driver) Create(ctx context.Context) error {
 	err := hypervAvailable()
 	if err != nil {
 		return err
@@ -243,7 +239,7 @@ func (d *Driver) Create() error {
 	}
 
 	log.Infof("Starting  VM...")
-	if err := d.Start(); err != nil {
+	if err := d.Start(ctx); err != nil {
 		return err
 	}
 
@@ -283,24 +279,24 @@ func (d *Driver) chooseVirtualSwitch() (string, error) {
 	return "", fmt.Errorf("no vswitch found")
 }
 
-func (d *Driver) wait() error {
+func (d *Driver) wait(ctx context.Context) error {
 	log.Infof("Waiting for host to start...")
-	for {
-		ip, _ := d.GetIP()
-		if ip != "" {
-			break
+	var ip string
+	if err := utils.WaitFor(ctx, "host to start", utils.DefaultBackoff, func() error {
+		var err error
+		ip, err = d.GetIP()
+		if err == nil && ip == "" {
+			err = fmt.Errorf("host has no IP yet")
 		}
-		time.Sleep(1 * time.Second)
-	}
-	log.Infof("Got IP, waiting for SSH")
-	ip, err := d.GetIP()
-	if err != nil {
+		return err
+	}); err != nil {
 		return err
 	}
-	return ssh.WaitForTCP(fmt.Sprintf("%s:22", ip))
+	log.Infof("Got IP, waiting for SSH")
+	return ssh.WaitForTCP(ctx, fmt.Sprintf("%s:22", ip))
 }
 
-func (d *Driver) Start() error {
+func (d *Driver) Start(ctx context.Context) error {
 	command := []string{
 		"Start-VM",
 		"-Name", d.MachineName}
@@ -308,10 +304,10 @@ func (d *Driver) Start() error {
 	if err != nil {
 		return err
 	}
-	return d.wait()
+	return d.wait(ctx)
 }
 
-func (d *Driver) Stop() error {
+func (d *Driver) Stop(ctx context.Context) error {
 	command := []string{
 		"Stop-VM",
 		"-Name", d.MachineName}
@@ -319,21 +315,24 @@ func (d *Driver) Stop() error {
 	if err != nil {
 		return err
 	}
-	for {
+	return d.waitForStop(ctx)
+}
+
+// waitForStop waits until the host is no longer running.
+func (d *Driver) waitForStop(ctx context.Context) error {
+	return utils.WaitFor(ctx, "host to stop", utils.DefaultBackoff, func() error {
 		s, err := d.GetState()
 		if err != nil {
-			return err
+			return utils.Permanent(err)
 		}
 		if s == state.Running {
-			time.Sleep(1 * time.Second)
-		} else {
-			break
+			return fmt.Errorf("host is still running")
 		}
-	}
-	return nil
+		return nil
+	})
 }
 
-func (d *Driver) Remove() error {
+func (d *Driver) Remove(ctx context.Context) error {
 	s, err := d.GetState()
 	if err != nil {
 		return err
@@ -352,12 +351,12 @@ func (d *Driver) Remove() error {
 }
 
 func (d *Driver) Restart() error {
-	err := d.Stop()
+	err := d.Stop(context.Background())
 	if err != nil {
 		return err
 	}
 
-	return d.Start()
+	return d.Start(context.Background())
 }
 
 func (d *Driver) Kill() error {
@@ -369,18 +368,7 @@ func (d *Driver) Kill() error {
 	if err != nil {
 		return err
 	}
-	for {
-		s, err := d.GetState()
-		if err != nil {
-			return err
-		}
-		if s == state.Running {
-			time.Sleep(1 * time.Second)
-		} else {
-			break
-		}
-	}
-	return nil
+	return d.waitForStop(context.Background())
 }
 
 func (d *Driver) setMachineNameIfNotSet() {
@@ -416,7 +404,7 @@ func (d *Driver) GetSSHCommand(args ...string) (*exec.Cmd, error) {
 
 func (d *Driver) Upgrade() error {
 	log.Infof("Stopping machine...")
-	if err := d.Stop(); err != nil {
+	if err := d.Stop(context.Background()); err != nil {
 		return err
 	}
 
@@ -432,45 +420,13 @@ func (d *Driver) Upgrade() error {
 	}
 
 	log.Infof("Starting machine...")
-	if err := d.Start(); err != nil {
+	if err := d.Start(context.Background()); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (d *Driver) StartDocker() error {
-	log.Debug("Starting Docker...")
-
-	cmd, err := d.GetSSHCommand("sudo /etc/init.d/docker start")
-	if err != nil {
-		return err
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (d *Driver) StopDocker() error {
-	log.Debug("Stopping Docker...")
-
-	cmd, err := d.GetSSHCommand("if [ -e /var/run/docker.pid ]; then sudo /etc/init.d/docker stop ; fi")
-	if err != nil {
-		return err
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (d *Driver) GetDockerConfigDir() string {
-	return dockerConfigDir
-}
-
 func (d *Driver) sshKeyPath() string {
 	return filepath.Join(d.storePath, "id_rsa")
 }
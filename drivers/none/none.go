@@ -8,6 +8,7 @@ import (
 	"github.com/docker/docker/api"
 	"github.com/docker/machine/drivers"
 	"github.com/docker/machine/state"
+	"golang.org/x/net/context"
 )
 
 // Driver is the driver used when no driver is selected. It is used to
@@ -65,54 +66,52 @@ func (d *Driver) GetIP() (string, error) {
 	return "", nil
 }
 
+// GetState is not supported as machine only knows the URL of the host.
 func (d *Driver) GetState() (state.State, error) {
-	return state.None, nil
+	return state.None, drivers.NotSupported(d, drivers.CapabilityState)
 }
 
 func (d *Driver) PreCreateCheck() error {
 	return nil
 }
 
-func (d *Driver) Create() error {
+func (d *Driver) Create(ctx context.Context) error {
 	return nil
 }
 
-func (d *Driver) Start() error {
-	return fmt.Errorf("hosts without a driver cannot be started")
+func (d *Driver) Start(ctx context.Context) error {
+	return drivers.NotSupported(d, drivers.CapabilityStart)
 }
 
-func (d *Driver) Stop() error {
-	return fmt.Errorf("hosts without a driver cannot be stopped")
+func (d *Driver) Stop(ctx context.Context) error {
+	return drivers.NotSupported(d, drivers.CapabilityStop)
 }
 
-func (d *Driver) Remove() error {
+func (d *Driver) Remove(ctx context.Context) error {
 	return nil
 }
 
 func (d *Driver) Restart() error {
-	return fmt.Errorf("hosts without a driver cannot be restarted")
+	return drivers.NotSupported(d, drivers.CapabilityRestart)
 }
 
 func (d *Driver) Kill() error {
-	return fmt.Errorf("hosts without a driver cannot be killed")
-}
-
-func (d *Driver) StartDocker() error {
-	return fmt.Errorf("hosts without a driver cannot start docker")
-}
-
-func (d *Driver) StopDocker() error {
-	return fmt.Errorf("hosts without a driver cannot stop docker")
-}
-
-func (d *Driver) GetDockerConfigDir() string {
-	return ""
-}
-
-func (d *Driver) Upgrade() error {
-	return fmt.Errorf("hosts without a driver cannot be upgraded")
+	return drivers.NotSupported(d, drivers.CapabilityKill)
 }
 
 func (d *Driver) GetSSHCommand(args ...string) (*exec.Cmd, error) {
-	return nil, fmt.Errorf("hosts without a driver do not support SSH")
+	return nil, drivers.NotSupported(d, drivers.CapabilitySSH)
+}
+
+// Unsupported lists all the operations on the host, as there is no driver
+// to run them with.
+func (d *Driver) Unsupported() []string {
+	return []string{
+		drivers.CapabilityStart,
+		drivers.CapabilityStop,
+		drivers.CapabilityRestart,
+		drivers.CapabilityKill,
+		drivers.CapabilityState,
+		drivers.CapabilitySSH,
+	}
 }
@@ -1,7 +1,11 @@
 package openstack
 
 import (
+	"fmt"
+	"time"
+
 	log "github.com/Sirupsen/logrus"
+	"github.com/docker/machine/utils"
 	"github.com/rackspace/gophercloud"
 	"github.com/rackspace/gophercloud/openstack"
 	"github.com/rackspace/gophercloud/openstack/compute/v2/extensions/keypairs"
@@ -13,6 +17,7 @@ import (
 	"github.com/rackspace/gophercloud/openstack/networking/v2/networks"
 	"github.com/rackspace/gophercloud/openstack/networking/v2/ports"
 	"github.com/rackspace/gophercloud/pagination"
+	"golang.org/x/net/context"
 )
 
 type Client interface {
@@ -26,7 +31,7 @@ type Client interface {
 	StopInstance(d *Driver) error
 	RestartInstance(d *Driver) error
 	DeleteInstance(d *Driver) error
-	WaitForInstanceStatus(d *Driver, status string, timeout int) error
+	WaitForInstanceStatus(ctx context.Context, d *Driver, status string, timeout int) error
 	GetInstanceIpAddresses(d *Driver) ([]IpAddress, error)
 	CreateKeyPair(d *Driver, name string, publicKey string) error
 	DeleteKeyPair(d *Driver, name string) error
@@ -51,6 +56,8 @@ func (c *GenericClient) CreateInstance(d *Driver) (string, error) {
 		FlavorRef:      d.FlavorId,
 		ImageRef:       d.ImageId,
 		SecurityGroups: d.SecurityGroups,
+		Metadata:       d.Labels,
+		UserData:       d.userData,
 	}
 	if d.NetworkId != "" {
 		serverOpts.Networks = []servers.Network{
@@ -129,11 +136,27 @@ func (c *GenericClient) DeleteInstance(d *Driver) error {
 	return nil
 }
 
-func (c *GenericClient) WaitForInstanceStatus(d *Driver, status string, timeout int) error {
-	if err := servers.WaitForStatus(c.Compute, d.MachineId, status, timeout); err != nil {
-		return err
-	}
-	return nil
+// WaitForInstanceStatus polls the instance until it has status, for at most
+// timeout seconds or until ctx is done. An instance in the ERROR status is
+// not waited for.
+func (c *GenericClient) WaitForInstanceStatus(ctx context.Context, d *Driver, status string, timeout int) error {
+	backoff := utils.DefaultBackoff
+	backoff.Timeout = time.Duration(timeout) * time.Second
+
+	what := fmt.Sprintf("instance %s to be %s", d.MachineId, status)
+	return utils.WaitFor(ctx, what, backoff, func() error {
+		current, err := c.GetInstanceState(d)
+		if err != nil {
+			return err
+		}
+		switch current {
+		case status:
+			return nil
+		case "ERROR":
+			return utils.Permanent(fmt.Errorf("Instance %s is in the ERROR status", d.MachineId))
+		}
+		return fmt.Errorf("instance %s is %s", d.MachineId, current)
+	})
 }
 
 func (c *GenericClient) GetInstanceIpAddresses(d *Driver) ([]IpAddress, error) {
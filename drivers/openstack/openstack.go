@@ -5,50 +5,52 @@ import (
 	"io/ioutil"
 	"os/exec"
 	"path"
-	"strconv"
 	"strings"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/codegangsta/cli"
-	"github.com/docker/docker/utils"
+	dockerutils "github.com/docker/docker/utils"
 	"github.com/docker/machine/drivers"
 	"github.com/docker/machine/ssh"
 	"github.com/docker/machine/state"
+	"github.com/docker/machine/utils"
+	"golang.org/x/net/context"
 )
 
-const (
-	dockerConfigDir = "/etc/docker"
-)
+// ipBackoff is how GetIP polls for the address of the instance, which
+// OpenStack may take a while to assign.
+var ipBackoff = utils.Backoff{Interval: 2 * time.Second, MaxAttempts: 200}
 
 type Driver struct {
-	AuthUrl             string
-	Username            string
-	Password            string
-	TenantName          string
-	TenantId            string
-	Region              string
-	EndpointType        string
-	MachineName         string
-	MachineId           string
-	FlavorName          string
-	FlavorId            string
-	ImageName           string
-	ImageId             string
-	KeyPairName         string
-	NetworkName         string
-	NetworkId           string
-	SecurityGroups      []string
-	FloatingIpPool      string
-	FloatingIpPoolId    string
-	SSHUser             string
-	SSHPort             int
-	Ip                  string
-	EnableDockerInstall bool
-	CaCertPath          string
-	PrivateKeyPath      string
-	storePath           string
-	client              Client
+	AuthUrl          string
+	Username         string
+	Password         string
+	TenantName       string
+	TenantId         string
+	Region           string
+	EndpointType     string
+	MachineName      string
+	MachineId        string
+	FlavorName       string
+	FlavorId         string
+	ImageName        string
+	ImageId          string
+	KeyPairName      string
+	NetworkName      string
+	NetworkId        string
+	SecurityGroups   []string
+	FloatingIpPool   string
+	FloatingIpPoolId string
+	SSHUser          string
+	SSHPort          int
+	Ip               string
+	Labels           map[string]string
+	CaCertPath       string
+	PrivateKeyPath   string
+	storePath        string
+	client           Client
+	userData         []byte
 }
 
 type CreateFlags struct {
@@ -172,11 +174,11 @@ func GetCreateFlags() []cli.Flag {
 			Usage: "OpenStack SSH port",
 			Value: 22,
 		},
-		// Using a StringFlag rather than a BoolFlag because
-		// the BoolFlag default value is always false
+		// Kept so existing scripts keep working, Docker is installed by the
+		// provisioner when it is missing.
 		cli.StringFlag{
 			Name:  "openstack-docker-install",
-			Usage: "Openstack should install docker on the machine",
+			Usage: "Deprecated, Docker is installed on the machine when it is missing",
 			Value: "true",
 		},
 	}
@@ -207,6 +209,16 @@ func (d *Driver) DriverName() string {
 	return "openstack"
 }
 
+// SetLabels sets the labels which are added to the server metadata.
+func (d *Driver) SetLabels(labels map[string]string) {
+	d.Labels = labels
+}
+
+// SetUserData sets the user data passed to the server on creation.
+func (d *Driver) SetUserData(userData []byte) {
+	d.userData = userData
+}
+
 func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.AuthUrl = flags.String("openstack-auth-url")
 	d.Username = flags.String("openstack-username")
@@ -228,11 +240,6 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.SSHUser = flags.String("openstack-ssh-user")
 	d.SSHPort = flags.Int("openstack-ssh-port")
 
-	installDocker, err := strconv.ParseBool(flags.String("openstack-docker-install"))
-	if err != nil {
-		return err
-	}
-	d.EnableDockerInstall = installDocker
 	return d.checkConfig()
 }
 
@@ -264,28 +271,34 @@ func (d *Driver) GetIP() (string, error) {
 	}
 
 	// Looking for the IP address in a retry loop to deal with OpenStack latency
-	for retryCount := 0; retryCount < 200; retryCount++ {
+	var ip string
+	what := fmt.Sprintf("an IP address for instance %s", d.MachineId)
+	err := utils.WaitFor(context.Background(), what, ipBackoff, func() error {
 		addresses, err := d.client.GetInstanceIpAddresses(d)
 		if err != nil {
-			return "", err
+			return utils.Permanent(err)
 		}
 		for _, a := range addresses {
 			if a.AddressType == addressType {
-				return a.Address, nil
+				ip = a.Address
+				return nil
 			}
 		}
-		time.Sleep(2 * time.Second)
-	}
-	return "", fmt.Errorf("No IP found for the machine")
+		return fmt.Errorf("No IP found for the machine")
+	})
+	return ip, err
 }
 
-func (d *Driver) GetState() (state.State, error) {
+func (d *Driver) GetProviderState() (string, error) {
 	log.WithField("MachineId", d.MachineId).Debug("Get status for OpenStack instance...")
 	if err := d.initCompute(); err != nil {
-		return state.None, err
+		return "", err
 	}
+	return d.client.GetInstanceState(d)
+}
 
-	s, err := d.client.GetInstanceState(d)
+func (d *Driver) GetState() (state.State, error) {
+	s, err := d.GetProviderState()
 	if err != nil {
 		return state.None, err
 	}
@@ -309,15 +322,15 @@ func (d *Driver) GetState() (state.State, error) {
 	case "ERROR":
 		return state.Error, nil
 	}
-	return state.None, nil
+	return state.Unknown, nil
 }
 
 func (d *Driver) PreCreateCheck() error {
 	return nil
 }
 
-func (d *Driver) Create() error {
-	d.KeyPairName = fmt.Sprintf("%s-%s", d.MachineName, utils.GenerateRandomID())
+func (d *Driver) Create(ctx context.Context) error {
+	d.KeyPairName = fmt.Sprintf("%s-%s", d.MachineName, dockerutils.GenerateRandomID())
 
 	if err := d.resolveIds(); err != nil {
 		return err
@@ -328,7 +341,7 @@ func (d *Driver) Create() error {
 	if err := d.createMachine(); err != nil {
 		return err
 	}
-	if err := d.waitForInstanceActive(); err != nil {
+	if err := d.waitForInstanceActive(ctx); err != nil {
 		return err
 	}
 	if d.FloatingIpPool != "" {
@@ -339,18 +352,10 @@ func (d *Driver) Create() error {
 	if err := d.lookForIpAddress(); err != nil {
 		return err
 	}
-	if err := d.waitForSSHServer(); err != nil {
-		return err
-	}
-	if d.EnableDockerInstall {
-		if err := d.installDocker(); err != nil {
-			return err
-		}
-	}
-	return nil
+	return d.waitForSSHServer(ctx)
 }
 
-func (d *Driver) Start() error {
+func (d *Driver) Start(ctx context.Context) error {
 	log.WithField("MachineId", d.MachineId).Info("Starting OpenStack instance...")
 	if err := d.initCompute(); err != nil {
 		return err
@@ -358,10 +363,10 @@ func (d *Driver) Start() error {
 	if err := d.client.StartInstance(d); err != nil {
 		return err
 	}
-	return d.waitForInstanceToStart()
+	return d.waitForInstanceToStart(ctx)
 }
 
-func (d *Driver) Stop() error {
+func (d *Driver) Stop(ctx context.Context) error {
 	log.WithField("MachineId", d.MachineId).Info("Stopping OpenStack instance...")
 	if err := d.initCompute(); err != nil {
 		return err
@@ -371,13 +376,13 @@ func (d *Driver) Stop() error {
 	}
 
 	log.WithField("MachineId", d.MachineId).Info("Waiting for the OpenStack instance to stop...")
-	if err := d.client.WaitForInstanceStatus(d, "SHUTOFF", 200); err != nil {
+	if err := d.client.WaitForInstanceStatus(ctx, d, "SHUTOFF", 200); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (d *Driver) Remove() error {
+func (d *Driver) Remove(ctx context.Context) error {
 	log.WithField("MachineId", d.MachineId).Info("Deleting OpenStack instance...")
 	if err := d.initCompute(); err != nil {
 		return err
@@ -400,59 +405,11 @@ func (d *Driver) Restart() error {
 	if err := d.client.RestartInstance(d); err != nil {
 		return err
 	}
-	return d.waitForInstanceToStart()
+	return d.waitForInstanceToStart(context.Background())
 }
 
 func (d *Driver) Kill() error {
-	return d.Stop()
-}
-
-func (d *Driver) Upgrade() error {
-	log.Debugf("Upgrading Docker")
-
-	cmd, err := d.GetSSHCommand("sudo apt-get update && sudo apt-get install --upgrade lxc-docker")
-	if err != nil {
-		return err
-
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-
-	}
-
-	return cmd.Run()
-}
-
-func (d *Driver) StartDocker() error {
-	log.Debug("Starting Docker...")
-
-	cmd, err := d.GetSSHCommand("sudo service docker start")
-	if err != nil {
-		return err
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (d *Driver) StopDocker() error {
-	log.Debug("Stopping Docker...")
-
-	cmd, err := d.GetSSHCommand("sudo service docker stop")
-	if err != nil {
-		return err
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (d *Driver) GetDockerConfigDir() string {
-	return dockerConfigDir
+	return d.Stop(context.Background())
 }
 
 func (d *Driver) GetSSHCommand(args ...string) (*exec.Cmd, error) {
@@ -607,6 +564,66 @@ func (d *Driver) resolveIds() error {
 	return nil
 }
 
+// Plan authenticates against OpenStack, resolves the flavor, image, network
+// and floating IP pool and returns what Create would make.
+func (d *Driver) Plan() ([]drivers.PlannedResource, error) {
+	if err := d.initCompute(); err != nil {
+		return nil, err
+	}
+	if err := d.resolveIds(); err != nil {
+		return nil, err
+	}
+
+	resources := []drivers.PlannedResource{
+		{Action: "create", Type: "key pair", Name: d.MachineName + "-<random id>"},
+		{
+			Action: "create",
+			Type:   "instance",
+			Name:   d.MachineName,
+			Detail: fmt.Sprintf("flavor %s, image %s", d.FlavorId, d.ImageId),
+		},
+	}
+
+	if d.NetworkId != "" {
+		resources = append(resources, drivers.PlannedResource{
+			Action: "use",
+			Type:   "network",
+			Name:   d.NetworkName,
+			Detail: d.NetworkId,
+		})
+	}
+	for _, group := range d.SecurityGroups {
+		resources = append(resources, drivers.PlannedResource{
+			Action: "use",
+			Type:   "security group",
+			Name:   group,
+		})
+	}
+
+	if d.FloatingIpPool != "" {
+		ips, err := d.client.GetFloatingIPs(d)
+		if err != nil {
+			return nil, err
+		}
+
+		action := "create"
+		for _, ip := range ips {
+			if ip.PortId == "" {
+				action = "use"
+				break
+			}
+		}
+		resources = append(resources, drivers.PlannedResource{
+			Action: action,
+			Type:   "floating IP",
+			Name:   d.FloatingIpPool,
+			Detail: d.FloatingIpPoolId,
+		})
+	}
+
+	return resources, nil
+}
+
 func (d *Driver) initCompute() error {
 	if err := d.client.Authenticate(d); err != nil {
 		return err
@@ -711,9 +728,9 @@ func (d *Driver) assignFloatingIp() error {
 	return nil
 }
 
-func (d *Driver) waitForInstanceActive() error {
+func (d *Driver) waitForInstanceActive(ctx context.Context) error {
 	log.WithField("MachineId", d.MachineId).Debug("Waiting for the OpenStack instance to be ACTIVE...")
-	if err := d.client.WaitForInstanceStatus(d, "ACTIVE", 200); err != nil {
+	if err := d.client.WaitForInstanceStatus(ctx, d, "ACTIVE", 200); err != nil {
 		return err
 	}
 	return nil
@@ -732,7 +749,7 @@ func (d *Driver) lookForIpAddress() error {
 	return nil
 }
 
-func (d *Driver) waitForSSHServer() error {
+func (d *Driver) waitForSSHServer(ctx context.Context) error {
 	ip, err := d.GetIP()
 	if err != nil {
 		return err
@@ -741,50 +758,14 @@ func (d *Driver) waitForSSHServer() error {
 		"MachineId": d.MachineId,
 		"IP":        ip,
 	}).Debug("Waiting for the SSH server to be started...")
-	return ssh.WaitForTCP(fmt.Sprintf("%s:%d", ip, d.SSHPort))
+	return ssh.WaitForTCP(ctx, fmt.Sprintf("%s:%d", ip, d.SSHPort))
 }
 
-func (d *Driver) waitForInstanceToStart() error {
-	if err := d.waitForInstanceActive(); err != nil {
+func (d *Driver) waitForInstanceToStart(ctx context.Context) error {
+	if err := d.waitForInstanceActive(ctx); err != nil {
 		return err
 	}
-	return d.waitForSSHServer()
-}
-
-func (d *Driver) installDocker() error {
-	log.WithField("MachineId", d.MachineId).Debug("Installing docker daemon on the machine")
-
-	if err := d.sshExec([]string{
-		`apt-get install -y curl`,
-		`curl -sSL https://get.docker.com | sh`,
-	}); err != nil {
-		log.Error("The docker installation failed.")
-		log.Error(
-			"The driver assumes that your instance is running Ubuntu. If this is not the case, you should ",
-			"use the option --openstack-docker-install=false (or --{provider}-docker-install=false) when ",
-			"creating a machine, and then install and configure docker manually.",
-		)
-		log.Error(
-			`Also, you can use "machine ssh" to manually configure docker on this host.`,
-		)
-
-		// Don't return this ssh error so that host creation succeeds and "machine ssh" and "machine rm"
-		// are usable.
-	}
-	return nil
-}
-
-func (d *Driver) sshExec(commands []string) error {
-	for _, command := range commands {
-		sshCmd, err := d.GetSSHCommand(command)
-		if err != nil {
-			return err
-		}
-		if err := sshCmd.Run(); err != nil {
-			return err
-		}
-	}
-	return nil
+	return d.waitForSSHServer(ctx)
 }
 
 func (d *Driver) sshKeyPath() string {
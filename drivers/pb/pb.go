@@ -16,10 +16,8 @@ import (
 	"github.com/docker/machine/drivers"
 	"github.com/docker/machine/ssh"
 	"github.com/docker/machine/state"
-)
-
-const (
-	dockerConfigDir = "/etc/docker"
+	"github.com/docker/machine/utils"
+	"golang.org/x/net/context"
 )
 
 type Driver struct {
@@ -264,16 +262,18 @@ func (d *Driver) PreCreateCheck() error {
 // CREATE
 //////////////
 
-func (d *Driver) Create() error {
+func (d *Driver) Create(ctx context.Context) error {
 	//d.IPAddress = "127.0.0.1"
 	key, err := d.createSSHKey()
 	if err != nil {
 		return err
 	}
-	log.Infof("User ---- %v", d)
+	log.WithFields(log.Fields{
+		"user":   d.User,
+		"vdc":    d.VDCName,
+		"sshKey": key,
+	}).Debug("Creating ProfitBricks server")
 	//Get vdc ID from name
-	log.Infof("%s", d.VDCName)
-	log.Infof(" ssssssssssssssss -------------- %+v", key)
 
 	soapreq_str := `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:ws="http://ws.api.profitbricks.com/">
 					<soapenv:Header>
@@ -295,8 +295,7 @@ func (d *Driver) Create() error {
 	v3 := VDCResponse{}
     err = xml.Unmarshal([]byte(s), &v3)
 	if err != nil {
-		log.Infof("error: %v", err)
-		log.Infof("Return XML  - %s", s)
+		log.Debugf("Return XML  - %s", s)
 		return err
 	}
 	log.Infof("%s", v3.RespBody.VDCResposne.Ret.DataCenterName)
@@ -337,8 +336,7 @@ func (d *Driver) Create() error {
 	v := StorageResponse{}
     err = xml.Unmarshal([]byte(s), &v)
 	if err != nil {
-		log.Infof("error: %v", err)
-		log.Infof("Return XML  - %s", s)
+		log.Debugf("Return XML  - %s", s)
 		return err
 	}
 	if v.RespBody.StrgRet.Ret.StorageId == ""{
@@ -387,8 +385,7 @@ func (d *Driver) Create() error {
 	v1 := ServerResponse{}
     err = xml.Unmarshal([]byte(s), &v1)
 	if err != nil {
-		log.Infof("error: %v", err)
-		log.Infof("Return XML  - %s", s)
+		log.Debugf("Return XML  - %s", s)
 		return err
 	}
 	if v1.RespBody.ServerRet.Ret.ServerId == ""{
@@ -397,9 +394,9 @@ func (d *Driver) Create() error {
 		return nil	
 	}
 
-	//Ping the server to see it is ready
-	i = 0
-	for {
+	//Ping the server to see it is ready, not more than 100 tries
+	backoff := utils.Backoff{Interval: 10 * time.Second, MaxAttempts: 100}
+	err = utils.WaitFor(ctx, "the server to run", backoff, func() error {
 		log.Infof("Pinging the server ....")
 		soapreq_str = `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:ws="http://ws.api.profitbricks.com/">
 						<soapenv:Header>
@@ -413,22 +410,21 @@ func (d *Driver) Create() error {
 		soapreq_str = fmt.Sprintf(soapreq_str, v1.RespBody.ServerRet.Ret.ServerId)
 		s = makeReq(soapreq_str, d.User, d.Password)
 		v2 := GetServerResponse{}
-		err = xml.Unmarshal([]byte(s), &v2)
-		if err != nil {
+		if err := xml.Unmarshal([]byte(s), &v2); err != nil {
 			log.Infof("error: %v", err)
 			log.Infof("Return XML  - %s", s)
-			return err
-		}
-		log.Infof("%s", v2.RespBody.GetServerResponse.Ret.VirtualMachineState)
-		if v2.RespBody.GetServerResponse.Ret.VirtualMachineState == "RUNNING"{
-			d.IPAddress = v2.RespBody.GetServerResponse.Ret.Ips
-			break
+			return utils.Permanent(err)
 		}
-		i = i + 1
-		time.Sleep(10 * time.Second)
-		if i == 100 { //Not more than 100 try
-			break
+		serverState := v2.RespBody.GetServerResponse.Ret.VirtualMachineState
+		log.Infof("%s", serverState)
+		if serverState != "RUNNING" {
+			return fmt.Errorf("server is %s", serverState)
 		}
+		d.IPAddress = v2.RespBody.GetServerResponse.Ret.Ips
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	//This is a new comment.
@@ -457,7 +453,7 @@ func (d *Driver) Create() error {
 // GET STATE
 ///////////////
 func (d *Driver) GetState() (state.State, error) {
-	return state.None, nil
+	return state.None, drivers.NotSupported(d, drivers.CapabilityState)
 }
 
 ////////////////
@@ -465,15 +461,15 @@ func (d *Driver) GetState() (state.State, error) {
 ///////////////
 
 func (d *Driver) Kill() error {
-	return nil
+	return drivers.NotSupported(d, drivers.CapabilityKill)
 }
 
 ///////////////
 // Remove
 //////////////
 
-func (d *Driver) Remove() error {
-	return nil
+func (d *Driver) Remove(ctx context.Context) error {
+	return drivers.NotSupported(d, drivers.CapabilityRemove)
 }
 
 //////////////
@@ -481,80 +477,51 @@ func (d *Driver) Remove() error {
 /////////////
 
 func (d *Driver) Restart() error {
-	return nil
+	return drivers.NotSupported(d, drivers.CapabilityRestart)
 }
 
 /////////////
 // Start
 /////////////
-func (d *Driver) Start() error {
-	return nil
+func (d *Driver) Start(ctx context.Context) error {
+	return drivers.NotSupported(d, drivers.CapabilityStart)
 }
 
 //////////////
 // Start docker
 //////////////
 
-func (d *Driver) StartDocker() error {
-	log.Debug("Starting Docker...")
-
-	cmd, err := d.GetSSHCommand("sudo service docker start")
-	if err != nil {
-		return err
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
 ///////////////
 // Stop
 //////////////
 
-func (d *Driver) Stop() error {
-	return nil
+func (d *Driver) Stop(ctx context.Context) error {
+	return drivers.NotSupported(d, drivers.CapabilityStop)
+}
+
+// Unsupported lists the operations which are not implemented for
+// ProfitBricks yet. The driver is not a drivers.UserDataSetter either, as
+// the createServer and createStorage calls of the SOAP API it uses take no
+// user data.
+func (d *Driver) Unsupported() []string {
+	return []string{
+		drivers.CapabilityState,
+		drivers.CapabilityKill,
+		drivers.CapabilityRemove,
+		drivers.CapabilityRestart,
+		drivers.CapabilityStart,
+		drivers.CapabilityStop,
+	}
 }
 
 ///////////////
 // Stop docker
 //////////////
 
-func (d *Driver) StopDocker() error {
-	log.Debug("Stopping Docker...")
-
-	cmd, err := d.GetSSHCommand("sudo service docker stop")
-	if err != nil {
-		return err
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
 //////////////
 // Upgrade
 /////////////
 
-func (d *Driver) Upgrade() error {
-	log.Debugf("Upgrading Docker")
-
-	cmd, err := d.GetSSHCommand("sudo apt-get update && apt-get install --upgrade lxc-docker")
-	if err != nil {
-		return err
-
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-
-	}
-
-	return cmd.Run()
-}
-
 func (d *Driver) GetURL() (string, error) {
 	ip, err := d.GetIP()
 	if err != nil {
@@ -592,10 +559,6 @@ func (d *Driver) sshKeyPath() string {
 	return filepath.Join(d.storePath, "id_rsa")
 }
 
-func (d *Driver) GetDockerConfigDir() string {
-	return dockerConfigDir
-}
-
 func (d *Driver) publicSSHKeyPath() string {
 	return d.sshKeyPath() + ".pub"
 }
@@ -0,0 +1,377 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"net/rpc"
+	"os"
+	"os/exec"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/machine/drivers"
+	"github.com/docker/machine/state"
+	"golang.org/x/net/context"
+)
+
+// Driver is a driver served by a plugin. Each Driver runs its own plugin
+// process, which exits when machine does.
+type Driver struct {
+	name         string
+	client       *rpc.Client
+	flags        []Flag
+	capabilities map[string]bool
+
+	// userDataErr is returned by PreCreateCheck when user data was given to
+	// a plugin which does not support it.
+	userDataErr error
+}
+
+// start runs the plugin at path and connects to it.
+func start(path string) (*rpc.Client, *exec.Cmd, error) {
+	cmd := exec.Command(path)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("Error starting driver plugin %s: %s", path, err)
+	}
+
+	return rpc.NewClient(&pipeConn{Reader: stdout, Writer: stdin}), cmd, nil
+}
+
+// handshakeTimeout bounds the handshake with a plugin, so a plugin which
+// does not answer cannot hang machine. Plugins are found on every command.
+var handshakeTimeout = 5 * time.Second
+
+// handshake checks that the plugin speaks the protocol of this version of
+// machine and returns its create flags.
+func handshake(client *rpc.Client, path string) ([]Flag, error) {
+	var reply HandshakeReply
+	call := client.Go("Driver.Handshake", Empty{}, &reply, nil)
+	select {
+	case <-call.Done:
+	case <-time.After(handshakeTimeout):
+		return nil, fmt.Errorf("Driver plugin %s did not answer within %s", path, handshakeTimeout)
+	}
+	if call.Error != nil {
+		return nil, fmt.Errorf("Error talking to driver plugin %s: %s", path, call.Error)
+	}
+	if reply.ProtocolVersion != ProtocolVersion {
+		return nil, fmt.Errorf("Driver plugin %s speaks protocol version %d, machine needs version %d",
+			path, reply.ProtocolVersion, ProtocolVersion)
+	}
+	return reply.Flags, nil
+}
+
+// GetCreateFlags runs the plugin at path to get its create flags.
+func GetCreateFlags(path string) ([]Flag, error) {
+	client, cmd, err := start(path)
+	if err != nil {
+		return nil, err
+	}
+
+	flags, err := handshake(client, path)
+	client.Close()
+	if err != nil {
+		cmd.Process.Kill()
+	}
+	cmd.Wait()
+
+	return flags, err
+}
+
+// NewDriver runs the plugin at path and creates a driver in it.
+func NewDriver(path string, machineName string, storePath string, caCert string, privateKey string) (*Driver, error) {
+	client, cmd, err := start(path)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := newDriver(client, path, NewArgs{
+		MachineName: machineName,
+		StorePath:   storePath,
+		CaCert:      caCert,
+		PrivateKey:  privateKey,
+	})
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, err
+	}
+	return d, nil
+}
+
+func newDriver(client *rpc.Client, path string, args NewArgs) (*Driver, error) {
+	flags, err := handshake(client, path)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	var reply NewReply
+	if err := client.Call("Driver.New", args, &reply); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	capabilities := map[string]bool{}
+	for _, capability := range reply.Capabilities {
+		capabilities[capability] = true
+	}
+
+	return &Driver{
+		name:         reply.DriverName,
+		client:       client,
+		flags:        flags,
+		capabilities: capabilities,
+	}, nil
+}
+
+// call calls the method of the plugin, turning the errors machine checks
+// for back into the errors of the drivers package.
+func (d *Driver) call(method string, args interface{}, reply interface{}) error {
+	return d.translate(method, d.client.Call("Driver."+method, args, reply))
+}
+
+// translate turns the error of a call to method back into the error of the
+// drivers package it stands for.
+func (d *Driver) translate(method string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	log.Debugf("%s driver plugin: %s failed: %s", d.name, method, err)
+
+	switch err.Error() {
+	case drivers.ErrHostIsNotRunning.Error():
+		return drivers.ErrHostIsNotRunning
+	case drivers.ErrAdoptNotSupported.Error():
+		return drivers.ErrAdoptNotSupported
+	}
+	if serverErr, ok := err.(rpc.ServerError); ok {
+		return errors.New(string(serverErr))
+	}
+	return fmt.Errorf("Error talking to the %s driver plugin: %s", d.name, err)
+}
+
+func (d *Driver) DriverName() string {
+	return d.name
+}
+
+// Unsupported returns the capabilities the driver in the plugin lacks. As
+// Driver implements all the optional driver interfaces, their methods fail
+// with drivers.ErrNotSupported without calling the plugin.
+func (d *Driver) Unsupported() []string {
+	unsupported := []string{}
+	for _, capability := range drivers.AllCapabilities {
+		if !d.capabilities[capability] {
+			unsupported = append(unsupported, capability)
+		}
+	}
+	return unsupported
+}
+
+// callContext calls the method of the plugin which takes a context with
+// args. When ctx is done, the plugin is told to cancel the call, which then
+// returns once the driver in the plugin has given up.
+func (d *Driver) callContext(ctx context.Context, capability string, method string, args interface{}) error {
+	if capability != "" && !d.capabilities[capability] {
+		return drivers.NotSupported(d, capability)
+	}
+
+	call := d.client.Go("Driver."+method, args, &Empty{}, nil)
+	select {
+	case <-call.Done:
+	case <-ctx.Done():
+		log.Debugf("%s driver plugin: cancelling %s", d.name, method)
+		if err := d.client.Call("Driver.Cancel", Empty{}, &Empty{}); err != nil {
+			return fmt.Errorf("Error talking to the %s driver plugin: %s", d.name, err)
+		}
+		<-call.Done
+	}
+	return d.translate(method, call.Error)
+}
+
+// callIfSupported calls the method of the plugin which needs capability.
+func (d *Driver) callIfSupported(capability string, method string, args interface{}, reply interface{}) error {
+	if !d.capabilities[capability] {
+		return drivers.NotSupported(d, capability)
+	}
+	return d.call(method, args, reply)
+}
+
+// MarshalJSON returns the configuration of the driver in the plugin, which
+// is saved with the host.
+func (d *Driver) MarshalJSON() ([]byte, error) {
+	var data []byte
+	if err := d.call("GetConfig", Empty{}, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// UnmarshalJSON passes a configuration saved with the host to the driver in
+// the plugin.
+func (d *Driver) UnmarshalJSON(data []byte) error {
+	return d.call("SetConfig", data, &Empty{})
+}
+
+func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	return d.call("SetConfigFromFlags", readOptions(d.flags, flags), &Empty{})
+}
+
+func (d *Driver) GetURL() (string, error) {
+	var url string
+	err := d.call("GetURL", Empty{}, &url)
+	return url, err
+}
+
+func (d *Driver) GetIP() (string, error) {
+	var ip string
+	err := d.call("GetIP", Empty{}, &ip)
+	return ip, err
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	var st state.State
+	err := d.callIfSupported(drivers.CapabilityState, "GetState", Empty{}, &st)
+	return st, err
+}
+
+func (d *Driver) PreCreateCheck() error {
+	if d.userDataErr != nil {
+		return d.userDataErr
+	}
+	return d.call("PreCreateCheck", Empty{}, &Empty{})
+}
+
+func (d *Driver) Create(ctx context.Context) error {
+	return d.callContext(ctx, "", "Create", Empty{})
+}
+
+func (d *Driver) Remove(ctx context.Context) error {
+	return d.callContext(ctx, drivers.CapabilityRemove, "Remove", Empty{})
+}
+
+func (d *Driver) Start(ctx context.Context) error {
+	return d.callContext(ctx, drivers.CapabilityStart, "Start", Empty{})
+}
+
+func (d *Driver) Stop(ctx context.Context) error {
+	return d.callContext(ctx, drivers.CapabilityStop, "Stop", Empty{})
+}
+
+func (d *Driver) Restart() error {
+	return d.callIfSupported(drivers.CapabilityRestart, "Restart", Empty{}, &Empty{})
+}
+
+func (d *Driver) Kill() error {
+	return d.callIfSupported(drivers.CapabilityKill, "Kill", Empty{}, &Empty{})
+}
+
+// GetSSHCommand returns the command built by the plugin, to be run by
+// machine.
+func (d *Driver) GetSSHCommand(args ...string) (*exec.Cmd, error) {
+	var reply SSHCommand
+	if err := d.callIfSupported(drivers.CapabilitySSH, "GetSSHCommand", args, &reply); err != nil {
+		return nil, err
+	}
+
+	cmd := &exec.Cmd{
+		Path: reply.Path,
+		Args: reply.Args,
+		Env:  reply.Env,
+	}
+	return cmd, nil
+}
+
+func (d *Driver) Adopt(ctx context.Context, flags drivers.DriverOptions) error {
+	if !d.capabilities[drivers.CapabilityAdopt] {
+		return drivers.ErrAdoptNotSupported
+	}
+
+	// The adopt flags are not among the create flags of the plugin
+	adoptFlags := append([]Flag{}, d.flags...)
+	for _, f := range drivers.AdoptFlags {
+		if flag, ok := newFlag(f); ok {
+			adoptFlags = append(adoptFlags, flag)
+		}
+	}
+	return d.callContext(ctx, "", "Adopt", readOptions(adoptFlags, flags))
+}
+
+func (d *Driver) Upgrade() error {
+	return d.callIfSupported(drivers.CapabilityUpgrade, "Upgrade", Empty{}, &Empty{})
+}
+
+func (d *Driver) Pause() error {
+	return d.callIfSupported(drivers.CapabilityPause, "Pause", Empty{}, &Empty{})
+}
+
+func (d *Driver) Unpause() error {
+	return d.callIfSupported(drivers.CapabilityPause, "Unpause", Empty{}, &Empty{})
+}
+
+func (d *Driver) Resize(cpus int, memory int) error {
+	return d.callIfSupported(drivers.CapabilityResize, "Resize", ResizeArgs{CPUs: cpus, Memory: memory}, &Empty{})
+}
+
+func (d *Driver) Snapshot(name string) error {
+	return d.callIfSupported(drivers.CapabilitySnapshot, "Snapshot", name, &Empty{})
+}
+
+func (d *Driver) RestoreSnapshot(name string) error {
+	return d.callIfSupported(drivers.CapabilitySnapshot, "RestoreSnapshot", name, &Empty{})
+}
+
+func (d *Driver) GetProviderState() (string, error) {
+	var providerState string
+	err := d.callIfSupported(drivers.CapabilityProviderState, "GetProviderState", Empty{}, &providerState)
+	return providerState, err
+}
+
+// SetLabels passes the labels on if the driver in the plugin is a
+// drivers.Labeler.
+func (d *Driver) SetLabels(labels map[string]string) {
+	if !d.capabilities[drivers.CapabilityLabels] {
+		return
+	}
+	if err := d.call("SetLabels", labels, &Empty{}); err != nil {
+		log.Warnf("Error setting labels: %s", err)
+	}
+}
+
+// SetUserData passes the user data on if the driver in the plugin is a
+// drivers.UserDataSetter. Otherwise the host is not created.
+func (d *Driver) SetUserData(userData []byte) {
+	if !d.capabilities[drivers.CapabilityUserData] {
+		d.userDataErr = fmt.Errorf("The %s driver does not support --user-data", d.name)
+		return
+	}
+	d.userDataErr = d.call("SetUserData", userData, &Empty{})
+}
+
+// Plan returns no resources, as for drivers which cannot plan, unless the
+// driver in the plugin is a drivers.Planner.
+func (d *Driver) Plan() ([]drivers.PlannedResource, error) {
+	if !d.capabilities[drivers.CapabilityPlan] {
+		return nil, nil
+	}
+	var resources []drivers.PlannedResource
+	if err := d.call("Plan", Empty{}, &resources); err != nil {
+		return nil, err
+	}
+	if resources == nil {
+		resources = []drivers.PlannedResource{}
+	}
+	return resources, nil
+}
@@ -0,0 +1,106 @@
+package plugin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/codegangsta/cli"
+	"github.com/docker/machine/drivers"
+)
+
+// Find returns the plugin executables in the directories of path, a list
+// like $PATH, by driver name. As for commands, the first one found wins.
+func Find(path string) map[string]string {
+	plugins := map[string]string{}
+
+	for _, dir := range filepath.SplitList(path) {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, f := range files {
+			name := f.Name()
+			if f.IsDir() || !strings.HasPrefix(name, ExecutablePrefix) {
+				continue
+			}
+			if runtime.GOOS == "windows" {
+				if !strings.EqualFold(filepath.Ext(name), ".exe") {
+					continue
+				}
+				name = strings.TrimSuffix(name, filepath.Ext(name))
+			} else if f.Mode()&0111 == 0 {
+				continue
+			}
+
+			name = strings.TrimPrefix(name, ExecutablePrefix)
+			if _, found := plugins[name]; name != "" && !found {
+				plugins[name] = filepath.Join(dir, f.Name())
+			}
+		}
+	}
+
+	return plugins
+}
+
+// Register registers the plugins found in path with the drivers package.
+// Drivers built into machine take precedence over plugins with the same
+// name. The plugins are run at the same time to get their create flags, so
+// those which do not answer delay machine by one handshake timeout at most.
+func Register(path string) {
+	found := Find(path)
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		flags = make(map[string][]Flag, len(found))
+		errs  = make(map[string]error, len(found))
+	)
+	for name, pluginPath := range found {
+		wg.Add(1)
+		go func(name string, pluginPath string) {
+			defer wg.Done()
+			f, err := GetCreateFlags(pluginPath)
+			mu.Lock()
+			flags[name], errs[name] = f, err
+			mu.Unlock()
+		}(name, pluginPath)
+	}
+	wg.Wait()
+
+	for name, pluginPath := range found {
+		err := errs[name]
+		if err == nil {
+			err = register(name, pluginPath, flags[name])
+		}
+		if err != nil {
+			log.Warnf("Ignoring driver plugin %s: %s", pluginPath, err)
+		}
+	}
+}
+
+func register(name string, path string, flags []Flag) error {
+	// The flags are added to "machine create" along with those of every
+	// other driver, so they must not clash with them.
+	cliFlags := []cli.Flag{}
+	for _, f := range flags {
+		if !strings.HasPrefix(f.key(), name+"-") {
+			return fmt.Errorf("create flag %s does not start with %s-", f.key(), name)
+		}
+		cliFlags = append(cliFlags, f.cliFlag())
+	}
+
+	return drivers.RegisterPlugin(name, &drivers.RegisteredDriver{
+		New: func(machineName string, storePath string, caCert string, privateKey string) (drivers.Driver, error) {
+			return NewDriver(path, machineName, storePath, caCert, privateKey)
+		},
+		GetCreateFlags: func() []cli.Flag {
+			return cliFlags
+		},
+	})
+}
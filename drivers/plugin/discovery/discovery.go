@@ -0,0 +1,14 @@
+// Package discovery registers the driver plugins found on $PATH when it is
+// imported. It is separate from the plugin package so that plugins, which
+// import that one, do not look for plugins themselves.
+package discovery
+
+import (
+	"os"
+
+	"github.com/docker/machine/drivers/plugin"
+)
+
+func init() {
+	plugin.Register(os.Getenv("PATH"))
+}
@@ -0,0 +1,168 @@
+// Package plugin runs drivers out of process. A plugin is an executable
+// named docker-machine-driver-<name> which calls Serve with its driver. It
+// serves the driver with net/rpc over its standard input and output, and
+// machine talks to it through Driver, which implements drivers.Driver.
+package plugin
+
+import (
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/docker/machine/drivers"
+)
+
+const (
+	// ExecutablePrefix is the prefix of the name of plugin executables.
+	ExecutablePrefix = "docker-machine-driver-"
+
+	// ProtocolVersion is increased whenever the RPC protocol changes in a
+	// way older plugins or older versions of machine cannot handle.
+	ProtocolVersion = 3
+)
+
+// Empty is the argument or reply of calls which do not need one.
+type Empty struct{}
+
+// HandshakeReply is the reply to the first call machine makes to a plugin.
+type HandshakeReply struct {
+	ProtocolVersion int
+	Flags           []Flag
+}
+
+// NewArgs are the arguments of drivers.RegisteredDriver.New.
+type NewArgs struct {
+	MachineName string
+	StorePath   string
+	CaCert      string
+	PrivateKey  string
+}
+
+// NewReply describes the driver created by the plugin.
+type NewReply struct {
+	DriverName string
+
+	// Capabilities are those of drivers.Capabilities for the driver.
+	Capabilities []string
+}
+
+// ResizeArgs are the arguments of drivers.Resizer.Resize.
+type ResizeArgs struct {
+	CPUs   int
+	Memory int
+}
+
+// SSHCommand is the command returned by GetSSHCommand, which machine runs
+// itself.
+type SSHCommand struct {
+	Path string
+	Args []string
+	Env  []string
+}
+
+// Flag describes a create flag of a plugin driver, as cli.Flag is an
+// interface which cannot be sent as is.
+type Flag struct {
+	Kind       string
+	Name       string
+	Usage      string
+	EnvVar     string
+	Value      string
+	IntValue   int
+	SliceValue []string
+}
+
+// newFlag describes f. Only the kinds of flags drivers.DriverOptions can
+// read are supported.
+func newFlag(f cli.Flag) (Flag, bool) {
+	switch f := f.(type) {
+	case cli.StringFlag:
+		return Flag{Kind: "string", Name: f.Name, Usage: f.Usage, EnvVar: f.EnvVar, Value: f.Value}, true
+	case cli.IntFlag:
+		return Flag{Kind: "int", Name: f.Name, Usage: f.Usage, EnvVar: f.EnvVar, IntValue: f.Value}, true
+	case cli.BoolFlag:
+		return Flag{Kind: "bool", Name: f.Name, Usage: f.Usage, EnvVar: f.EnvVar}, true
+	case cli.BoolTFlag:
+		return Flag{Kind: "boolt", Name: f.Name, Usage: f.Usage, EnvVar: f.EnvVar}, true
+	case cli.StringSliceFlag:
+		flag := Flag{Kind: "stringslice", Name: f.Name, Usage: f.Usage, EnvVar: f.EnvVar}
+		if f.Value != nil {
+			flag.SliceValue = f.Value.Value()
+		}
+		return flag, true
+	}
+	return Flag{}, false
+}
+
+// key is the name the value of the flag is read with.
+func (f Flag) key() string {
+	return strings.TrimSpace(strings.Split(f.Name, ",")[0])
+}
+
+// cliFlag returns the flag to add to "machine create".
+func (f Flag) cliFlag() cli.Flag {
+	switch f.Kind {
+	case "int":
+		return cli.IntFlag{Name: f.Name, Usage: f.Usage, EnvVar: f.EnvVar, Value: f.IntValue}
+	case "bool":
+		return cli.BoolFlag{Name: f.Name, Usage: f.Usage, EnvVar: f.EnvVar}
+	case "boolt":
+		return cli.BoolTFlag{Name: f.Name, Usage: f.Usage, EnvVar: f.EnvVar}
+	case "stringslice":
+		value := cli.StringSlice(append([]string{}, f.SliceValue...))
+		return cli.StringSliceFlag{Name: f.Name, Usage: f.Usage, EnvVar: f.EnvVar, Value: &value}
+	}
+	return cli.StringFlag{Name: f.Name, Usage: f.Usage, EnvVar: f.EnvVar, Value: f.Value}
+}
+
+// Options holds the values of the create flags of a plugin driver, read on
+// the machine side, and implements drivers.DriverOptions in the plugin.
+type Options struct {
+	Strings      map[string]string
+	Ints         map[string]int
+	Bools        map[string]bool
+	StringSlices map[string][]string
+}
+
+func newOptions() Options {
+	return Options{
+		Strings:      map[string]string{},
+		Ints:         map[string]int{},
+		Bools:        map[string]bool{},
+		StringSlices: map[string][]string{},
+	}
+}
+
+// readOptions reads the values of flags from opts.
+func readOptions(flags []Flag, opts drivers.DriverOptions) Options {
+	options := newOptions()
+	for _, f := range flags {
+		key := f.key()
+		switch f.Kind {
+		case "int":
+			options.Ints[key] = opts.Int(key)
+		case "bool", "boolt":
+			options.Bools[key] = opts.Bool(key)
+		case "stringslice":
+			options.StringSlices[key] = opts.StringSlice(key)
+		default:
+			options.Strings[key] = opts.String(key)
+		}
+	}
+	return options
+}
+
+func (o Options) String(key string) string {
+	return o.Strings[key]
+}
+
+func (o Options) Int(key string) int {
+	return o.Ints[key]
+}
+
+func (o Options) Bool(key string) bool {
+	return o.Bools[key]
+}
+
+func (o Options) StringSlice(key string) []string {
+	return o.StringSlices[key]
+}
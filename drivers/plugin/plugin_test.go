@@ -0,0 +1,388 @@
+package plugin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/docker/machine/drivers"
+	"github.com/docker/machine/state"
+	"golang.org/x/net/context"
+)
+
+type testDriver struct {
+	MachineName string
+	Size        int
+	Tags        []string
+	Labels      map[string]string
+	Created     bool
+
+	// Block makes Create wait until it is cancelled.
+	Block bool
+}
+
+func (d *testDriver) DriverName() string { return "test" }
+
+func (d *testDriver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.Size = flags.Int("test-size")
+	d.Tags = flags.StringSlice("test-tag")
+	return nil
+}
+
+func (d *testDriver) GetURL() (string, error) {
+	if !d.Created {
+		return "", drivers.ErrHostIsNotRunning
+	}
+	return "tcp://1.2.3.4:2376", nil
+}
+
+func (d *testDriver) GetIP() (string, error) { return "1.2.3.4", nil }
+
+func (d *testDriver) GetState() (state.State, error) {
+	if !d.Created {
+		return state.None, nil
+	}
+	return state.Running, nil
+}
+
+func (d *testDriver) PreCreateCheck() error { return nil }
+
+func (d *testDriver) Create(ctx context.Context) error {
+	if d.Block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	d.Created = true
+	return nil
+}
+
+func (d *testDriver) Remove(ctx context.Context) error { return nil }
+func (d *testDriver) Start(ctx context.Context) error  { return nil }
+func (d *testDriver) Stop(ctx context.Context) error   { return nil }
+func (d *testDriver) Restart() error                   { return nil }
+func (d *testDriver) Kill() error                      { return nil }
+
+func (d *testDriver) GetSSHCommand(args ...string) (*exec.Cmd, error) {
+	return exec.Command("ssh", append([]string{"docker@1.2.3.4"}, args...)...), nil
+}
+
+func (d *testDriver) SetLabels(labels map[string]string) {
+	d.Labels = labels
+}
+
+type testOptions map[string]interface{}
+
+func (o testOptions) String(key string) string {
+	v, _ := o[key].(string)
+	return v
+}
+
+func (o testOptions) Int(key string) int {
+	v, _ := o[key].(int)
+	return v
+}
+
+func (o testOptions) Bool(key string) bool {
+	v, _ := o[key].(bool)
+	return v
+}
+
+func (o testOptions) StringSlice(key string) []string {
+	v, _ := o[key].([]string)
+	return v
+}
+
+// adoptingDriver is a testDriver which can adopt hosts.
+type adoptingDriver struct {
+	testDriver
+	InstanceId string
+	SSHUser    string
+}
+
+func (d *adoptingDriver) Adopt(ctx context.Context, flags drivers.DriverOptions) error {
+	d.SetConfigFromFlags(flags)
+	d.InstanceId = flags.String("instance-id")
+	d.SSHUser = flags.String("ssh-user")
+	d.Created = true
+	return nil
+}
+
+// connect serves a testDriver in process and returns the plugin driver
+// talking to it, along with the driver itself.
+func connect(t *testing.T) (*Driver, *testDriver) {
+	d := &testDriver{}
+	return connectDriver(t, d, &d.MachineName), d
+}
+
+// connectDriver serves d in process and returns the plugin driver talking
+// to it. The name of the machine is stored in machineName.
+func connectDriver(t *testing.T, d drivers.Driver, machineName *string) *Driver {
+	registered := &drivers.RegisteredDriver{
+		New: func(name string, storePath string, caCert string, privateKey string) (drivers.Driver, error) {
+			*machineName = name
+			return d, nil
+		},
+		GetCreateFlags: func() []cli.Flag {
+			return []cli.Flag{
+				cli.IntFlag{Name: "test-size", Value: 10},
+				cli.StringSliceFlag{Name: "test-tag", Value: &cli.StringSlice{}},
+			}
+		},
+	}
+
+	serverConn, clientConn := net.Pipe()
+	go serveConn(registered, serverConn)
+
+	driver, err := newDriver(rpc.NewClient(clientConn), "test", NewArgs{MachineName: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return driver
+}
+
+func TestDriver(t *testing.T) {
+	driver, d := connect(t)
+	defer driver.client.Close()
+
+	if driver.DriverName() != "test" {
+		t.Fatalf("expected driver name test, got %s", driver.DriverName())
+	}
+	if d.MachineName != "foo" {
+		t.Fatalf("expected machine name foo, got %s", d.MachineName)
+	}
+
+	if len(driver.flags) != 2 || driver.flags[0].IntValue != 10 || driver.flags[1].Kind != "stringslice" {
+		t.Fatalf("unexpected flags %+v", driver.flags)
+	}
+
+	opts := testOptions{"test-size": 20, "test-tag": []string{"a", "b"}}
+	if err := driver.SetConfigFromFlags(opts); err != nil {
+		t.Fatal(err)
+	}
+	if d.Size != 20 || !reflect.DeepEqual(d.Tags, []string{"a", "b"}) {
+		t.Fatalf("flags were not passed to the driver: %+v", d)
+	}
+
+	if _, err := driver.GetURL(); err != drivers.ErrHostIsNotRunning {
+		t.Fatalf("expected ErrHostIsNotRunning, got %v", err)
+	}
+
+	if err := driver.Create(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	st, err := driver.GetState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st != state.Running {
+		t.Fatalf("expected state Running, got %s", st)
+	}
+
+	cmd, err := driver.GetSSHCommand("uptime")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cmd.Args, []string{"ssh", "docker@1.2.3.4", "uptime"}) {
+		t.Fatalf("unexpected ssh command %v", cmd.Args)
+	}
+}
+
+func TestDriverCancel(t *testing.T) {
+	driver, d := connect(t)
+	defer driver.client.Close()
+
+	d.Block = true
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := driver.Create(ctx)
+	if err == nil || err.Error() != context.Canceled.Error() {
+		t.Fatalf("expected create to be cancelled in the plugin, got %v", err)
+	}
+	if d.Created {
+		t.Fatal("expected the driver not to create the host")
+	}
+}
+
+func TestDriverConfig(t *testing.T) {
+	driver, d := connect(t)
+	defer driver.client.Close()
+
+	d.Size = 30
+	data, err := json.Marshal(driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.Size = 0
+	if err := json.Unmarshal(data, driver); err != nil {
+		t.Fatal(err)
+	}
+	if d.Size != 30 {
+		t.Fatalf("expected the config to be restored, got size %d", d.Size)
+	}
+}
+
+func TestDriverOptionalInterfaces(t *testing.T) {
+	driver, d := connect(t)
+	defer driver.client.Close()
+
+	driver.SetLabels(map[string]string{"env": "test"})
+	if d.Labels["env"] != "test" {
+		t.Fatalf("labels were not passed to the driver: %v", d.Labels)
+	}
+
+	if err := driver.Adopt(context.Background(), testOptions{}); err != drivers.ErrAdoptNotSupported {
+		t.Fatalf("expected ErrAdoptNotSupported, got %v", err)
+	}
+
+	if err := driver.Upgrade(); !drivers.IsNotSupported(err) {
+		t.Fatalf("expected upgrading not to be supported, got %v", err)
+	}
+	if err := driver.Pause(); !drivers.IsNotSupported(err) {
+		t.Fatalf("expected pausing not to be supported, got %v", err)
+	}
+
+	capabilities := drivers.Capabilities(driver)
+	if !reflect.DeepEqual(capabilities, drivers.Capabilities(d)) {
+		t.Fatalf("expected the capabilities of the driver in the plugin %v, got %v", drivers.Capabilities(d), capabilities)
+	}
+
+	resources, err := driver.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resources != nil {
+		t.Fatalf("expected no plan, got %v", resources)
+	}
+
+	driver.SetUserData([]byte("#cloud-config"))
+	if err := driver.PreCreateCheck(); err == nil {
+		t.Fatal("expected user data to fail the pre-create check")
+	}
+}
+
+func TestDriverAdopt(t *testing.T) {
+	d := &adoptingDriver{}
+	driver := connectDriver(t, d, &d.MachineName)
+	defer driver.client.Close()
+
+	flags := testOptions{"instance-id": "i-1234", "ssh-user": "ubuntu", "test-size": 20}
+	if err := driver.Adopt(context.Background(), flags); err != nil {
+		t.Fatal(err)
+	}
+	if d.InstanceId != "i-1234" || d.SSHUser != "ubuntu" {
+		t.Fatalf("expected the adopt flags to be passed to the driver; got %q and %q", d.InstanceId, d.SSHUser)
+	}
+	if d.Size != 20 {
+		t.Fatalf("expected the create flags to be passed to the driver; got size %d", d.Size)
+	}
+}
+
+func TestFlagRoundTrip(t *testing.T) {
+	flags := []cli.Flag{
+		cli.StringFlag{Name: "test-name, n", Usage: "name", EnvVar: "TEST_NAME", Value: "default"},
+		cli.IntFlag{Name: "test-size", Value: 10},
+		cli.BoolFlag{Name: "test-bool"},
+		cli.BoolTFlag{Name: "test-boolt"},
+		cli.StringSliceFlag{Name: "test-tag", Value: &cli.StringSlice{"a"}},
+	}
+
+	for _, f := range flags {
+		flag, ok := newFlag(f)
+		if !ok {
+			t.Fatalf("flag %v is not supported", f)
+		}
+		if !reflect.DeepEqual(flag.cliFlag(), f) {
+			t.Fatalf("expected %#v, got %#v", f, flag.cliFlag())
+		}
+	}
+
+	flag, _ := newFlag(flags[0])
+	if flag.key() != "test-name" {
+		t.Fatalf("expected key test-name, got %s", flag.key())
+	}
+
+	if _, ok := newFlag(cli.GenericFlag{Name: "test-generic"}); ok {
+		t.Fatal("expected generic flags not to be supported")
+	}
+}
+
+func TestFind(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are found by extension on windows")
+	}
+
+	first, err := ioutil.TempDir("", "machine-plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(first)
+	second, err := ioutil.TempDir("", "machine-plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(second)
+
+	files := []struct {
+		path string
+		mode os.FileMode
+	}{
+		{filepath.Join(first, ExecutablePrefix+"foo"), 0755},
+		{filepath.Join(first, ExecutablePrefix+"bar"), 0644},
+		{filepath.Join(first, "docker-machine"), 0755},
+		{filepath.Join(second, ExecutablePrefix+"foo"), 0755},
+		{filepath.Join(second, ExecutablePrefix+"baz"), 0755},
+	}
+	for _, f := range files {
+		if err := ioutil.WriteFile(f.path, []byte{}, f.mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found := Find(first + string(filepath.ListSeparator) + second)
+	expected := map[string]string{
+		"foo": filepath.Join(first, ExecutablePrefix+"foo"),
+		"baz": filepath.Join(second, ExecutablePrefix+"baz"),
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Fatalf("expected %v, got %v", expected, found)
+	}
+}
+
+func TestGetCreateFlagsTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the plugin is a shell script")
+	}
+
+	dir, err := ioutil.TempDir("", "machine-plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, ExecutablePrefix+"hung")
+	if err := ioutil.WriteFile(path, []byte("#!/bin/sh\nexec sleep 60\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(timeout time.Duration) { handshakeTimeout = timeout }(handshakeTimeout)
+	handshakeTimeout = 100 * time.Millisecond
+
+	start := time.Now()
+	if _, err := GetCreateFlags(path); err == nil {
+		t.Fatal("expected the handshake with a plugin which does not answer to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected the plugin to be killed after the timeout; took %s", elapsed)
+	}
+}
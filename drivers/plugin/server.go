@@ -0,0 +1,373 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/rpc"
+	"os"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/machine/drivers"
+	"github.com/docker/machine/state"
+	"github.com/docker/machine/utils"
+	"golang.org/x/net/context"
+)
+
+// Serve serves the driver over standard input and output until machine
+// closes them. It is called from the main function of a plugin executable.
+// Anything the driver writes to os.Stdout goes to standard error instead,
+// so it does not end up in the RPC stream. The plugin logs as machine does,
+// as JSON with --log-format json, and with the secrets of its driver
+// redacted.
+func Serve(registered *drivers.RegisteredDriver) {
+	if os.Getenv("DEBUG") != "" {
+		log.SetLevel(log.DebugLevel)
+	}
+	if os.Getenv("MACHINE_LOG_FORMAT") == "json" {
+		log.SetFormatter(&log.JSONFormatter{})
+	}
+	log.AddHook(utils.RedactHook{})
+	log.AddHook(utils.ProgressHook{})
+
+	conn := &pipeConn{Reader: os.Stdin, Writer: os.Stdout}
+	os.Stdout = os.Stderr
+
+	serveConn(registered, conn)
+}
+
+// serveConn serves the driver on conn until it is closed.
+func serveConn(registered *drivers.RegisteredDriver, conn io.ReadWriteCloser) {
+	server := rpc.NewServer()
+	if err := server.RegisterName("Driver", &Server{registered: registered}); err != nil {
+		log.Fatal(err)
+	}
+	server.ServeConn(conn)
+}
+
+// pipeConn joins a reader and a writer, e.g. standard input and output,
+// into a connection for net/rpc.
+type pipeConn struct {
+	io.Reader
+	io.Writer
+}
+
+func (c *pipeConn) Close() error {
+	var err error
+	if r, ok := c.Reader.(io.Closer); ok {
+		err = r.Close()
+	}
+	if w, ok := c.Writer.(io.Closer); ok {
+		if werr := w.Close(); err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+// Server is the RPC service of a plugin. Its methods mirror those of
+// drivers.Driver and are only called by Driver.
+type Server struct {
+	registered *drivers.RegisteredDriver
+	driver     drivers.Driver
+
+	// cancel cancels the context of the operation in progress, if any.
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+func (s *Server) Handshake(args Empty, reply *HandshakeReply) error {
+	reply.ProtocolVersion = ProtocolVersion
+	for _, f := range s.registered.GetCreateFlags() {
+		flag, ok := newFlag(f)
+		if !ok {
+			log.Warnf("Create flag %v of the plugin is not supported", f)
+			continue
+		}
+		reply.Flags = append(reply.Flags, flag)
+	}
+	return nil
+}
+
+func (s *Server) New(args NewArgs, reply *NewReply) error {
+	driver, err := s.registered.New(args.MachineName, args.StorePath, args.CaCert, args.PrivateKey)
+	if err != nil {
+		return err
+	}
+	s.driver = driver
+
+	reply.DriverName = driver.DriverName()
+	reply.Capabilities = drivers.Capabilities(driver)
+	return nil
+}
+
+// getDriver returns the driver created by New.
+func (s *Server) getDriver() (drivers.Driver, error) {
+	if s.driver == nil {
+		return nil, fmt.Errorf("no driver has been created")
+	}
+	return s.driver, nil
+}
+
+func (s *Server) GetConfig(args Empty, reply *[]byte) error {
+	d, err := s.getDriver()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	*reply = data
+	return nil
+}
+
+func (s *Server) SetConfig(args []byte, reply *Empty) error {
+	d, err := s.getDriver()
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(args, d); err != nil {
+		return err
+	}
+	utils.RegisterSecrets(d)
+	return nil
+}
+
+func (s *Server) SetConfigFromFlags(args Options, reply *Empty) error {
+	d, err := s.getDriver()
+	if err != nil {
+		return err
+	}
+	if err := d.SetConfigFromFlags(args); err != nil {
+		return err
+	}
+	utils.RegisterSecrets(d)
+	return nil
+}
+
+func (s *Server) GetURL(args Empty, reply *string) error {
+	d, err := s.getDriver()
+	if err != nil {
+		return err
+	}
+	*reply, err = d.GetURL()
+	return err
+}
+
+func (s *Server) GetIP(args Empty, reply *string) error {
+	d, err := s.getDriver()
+	if err != nil {
+		return err
+	}
+	*reply, err = d.GetIP()
+	return err
+}
+
+func (s *Server) GetState(args Empty, reply *state.State) error {
+	d, err := s.getDriver()
+	if err != nil {
+		return err
+	}
+	*reply, err = d.GetState()
+	return err
+}
+
+// call runs one of the methods of the driver which only return an error.
+func (s *Server) call(fn func(d drivers.Driver) error) error {
+	d, err := s.getDriver()
+	if err != nil {
+		return err
+	}
+	return fn(d)
+}
+
+// callContext runs one of the methods of the driver which take a context.
+// The context is cancelled by Cancel.
+func (s *Server) callContext(fn func(d drivers.Driver, ctx context.Context) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.mu.Lock()
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.cancel = nil
+		s.mu.Unlock()
+	}()
+
+	return s.call(func(d drivers.Driver) error {
+		return fn(d, ctx)
+	})
+}
+
+// Cancel cancels the operation in progress, which then returns.
+func (s *Server) Cancel(args Empty, reply *Empty) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	return nil
+}
+
+func (s *Server) PreCreateCheck(args Empty, reply *Empty) error {
+	return s.call(drivers.Driver.PreCreateCheck)
+}
+
+func (s *Server) Create(args Empty, reply *Empty) error {
+	return s.callContext(drivers.Driver.Create)
+}
+
+func (s *Server) Remove(args Empty, reply *Empty) error {
+	return s.callContext(drivers.Driver.Remove)
+}
+
+func (s *Server) Start(args Empty, reply *Empty) error {
+	return s.callContext(drivers.Driver.Start)
+}
+
+func (s *Server) Stop(args Empty, reply *Empty) error {
+	return s.callContext(drivers.Driver.Stop)
+}
+
+func (s *Server) Restart(args Empty, reply *Empty) error {
+	return s.call(drivers.Driver.Restart)
+}
+
+func (s *Server) Kill(args Empty, reply *Empty) error {
+	return s.call(drivers.Driver.Kill)
+}
+
+func (s *Server) GetSSHCommand(args []string, reply *SSHCommand) error {
+	d, err := s.getDriver()
+	if err != nil {
+		return err
+	}
+	cmd, err := d.GetSSHCommand(args...)
+	if err != nil {
+		return err
+	}
+	reply.Path = cmd.Path
+	reply.Args = cmd.Args
+	reply.Env = cmd.Env
+	return nil
+}
+
+func (s *Server) Adopt(args Options, reply *Empty) error {
+	return s.callContext(func(d drivers.Driver, ctx context.Context) error {
+		adopter, ok := d.(drivers.Adopter)
+		if !ok {
+			return drivers.ErrAdoptNotSupported
+		}
+		return adopter.Adopt(ctx, args)
+	})
+}
+
+func (s *Server) Upgrade(args Empty, reply *Empty) error {
+	return s.call(func(d drivers.Driver) error {
+		upgrader, ok := d.(drivers.Upgrader)
+		if !ok {
+			return drivers.NotSupported(d, drivers.CapabilityUpgrade)
+		}
+		return upgrader.Upgrade()
+	})
+}
+
+func (s *Server) SetLabels(args map[string]string, reply *Empty) error {
+	return s.call(func(d drivers.Driver) error {
+		if labeler, ok := d.(drivers.Labeler); ok {
+			labeler.SetLabels(args)
+		}
+		return nil
+	})
+}
+
+func (s *Server) SetUserData(args []byte, reply *Empty) error {
+	return s.call(func(d drivers.Driver) error {
+		setter, ok := d.(drivers.UserDataSetter)
+		if !ok {
+			return fmt.Errorf("The %s driver does not support --user-data", d.DriverName())
+		}
+		setter.SetUserData(args)
+		return nil
+	})
+}
+
+func (s *Server) Pause(args Empty, reply *Empty) error {
+	return s.call(func(d drivers.Driver) error {
+		pauser, ok := d.(drivers.Pauser)
+		if !ok {
+			return drivers.NotSupported(d, drivers.CapabilityPause)
+		}
+		return pauser.Pause()
+	})
+}
+
+func (s *Server) Unpause(args Empty, reply *Empty) error {
+	return s.call(func(d drivers.Driver) error {
+		pauser, ok := d.(drivers.Pauser)
+		if !ok {
+			return drivers.NotSupported(d, drivers.CapabilityPause)
+		}
+		return pauser.Unpause()
+	})
+}
+
+func (s *Server) Resize(args ResizeArgs, reply *Empty) error {
+	return s.call(func(d drivers.Driver) error {
+		resizer, ok := d.(drivers.Resizer)
+		if !ok {
+			return drivers.NotSupported(d, drivers.CapabilityResize)
+		}
+		return resizer.Resize(args.CPUs, args.Memory)
+	})
+}
+
+func (s *Server) Snapshot(args string, reply *Empty) error {
+	return s.call(func(d drivers.Driver) error {
+		snapshotter, ok := d.(drivers.Snapshotter)
+		if !ok {
+			return drivers.NotSupported(d, drivers.CapabilitySnapshot)
+		}
+		return snapshotter.Snapshot(args)
+	})
+}
+
+func (s *Server) RestoreSnapshot(args string, reply *Empty) error {
+	return s.call(func(d drivers.Driver) error {
+		snapshotter, ok := d.(drivers.Snapshotter)
+		if !ok {
+			return drivers.NotSupported(d, drivers.CapabilitySnapshot)
+		}
+		return snapshotter.RestoreSnapshot(args)
+	})
+}
+
+func (s *Server) GetProviderState(args Empty, reply *string) error {
+	return s.call(func(d drivers.Driver) error {
+		stater, ok := d.(drivers.ProviderStater)
+		if !ok {
+			return drivers.NotSupported(d, drivers.CapabilityProviderState)
+		}
+		providerState, err := stater.GetProviderState()
+		*reply = providerState
+		return err
+	})
+}
+
+func (s *Server) Plan(args Empty, reply *[]drivers.PlannedResource) error {
+	d, err := s.getDriver()
+	if err != nil {
+		return err
+	}
+	planner, ok := d.(drivers.Planner)
+	if !ok {
+		return nil
+	}
+	*reply, err = planner.Plan()
+	return err
+}
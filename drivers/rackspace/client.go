@@ -1,18 +1,13 @@
 package rackspace
 
 import (
-	"fmt"
-
 	log "github.com/Sirupsen/logrus"
+	"github.com/docker/machine/drivers"
 	"github.com/docker/machine/drivers/openstack"
 	"github.com/rackspace/gophercloud"
 	"github.com/rackspace/gophercloud/rackspace"
 )
 
-func unsupportedOpErr(operation string) error {
-	return fmt.Errorf("Rackspace does not currently support the %s operation", operation)
-}
-
 // Client is a Rackspace specialization of the generic OpenStack driver.
 type Client struct {
 	openstack.GenericClient
@@ -47,12 +42,12 @@ func (c *Client) Authenticate(d *openstack.Driver) error {
 
 // StartInstance is unfortunately not supported on Rackspace at this time.
 func (c *Client) StartInstance(d *openstack.Driver) error {
-	return unsupportedOpErr("start")
+	return &drivers.ErrNotSupported{Driver: "rackspace", Operation: drivers.CapabilityStart}
 }
 
 // StopInstance is unfortunately not support on Rackspace at this time.
 func (c *Client) StopInstance(d *openstack.Driver) error {
-	return unsupportedOpErr("stop")
+	return &drivers.ErrNotSupported{Driver: "rackspace", Operation: drivers.CapabilityStop}
 }
 
 // GetInstanceIpAddresses can be short-circuited with the server's AccessIPv4Addr on Rackspace.
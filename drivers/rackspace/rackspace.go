@@ -9,10 +9,6 @@ import (
 	"github.com/docker/machine/drivers/openstack"
 )
 
-const (
-	dockerConfigDir = "/etc/docker"
-)
-
 // Driver is a machine driver for Rackspace. It's a specialization of the generic OpenStack one.
 type Driver struct {
 	*openstack.Driver
@@ -90,9 +86,11 @@ func GetCreateFlags() []cli.Flag {
 			Usage: "SSH port for the newly booted machine. Set to 22 by default",
 			Value: 22,
 		},
+		// Kept so existing scripts keep working, Docker is installed by the
+		// provisioner when it is missing.
 		cli.StringFlag{
 			Name:  "rackspace-docker-install",
-			Usage: "Set if docker have to be installed on the machine",
+			Usage: "Deprecated, Docker is installed on the machine when it is missing",
 			Value: "true",
 		},
 	}
@@ -123,8 +121,10 @@ func (d *Driver) DriverName() string {
 	return "rackspace"
 }
 
-func (d *Driver) GetDockerConfigDir() string {
-	return dockerConfigDir
+// Unsupported lists the operations Rackspace does not offer, killing an
+// instance being the same as stopping it.
+func (d *Driver) Unsupported() []string {
+	return []string{drivers.CapabilityStart, drivers.CapabilityStop, drivers.CapabilityKill}
 }
 
 func missingEnvOrOption(setting, envVar, opt string) error {
@@ -146,7 +146,6 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.FlavorId = flags.String("rackspace-flavor-id")
 	d.SSHUser = flags.String("rackspace-ssh-user")
 	d.SSHPort = flags.Int("rackspace-ssh-port")
-	d.EnableDockerInstall = flags.String("rackspace-docker-install") == "true"
 
 	if d.Region == "" {
 		return missingEnvOrOption("Region", "OS_REGION_NAME", "--rackspace-region")
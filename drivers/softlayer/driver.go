@@ -14,10 +14,11 @@ import (
 	"github.com/docker/machine/drivers"
 	"github.com/docker/machine/ssh"
 	"github.com/docker/machine/state"
+	"github.com/docker/machine/utils"
+	"golang.org/x/net/context"
 )
 
 const (
-	dockerConfigDir  = "/etc/docker"
 	ApiEndpoint      = "https://api.softlayer.com/rest/v3"
 	DockerInstallUrl = "https://get.docker.com"
 )
@@ -218,38 +219,6 @@ func (d *Driver) DriverName() string {
 	return "softlayer"
 }
 
-func (d *Driver) StartDocker() error {
-	log.Debug("Starting Docker...")
-
-	cmd, err := d.GetSSHCommand("sudo service docker start")
-	if err != nil {
-		return err
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (d *Driver) StopDocker() error {
-	log.Debug("Stopping Docker...")
-
-	cmd, err := d.GetSSHCommand("sudo service docker stop")
-	if err != nil {
-		return err
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (d *Driver) GetDockerConfigDir() string {
-	return dockerConfigDir
-}
-
 func (d *Driver) GetURL() (string, error) {
 	ip, err := d.GetIP()
 	if err != nil {
@@ -293,25 +262,24 @@ func (d *Driver) PreCreateCheck() error {
 	return nil
 }
 
-func (d *Driver) Create() error {
-	waitForStart := func() {
+func (d *Driver) Create(ctx context.Context) error {
+	waitForStart := func() error {
 		log.Infof("Waiting for host to become available")
-		for {
+		return utils.WaitFor(ctx, "host to become available", utils.DefaultBackoff, func() error {
 			s, err := d.GetState()
 			if err != nil {
-				continue
+				return err
 			}
-
-			if s == state.Running {
-				break
+			if s != state.Running {
+				return fmt.Errorf("host is %s", s)
 			}
-			time.Sleep(2 * time.Second)
-		}
+			return nil
+		})
 	}
 
-	getIp := func() {
+	getIp := func() error {
 		log.Infof("Getting Host IP")
-		for {
+		return utils.WaitFor(ctx, "the host IP", utils.DefaultBackoff, func() error {
 			var (
 				ip  string
 				err error
@@ -322,17 +290,16 @@ func (d *Driver) Create() error {
 				ip, err = d.getClient().VirtualGuest().GetPublicIp(d.Id)
 			}
 			if err != nil {
-				time.Sleep(2 * time.Second)
-				continue
+				return err
 			}
 			// not a perfect regex, but should be just fine for our needs
 			exp := regexp.MustCompile(`\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}`)
-			if exp.MatchString(ip) {
-				d.IPAddress = ip
-				break
+			if !exp.MatchString(ip) {
+				return fmt.Errorf("host has no IP yet: %q", ip)
 			}
-			time.Sleep(2 * time.Second)
-		}
+			d.IPAddress = ip
+			return nil
+		})
 	}
 
 	log.Infof("Creating SSH key...")
@@ -349,10 +316,16 @@ func (d *Driver) Create() error {
 		return fmt.Errorf("Error creating host: %q", err)
 	}
 	d.Id = id
-	getIp()
-	waitForStart()
-	ssh.WaitForTCP(d.IPAddress + ":22")
-	if err := d.setupHost(); err != nil {
+	if err := getIp(); err != nil {
+		return err
+	}
+	if err := waitForStart(); err != nil {
+		return err
+	}
+	if err := ssh.WaitForTCP(ctx, d.IPAddress+":22"); err != nil {
+		return err
+	}
+	if err := d.setupHost(ctx); err != nil {
 		fmt.Fprintf(os.Stderr, "Error setting up host config: %q", err)
 	}
 	return nil
@@ -405,57 +378,33 @@ func (d *Driver) sshKeyPath() string {
 func (d *Driver) Kill() error {
 	return d.getClient().VirtualGuest().PowerOff(d.Id)
 }
-func (d *Driver) Remove() error {
-	var err error
-	for i := 0; i < 5; i++ {
-		if err = d.getClient().VirtualGuest().Cancel(d.Id); err != nil {
-			time.Sleep(2 * time.Second)
-			continue
-		}
-		break
-	}
-	return err
+func (d *Driver) Remove(ctx context.Context) error {
+	backoff := utils.Backoff{Interval: 2 * time.Second, MaxAttempts: 5}
+	return utils.WaitFor(ctx, fmt.Sprintf("host %d to be cancelled", d.Id), backoff, func() error {
+		return d.getClient().VirtualGuest().Cancel(d.Id)
+	})
 }
 func (d *Driver) Restart() error {
 	return d.getClient().VirtualGuest().Reboot(d.Id)
 }
-func (d *Driver) Start() error {
+func (d *Driver) Start(ctx context.Context) error {
 	return d.getClient().VirtualGuest().PowerOn(d.Id)
 }
-func (d *Driver) Stop() error {
+func (d *Driver) Stop(ctx context.Context) error {
 	return d.getClient().VirtualGuest().PowerOff(d.Id)
 }
 
-func (d *Driver) Upgrade() error {
-	log.Debugf("Upgrading Docker")
-
-	cmd, err := d.GetSSHCommand("sudo apt-get update && sudo apt-get install --upgrade lxc-docker")
-	if err != nil {
-		return err
-
-	}
-	if err := cmd.Run(); err != nil {
+func (d *Driver) setupHost(ctx context.Context) error {
+	log.Infof("Configuring host OS")
+	if err := ssh.WaitForTCP(ctx, d.IPAddress+":22"); err != nil {
 		return err
-
 	}
-
-	return cmd.Run()
-}
-
-func (d *Driver) setupHost() error {
-	log.Infof("Configuring host OS")
-	ssh.WaitForTCP(d.IPAddress + ":22")
 	// Wait to make sure docker is installed
-	for {
+	return utils.WaitFor(ctx, "Docker to be installed", utils.DefaultBackoff, func() error {
 		cmd, err := d.GetSSHCommand(`[ -f "$(which docker)" ] && [ -f "/etc/default/docker" ] || exit 1`)
 		if err != nil {
-			return err
-		}
-		if err := cmd.Run(); err == nil {
-			break
+			return utils.Permanent(err)
 		}
-		time.Sleep(2 * time.Second)
-	}
-
-	return nil
+		return cmd.Run()
+	})
 }
@@ -1,11 +1,15 @@
 package drivers
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 
+	log "github.com/Sirupsen/logrus"
 	"github.com/docker/machine/utils"
 )
 
@@ -31,6 +35,54 @@ func AddPublicKeyToAuthorizedHosts(d Driver, authorizedKeysPath string) error {
 	return cmd.Run()
 }
 
+// RunSSHCommand runs command on the host of d over SSH and returns what it
+// wrote to standard output. Its output is logged at the debug level, so that
+// it ends up in the operation logs, and what it wrote to standard error is
+// added to its error.
+func RunSSHCommand(d Driver, command string) ([]byte, error) {
+	return runSSHCommand(d, command, nil)
+}
+
+// WriteSSHFile writes contents to the file at filePath on the host of d as
+// root, creating its directory. The contents are sent over standard input,
+// so the shell of the host does not see them.
+func WriteSSHFile(d Driver, filePath string, contents []byte) error {
+	// Use path.Dir here, want a unix path even when running on Windows.
+	command := fmt.Sprintf("sudo mkdir -p %q && sudo tee %q >/dev/null", path.Dir(filePath), filePath)
+	_, err := runSSHCommand(d, command, bytes.NewReader(contents))
+	return err
+}
+
+func runSSHCommand(d Driver, command string, stdin io.Reader) ([]byte, error) {
+	cmd, err := d.GetSSHCommand(command)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdin = stdin
+
+	Logger(d).Debugf("executing: %v", strings.Join(cmd.Args, " "))
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+
+	entry := Logger(d).WithFields(log.Fields{
+		"command": command,
+		"stdout":  stdout.String(),
+		"stderr":  stderr.String(),
+	})
+	if err != nil {
+		entry.Debugf("SSH command failed: %s", err)
+		if msg := bytes.TrimSpace(stderr.Bytes()); len(msg) > 0 {
+			return stdout.Bytes(), fmt.Errorf("%s: %s", err, msg)
+		}
+		return stdout.Bytes(), err
+	}
+	entry.Debug("SSH command succeeded")
+	return stdout.Bytes(), nil
+}
+
 func PublicKeyExists() (bool, error) {
 	_, err := os.Stat(PublicKeyPath())
 	if err == nil {
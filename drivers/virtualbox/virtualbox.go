@@ -14,7 +14,6 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
-	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/codegangsta/cli"
@@ -22,10 +21,7 @@ import (
 	"github.com/docker/machine/ssh"
 	"github.com/docker/machine/state"
 	"github.com/docker/machine/utils"
-)
-
-const (
-	dockerConfigDir = "/var/lib/boot2docker"
+	"golang.org/x/net/context"
 )
 
 type Driver struct {
@@ -105,7 +101,7 @@ func (d *Driver) PreCreateCheck() error {
 	return nil
 }
 
-func (d *Driver) Create() error {
+func (d *Driver) Create(ctx context.Context) error {
 	var (
 		err    error
 		isoURL string
@@ -314,7 +310,7 @@ func (d *Driver) Create() error {
 
 	log.Infof("Starting VirtualBox VM...")
 
-	if err := d.Start(); err != nil {
+	if err := d.Start(ctx); err != nil {
 		return err
 	}
 
@@ -335,33 +331,31 @@ func (d *Driver) Create() error {
 	return nil
 }
 
-func (d *Driver) Start() error {
+func (d *Driver) Start(ctx context.Context) error {
 	if err := vbm("startvm", d.MachineName, "--type", "headless"); err != nil {
 		return err
 	}
 	log.Infof("Waiting for VM to start...")
-	return ssh.WaitForTCP(fmt.Sprintf("localhost:%d", d.SSHPort))
+	return ssh.WaitForTCP(ctx, fmt.Sprintf("localhost:%d", d.SSHPort))
 }
 
-func (d *Driver) Stop() error {
+func (d *Driver) Stop(ctx context.Context) error {
 	if err := vbm("controlvm", d.MachineName, "acpipowerbutton"); err != nil {
 		return err
 	}
-	for {
+	return utils.WaitFor(ctx, "VM to stop", utils.DefaultBackoff, func() error {
 		s, err := d.GetState()
 		if err != nil {
-			return err
+			return utils.Permanent(err)
 		}
 		if s == state.Running {
-			time.Sleep(1 * time.Second)
-		} else {
-			break
+			return fmt.Errorf("VM is still running")
 		}
-	}
-	return nil
+		return nil
+	})
 }
 
-func (d *Driver) Remove() error {
+func (d *Driver) Remove(ctx context.Context) error {
 	s, err := d.GetState()
 	if err != nil {
 		if err == ErrMachineNotExist {
@@ -385,11 +379,11 @@ func (d *Driver) Restart() error {
 	}
 
 	if s == state.Running {
-		if err := d.Stop(); err != nil {
+		if err := d.Stop(context.Background()); err != nil {
 			return err
 		}
 	}
-	return d.Start()
+	return d.Start(context.Background())
 }
 
 func (d *Driver) Kill() error {
@@ -398,7 +392,7 @@ func (d *Driver) Kill() error {
 
 func (d *Driver) Upgrade() error {
 	log.Infof("Stopping machine...")
-	if err := d.Stop(); err != nil {
+	if err := d.Stop(context.Background()); err != nil {
 		return err
 	}
 
@@ -414,28 +408,86 @@ func (d *Driver) Upgrade() error {
 	}
 
 	log.Infof("Starting machine...")
-	if err := d.Start(); err != nil {
+	if err := d.Start(context.Background()); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (d *Driver) GetState() (state.State, error) {
+func (d *Driver) Pause() error {
+	return vbm("controlvm", d.MachineName, "pause")
+}
+
+func (d *Driver) Unpause() error {
+	return vbm("controlvm", d.MachineName, "resume")
+}
+
+// Resize changes the CPUs and memory of the VM, which VirtualBox only allows
+// while it is powered off.
+func (d *Driver) Resize(cpus int, memory int) error {
+	s, err := d.GetState()
+	if err != nil {
+		return err
+	}
+	if s != state.Stopped {
+		return fmt.Errorf("%s must be stopped to be resized", d.MachineName)
+	}
+
+	args := []string{"modifyvm", d.MachineName}
+	if cpus > 0 {
+		args = append(args, "--cpus", fmt.Sprintf("%d", cpus))
+	}
+	if memory > 0 {
+		args = append(args, "--memory", fmt.Sprintf("%d", memory))
+	}
+	if err := vbm(args...); err != nil {
+		return err
+	}
+
+	if memory > 0 {
+		d.Memory = memory
+	}
+	return nil
+}
+
+func (d *Driver) Snapshot(name string) error {
+	return vbm("snapshot", d.MachineName, "take", name)
+}
+
+// RestoreSnapshot rolls the VM back to the snapshot name, which VirtualBox
+// only allows while it is not running.
+func (d *Driver) RestoreSnapshot(name string) error {
+	return vbm("snapshot", d.MachineName, "restore", name)
+}
+
+// GetProviderState returns the VMState of the VM, or an empty string if
+// VirtualBox does not report one.
+func (d *Driver) GetProviderState() (string, error) {
 	stdout, stderr, err := vbmOutErr("showvminfo", d.MachineName,
 		"--machinereadable")
 	if err != nil {
 		if reMachineNotFound.FindString(stderr) != "" {
-			return state.Error, ErrMachineNotExist
+			return "", ErrMachineNotExist
 		}
-		return state.Error, err
+		return "", err
 	}
 	re := regexp.MustCompile(`(?m)^VMState="(\w+)"`)
 	groups := re.FindStringSubmatch(stdout)
 	if len(groups) < 1 {
-		return state.None, nil
+		return "", nil
 	}
-	switch groups[1] {
+	return groups[1], nil
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	vmState, err := d.GetProviderState()
+	if err != nil {
+		return state.Error, err
+	}
+	switch vmState {
+	case "":
+		return state.None, nil
 	case "running":
 		return state.Running, nil
 	case "paused":
@@ -444,8 +496,12 @@ func (d *Driver) GetState() (state.State, error) {
 		return state.Saved, nil
 	case "poweroff", "aborted":
 		return state.Stopped, nil
+	case "starting", "restoring":
+		return state.Starting, nil
+	case "stopping", "saving":
+		return state.Stopping, nil
 	}
-	return state.None, nil
+	return state.Unknown, nil
 }
 
 func (d *Driver) setMachineNameIfNotSet() {
@@ -495,38 +551,6 @@ func (d *Driver) GetSSHCommand(args ...string) (*exec.Cmd, error) {
 	return ssh.GetSSHCommand("localhost", d.SSHPort, "docker", d.sshKeyPath(), args...), nil
 }
 
-func (d *Driver) StartDocker() error {
-	log.Debug("Starting Docker...")
-
-	cmd, err := d.GetSSHCommand("sudo /etc/init.d/docker start")
-	if err != nil {
-		return err
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (d *Driver) StopDocker() error {
-	log.Debug("Stopping Docker...")
-
-	cmd, err := d.GetSSHCommand("if [ -e /var/run/docker.pid ]; then sudo /etc/init.d/docker stop ; fi")
-	if err != nil {
-		return err
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (d *Driver) GetDockerConfigDir() string {
-	return dockerConfigDir
-}
-
 func (d *Driver) sshKeyPath() string {
 	return filepath.Join(d.storePath, "id_rsa")
 }
@@ -25,13 +25,13 @@ import (
 	"github.com/docker/machine/state"
 	"github.com/docker/machine/utils"
 	cssh "golang.org/x/crypto/ssh"
+	"golang.org/x/net/context"
 )
 
 const (
-	B2D_USER        = "docker"
-	B2D_PASS        = "tcuser"
-	dockerConfigDir = "/var/lib/boot2docker"
-	isoFilename     = "boot2docker-vmw.iso"
+	B2D_USER    = "docker"
+	B2D_PASS    = "tcuser"
+	isoFilename = "boot2docker-vmw.iso"
 )
 
 // Driver for VMware Fusion
@@ -134,7 +134,7 @@ func (d *Driver) PreCreateCheck() error {
 	return nil
 }
 
-func (d *Driver) Create() error {
+func (d *Driver) Create(ctx context.Context) error {
 
 	var (
 		isoURL string
@@ -217,29 +217,27 @@ func (d *Driver) Create() error {
 		}
 	}
 
-	if err := d.Start(); err != nil {
+	if err := d.Start(ctx); err != nil {
 		return err
 	}
 
 	var ip string
 
 	log.Infof("Waiting for VM to come online...")
-	for i := 1; i <= 60; i++ {
+	backoff := utils.DefaultBackoff
+	backoff.Timeout = 2 * time.Minute
+	if err := utils.WaitFor(ctx, "the VM to come online", backoff, func() error {
 		ip, err = d.getIPfromDHCPLease()
 		if err != nil {
-			log.Debugf("Not there yet %d/%d, error: %s", i, 60, err)
-			time.Sleep(2 * time.Second)
-			continue
+			return err
 		}
-
-		if ip != "" {
-			log.Debugf("Got an ip: %s", ip)
-			break
+		if ip == "" {
+			return fmt.Errorf("VM has no IP yet")
 		}
-	}
-
-	if ip == "" {
-		return fmt.Errorf("Machine didn't return an IP after 120 seconds, aborting")
+		log.Debugf("Got an ip: %s", ip)
+		return nil
+	}); err != nil {
+		return err
 	}
 
 	d.IPAddress = ip
@@ -288,17 +286,17 @@ func (d *Driver) Create() error {
 	return nil
 }
 
-func (d *Driver) Start() error {
+func (d *Driver) Start(ctx context.Context) error {
 	vmrun("start", d.vmxPath(), "nogui")
 	return nil
 }
 
-func (d *Driver) Stop() error {
+func (d *Driver) Stop(ctx context.Context) error {
 	vmrun("stop", d.vmxPath(), "nogui")
 	return nil
 }
 
-func (d *Driver) Remove() error {
+func (d *Driver) Remove(ctx context.Context) error {
 
 	s, _ := d.GetState()
 	if s == state.Running {
@@ -321,42 +319,6 @@ func (d *Driver) Kill() error {
 	return nil
 }
 
-func (d *Driver) StartDocker() error {
-	log.Debug("Starting Docker...")
-
-	cmd, err := d.GetSSHCommand("sudo /etc/init.d/docker start")
-	if err != nil {
-		return err
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (d *Driver) StopDocker() error {
-	log.Debug("Stopping Docker...")
-
-	cmd, err := d.GetSSHCommand("if [ -e /var/run/docker.pid ]; then sudo /etc/init.d/docker stop ; fi")
-	if err != nil {
-		return err
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (d *Driver) GetDockerConfigDir() string {
-	return dockerConfigDir
-}
-
-func (d *Driver) Upgrade() error {
-	return nil
-}
-
 func (d *Driver) GetSSHCommand(args ...string) (*exec.Cmd, error) {
 	ip, err := d.GetIP()
 	if err != nil {
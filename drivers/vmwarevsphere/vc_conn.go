@@ -11,6 +11,7 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/machine/drivers/vmwarevsphere/errors"
+	"github.com/docker/machine/utils"
 )
 
 type VcConn struct {
@@ -73,11 +74,18 @@ func (conn VcConn) DatastoreUpload(localPath string) error {
 	args = append(args, fmt.Sprintf("--dc=%s", conn.driver.Datacenter))
 	args = append(args, localPath)
 	args = append(args, dsPath)
+
+	// govc does not tell how much it uploaded, only how long it has taken
+	progress := utils.NewProgress()
+	progress.Step(fmt.Sprintf("Uploading %s to datastore %s", B2D_ISO_NAME, conn.driver.Datastore), -1)
 	_, stderr, err := govcOutErr(args...)
 	if stderr == "" && err == nil {
+		progress.Done(nil)
 		return nil
 	} else {
-		return errors.NewDatastoreError(conn.driver.Datacenter, "upload", stderr)
+		err = errors.NewDatastoreError(conn.driver.Datacenter, "upload", stderr)
+		progress.Done(err)
+		return err
 	}
 }
 
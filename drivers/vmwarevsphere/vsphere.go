@@ -24,13 +24,13 @@ import (
 	"github.com/docker/machine/state"
 	"github.com/docker/machine/utils"
 	cssh "golang.org/x/crypto/ssh"
+	"golang.org/x/net/context"
 )
 
 const (
 	DATASTORE_DIR      = "boot2docker-iso"
 	B2D_ISO_NAME       = "boot2docker-vmw.iso"
 	DEFAULT_CPU_NUMBER = 2
-	dockerConfigDir    = "/var/lib/boot2docker"
 	B2D_USER           = "docker"
 	B2D_PASS           = "tcuser"
 )
@@ -224,7 +224,7 @@ func (d *Driver) PreCreateCheck() error {
 // 2. generate an SSH keypair
 // 3. create a virtual machine with the boot2docker ISO mounted;
 // 4. reconfigure the virtual machine network and disk size;
-func (d *Driver) Create() error {
+func (d *Driver) Create(ctx context.Context) error {
 	if err := d.checkVsphereConfig(); err != nil {
 		return err
 	}
@@ -318,7 +318,7 @@ func (d *Driver) Create() error {
 		return err
 	}
 
-	if err := d.Start(); err != nil {
+	if err := d.Start(ctx); err != nil {
 		return err
 	}
 
@@ -339,7 +339,7 @@ func (d *Driver) Create() error {
 	return nil
 }
 
-func (d *Driver) Start() error {
+func (d *Driver) Start(ctx context.Context) error {
 	machineState, err := d.GetState()
 	if err != nil {
 		return err
@@ -403,7 +403,7 @@ func (d *Driver) Start() error {
 	return errors.NewInvalidStateError(d.MachineName)
 }
 
-func (d *Driver) Stop() error {
+func (d *Driver) Stop(ctx context.Context) error {
 	vcConn := NewVcConn(d)
 	err := vcConn.VmPowerOff()
 	if err != nil {
@@ -412,13 +412,13 @@ func (d *Driver) Stop() error {
 	return err
 }
 
-func (d *Driver) Remove() error {
+func (d *Driver) Remove(ctx context.Context) error {
 	machineState, err := d.GetState()
 	if err != nil {
 		return err
 	}
 	if machineState == state.Running {
-		if err = d.Stop(); err != nil {
+		if err = d.Stop(ctx); err != nil {
 			return fmt.Errorf("can't stop VM: %s", err)
 		}
 	}
@@ -431,50 +431,14 @@ func (d *Driver) Remove() error {
 }
 
 func (d *Driver) Restart() error {
-	if err := d.Stop(); err != nil {
+	if err := d.Stop(context.Background()); err != nil {
 		return err
 	}
-	return d.Start()
+	return d.Start(context.Background())
 }
 
 func (d *Driver) Kill() error {
-	return d.Stop()
-}
-
-func (d *Driver) StartDocker() error {
-	log.Debug("Starting Docker...")
-
-	cmd, err := d.GetSSHCommand("sudo /etc/init.d/docker start")
-	if err != nil {
-		return err
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (d *Driver) StopDocker() error {
-	log.Debug("Stopping Docker...")
-
-	cmd, err := d.GetSSHCommand("sudo /etc/init.d/docker stop")
-	if err != nil {
-		return err
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (d *Driver) GetDockerConfigDir() string {
-	return dockerConfigDir
-}
-
-func (d *Driver) Upgrade() error {
-	return fmt.Errorf("upgrade is not supported for vsphere driver at this moment")
+	return d.Stop(context.Background())
 }
 
 func (d *Driver) GetSSHCommand(args ...string) (*exec.Cmd, error) {
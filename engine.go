@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/drivers"
+)
+
+// EngineOptions are the Docker daemon settings given with the --engine-*
+// flags of "machine create". They are stored with the host so that
+// "machine provision" can apply them again.
+type EngineOptions struct {
+	ArbitraryFlags   []string
+	Env              []string
+	InsecureRegistry []string
+	Labels           []string
+	RegistryMirror   []string
+	StorageDriver    string
+}
+
+// NewEngineOptions reads and validates the --engine-* flags.
+func NewEngineOptions(flags drivers.DriverOptions) (EngineOptions, error) {
+	opts := EngineOptions{
+		ArbitraryFlags:   flags.StringSlice("engine-opt"),
+		Env:              flags.StringSlice("engine-env"),
+		InsecureRegistry: flags.StringSlice("engine-insecure-registry"),
+		Labels:           flags.StringSlice("engine-label"),
+		RegistryMirror:   flags.StringSlice("engine-registry-mirror"),
+		StorageDriver:    flags.String("engine-storage-driver"),
+	}
+
+	for _, env := range opts.Env {
+		if kv := strings.SplitN(env, "=", 2); len(kv) != 2 || kv[0] == "" {
+			return opts, fmt.Errorf("Invalid engine environment variable %q, must be of the form KEY=value", env)
+		}
+	}
+
+	for _, arg := range opts.daemonArgs() {
+		if err := validateDaemonArg(arg); err != nil {
+			return opts, err
+		}
+	}
+	for _, env := range opts.Env {
+		if err := validateDaemonArg(env); err != nil {
+			return opts, err
+		}
+	}
+
+	return opts, nil
+}
+
+// daemonArgs returns the extra arguments to start the Docker daemon with.
+// Arbitrary flags are given without their leading dashes, e.g. "dns=8.8.8.8"
+// or "debug".
+func (o EngineOptions) daemonArgs() []string {
+	args := []string{}
+
+	for _, f := range o.ArbitraryFlags {
+		args = append(args, "--"+strings.TrimLeft(f, "-"))
+	}
+	for _, r := range o.InsecureRegistry {
+		args = append(args, "--insecure-registry="+r)
+	}
+	for _, l := range o.Labels {
+		args = append(args, "--label="+l)
+	}
+	for _, m := range o.RegistryMirror {
+		args = append(args, "--registry-mirror="+m)
+	}
+	if o.StorageDriver != "" {
+		args = append(args, "--storage-driver="+o.StorageDriver)
+	}
+
+	return args
+}
+
+// validateDaemonArg makes sure arg can be written into the daemon options
+// on the host, which are read by a shell or systemd.
+func validateDaemonArg(arg string) error {
+	if strings.ContainsAny(arg, " \t\n'\"\\$`") {
+		return fmt.Errorf("Invalid engine option %q, options cannot contain whitespace, quotes, backslashes, $ or `", arg)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/provision"
+)
+
+func TestNewEngineOptions(t *testing.T) {
+	flags := &DriverOptionsMock{
+		Data: map[string]interface{}{
+			"engine-opt":               []string{"dns=8.8.8.8", "--debug"},
+			"engine-insecure-registry": []string{"registry.local:5000"},
+			"engine-registry-mirror":   []string{"http://mirror.local"},
+			"engine-storage-driver":    "overlay",
+			"engine-label":             []string{"storage=ssd"},
+			"engine-env":               []string{"HTTP_PROXY=http://proxy:3128"},
+		},
+	}
+
+	opts, err := NewEngineOptions(flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"--dns=8.8.8.8",
+		"--debug",
+		"--insecure-registry=registry.local:5000",
+		"--label=storage=ssd",
+		"--registry-mirror=http://mirror.local",
+		"--storage-driver=overlay",
+	}
+	args := opts.daemonArgs()
+	if strings.Join(args, " ") != strings.Join(expected, " ") {
+		t.Fatalf("expected daemon args %v; received %v", expected, args)
+	}
+}
+
+func TestNewEngineOptionsInvalid(t *testing.T) {
+	for _, data := range []map[string]interface{}{
+		{"engine-env": []string{"HTTP_PROXY"}},
+		{"engine-opt": []string{"dns=8.8.8.8 --debug"}},
+		{"engine-label": []string{"name='foo'"}},
+		{"engine-env": []string{"HTTP_PROXY=$(reboot)"}},
+		{"engine-opt": []string{"dns=`reboot`"}},
+	} {
+		if _, err := NewEngineOptions(&DriverOptionsMock{Data: data}); err == nil {
+			t.Fatalf("expected error for %v", data)
+		}
+	}
+}
+
+func TestGenerateDockerConfigEngineOptions(t *testing.T) {
+	host, err := NewHost(hostTestName, hostTestDriverName, hostTestStorePath, hostTestCaCert, hostTestPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host.EngineOptions = EngineOptions{
+		StorageDriver: "overlay",
+		Env:           []string{"HTTP_PROXY=http://proxy:3128"},
+	}
+
+	dockerCfg := host.generateDockerConfig(provision.NewDebianProvisioner(host.Driver), 2376, "", "", "")
+
+	if strings.Index(dockerCfg.EngineConfig, "--storage-driver=overlay") == -1 {
+		t.Fatalf("expected storage driver in engine config; received %s", dockerCfg.EngineConfig)
+	}
+	if strings.Index(dockerCfg.EngineConfig, "export HTTP_PROXY=http://proxy:3128") == -1 {
+		t.Fatalf("expected environment in engine config; received %s", dockerCfg.EngineConfig)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/machine/utils"
+)
+
+// The actions recorded in the event log.
+const (
+	eventCreate          = "create"
+	eventAdopt           = "adopt"
+	eventProvision       = "provision"
+	eventStart           = "start"
+	eventStop            = "stop"
+	eventKill            = "kill"
+	eventRestart         = "restart"
+	eventRemove          = "rm"
+	eventUpgrade         = "upgrade"
+	eventPause           = "pause"
+	eventUnpause         = "unpause"
+	eventResize          = "resize"
+	eventSnapshot        = "snapshot"
+	eventRestoreSnapshot = "restore-snapshot"
+)
+
+// The results of the events.
+const (
+	eventSuccess = "success"
+	eventError   = "error"
+)
+
+// eventLogName is the file of the store which holds the event log, one
+// JSON event per line, oldest first.
+const eventLogName = ".events"
+
+// eventPollInterval is how often "machine events --follow" checks the event
+// log for new events.
+const eventPollInterval = time.Second
+
+// Event is an operation done on a machine, as recorded in the event log.
+type Event struct {
+	Time    time.Time
+	User    string
+	Machine string
+	Action  string
+	Result  string
+	Error   string `json:",omitempty"`
+
+	// Duration is how long the operation took, in nanoseconds in JSON.
+	Duration time.Duration
+}
+
+// newEvent returns the event for action, which was started on machine at
+// start and failed with err if it is not nil.
+func newEvent(machine string, action string, start time.Time, err error) *Event {
+	event := &Event{
+		Time:     start,
+		User:     utils.GetUsername(),
+		Machine:  machine,
+		Action:   action,
+		Result:   eventSuccess,
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		event.Result = eventError
+		event.Error = err.Error()
+	}
+	return event
+}
+
+// appendEvent appends event to the event log at path. Each event is written
+// at once, so machines running at the same time do not mix their events up.
+func appendEvent(path string, event *Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// recordEvent appends the event for action on the host to the event log of
+// its store. err is where the operation left its error, so it can be
+// deferred. Failing to record the event is not an error of the operation.
+func (h *Host) recordEvent(action string, start time.Time, err *error) {
+	// The host is stored in a directory of the store
+	path := filepath.Join(filepath.Dir(h.storePath), eventLogName)
+	if appendErr := appendEvent(path, newEvent(h.Name, action, start, *err)); appendErr != nil {
+		log.Warnf("Error recording %s of %s: %s", action, h.Name, appendErr)
+	}
+}
+
+// readEvents reads the events in the event log at path from offset on,
+// skipping those before since. It returns the offset after the last event
+// read, from which the events added later can be read; a last line which
+// is still being written is left for then.
+func readEvents(path string, offset int64, since time.Time) ([]Event, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, offset, nil
+		}
+		return nil, offset, err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, 0); err != nil {
+		return nil, offset, err
+	}
+
+	events := []Event{}
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return events, offset, nil
+		}
+		if err != nil {
+			return events, offset, err
+		}
+		offset += int64(len(line))
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			log.Warnf("Skipping invalid event in %s: %s", path, err)
+			continue
+		}
+		if event.Time.Before(since) {
+			continue
+		}
+		events = append(events, event)
+	}
+}
+
+// parseSince parses the --since option of "machine events", either a time
+// such as 2015-03-01T10:00:00Z or a duration before now such as 1h.
+func parseSince(since string, now time.Time) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(since); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid --since %q: expected a time such as 2015-03-01T10:00:00Z or a duration such as 1h", since)
+	}
+	return t, nil
+}
+
+// filterEvents returns the events of the machines names, or all of them if
+// there are no names.
+func filterEvents(events []Event, names []string) []Event {
+	if len(names) == 0 {
+		return events
+	}
+	filtered := []Event{}
+	for _, event := range events {
+		for _, name := range names {
+			if event.Machine == name {
+				filtered = append(filtered, event)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// eventPrinter writes events as a table, as JSON, one event per line, when
+// format is "json", or by executing format as a Go template for each event.
+type eventPrinter struct {
+	w      io.Writer
+	format string
+	tmpl   *template.Template
+}
+
+func newEventPrinter(w io.Writer, format string) (*eventPrinter, error) {
+	p := &eventPrinter{w: w, format: format}
+	if format == "" || format == "json" {
+		return p, nil
+	}
+
+	format = strings.NewReplacer(`\t`, "\t", `\n`, "\n").Replace(format)
+	tmpl, err := template.New("events").Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid format template: %s", err)
+	}
+	p.tmpl = tmpl
+	return p, nil
+}
+
+// header writes the header of the table, if events are printed as one.
+func (p *eventPrinter) header() {
+	if p.format == "" {
+		fmt.Fprintln(p.w, "TIME\tUSER\tMACHINE\tACTION\tRESULT\tDURATION\tERROR")
+	}
+}
+
+func (p *eventPrinter) print(event Event) error {
+	switch {
+	case p.tmpl != nil:
+		if err := p.tmpl.Execute(p.w, event); err != nil {
+			return err
+		}
+		fmt.Fprintln(p.w)
+	case p.format == "json":
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(p.w, string(data))
+	default:
+		duration := event.Duration - event.Duration%time.Millisecond
+		fmt.Fprintf(p.w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			event.Time.Local().Format(time.RFC3339), event.User, event.Machine,
+			event.Action, event.Result, duration, event.Error)
+	}
+	return nil
+}
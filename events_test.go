@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, eventLogName)
+
+	start := time.Now().Add(-time.Hour)
+	if err := appendEvent(path, newEvent("foo", eventCreate, start, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := appendEvent(path, newEvent("bar", eventStop, time.Now(), errors.New("host is not running"))); err != nil {
+		t.Fatal(err)
+	}
+
+	events, offset, err := readEvents(path, 0, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events; received %d", len(events))
+	}
+	if events[0].Machine != "foo" || events[0].Action != eventCreate || events[0].Result != eventSuccess {
+		t.Fatalf("unexpected event %+v", events[0])
+	}
+	if events[0].Duration < time.Hour {
+		t.Fatalf("expected the duration of the operation; received %s", events[0].Duration)
+	}
+	if events[1].Result != eventError || events[1].Error != "host is not running" {
+		t.Fatalf("expected the error of the operation; received %+v", events[1])
+	}
+
+	events, _, err = readEvents(path, 0, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Machine != "bar" {
+		t.Fatalf("expected only the events since a minute ago; received %+v", events)
+	}
+
+	// A line which is still being written is left for the next read
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"Machine":"baz"`)
+	events, next, err := readEvents(path, offset, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 || next != offset {
+		t.Fatalf("expected no complete event; received %+v", events)
+	}
+	f.WriteString(`,"Action":"rm"}` + "\n")
+	f.Close()
+	events, _, err = readEvents(path, next, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Machine != "baz" || events[0].Action != eventRemove {
+		t.Fatalf("expected the event once it is complete; received %+v", events)
+	}
+}
+
+func TestReadEventsNoLog(t *testing.T) {
+	events, offset, err := readEvents(filepath.Join(os.TempDir(), "machine-test-no-events"), 0, time.Time{})
+	if err != nil || len(events) != 0 || offset != 0 {
+		t.Fatalf("expected no events without an event log; received %v, %v", events, err)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2015, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	since, err := parseSince("1h", now)
+	if err != nil || !since.Equal(now.Add(-time.Hour)) {
+		t.Fatalf("expected an hour ago; received %s, %v", since, err)
+	}
+	since, err = parseSince("2015-03-01T10:00:00Z", now)
+	if err != nil || !since.Equal(time.Date(2015, 3, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected 10:00; received %s, %v", since, err)
+	}
+	if _, err := parseSince("yesterday", now); err == nil {
+		t.Fatal("expected an error for an invalid --since")
+	}
+}
+
+func TestPrintEvents(t *testing.T) {
+	events := []Event{
+		{Machine: "foo", Action: eventStart, Result: eventSuccess},
+		{Machine: "bar", Action: eventStop, Result: eventSuccess},
+	}
+	events = filterEvents(events, []string{"bar"})
+	if len(events) != 1 {
+		t.Fatalf("expected the events of bar; received %+v", events)
+	}
+
+	var buf bytes.Buffer
+	printer, err := newEventPrinter(&buf, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	printer.print(events[0])
+	if !strings.HasPrefix(buf.String(), "{") || !strings.Contains(buf.String(), `"Action":"stop"`) {
+		t.Fatalf("expected the event as JSON; received %s", buf.String())
+	}
+
+	buf.Reset()
+	printer, err = newEventPrinter(&buf, "{{.Machine}} {{.Action}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	printer.print(events[0])
+	if buf.String() != "bar stop\n" {
+		t.Fatalf("expected the template to be executed; received %q", buf.String())
+	}
+}
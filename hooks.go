@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/machine/drivers"
+	"github.com/docker/machine/state"
+)
+
+// HookOptions are the scripts given with the --*-script flags of "machine
+// create". The scripts are kept on the local machine, uploaded to the host
+// over SSH and run there whenever the hook fires.
+type HookOptions struct {
+	Provision []string `json:",omitempty"`
+	PostStart []string `json:",omitempty"`
+	PreRemove []string `json:",omitempty"`
+}
+
+// HookError is returned when a hook script exits with a non-zero status.
+type HookError struct {
+	Hook     string
+	Script   string
+	ExitCode int
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("%s script %s exited with status %d", e.Hook, e.Script, e.ExitCode)
+}
+
+// sshExitStatus is what ssh exits with when it cannot connect to the host
+// or loses the connection, rather than with the status of the command.
+const sshExitStatus = 255
+
+// exitCode returns the status a command failing with err exits with, which
+// is the one of the script for a failed hook.
+func exitCode(err error) int {
+	if hookErr, ok := err.(*HookError); ok {
+		return hookErr.ExitCode
+	}
+	return 1
+}
+
+// NewHookOptions reads the --*-script flags and makes sure the scripts
+// exist. The paths are stored absolute, so hooks run from any directory.
+func NewHookOptions(flags drivers.DriverOptions) (HookOptions, error) {
+	var err error
+	opts := HookOptions{}
+
+	if opts.Provision, err = hookScripts(flags.StringSlice("provision-script")); err != nil {
+		return opts, err
+	}
+	if opts.PostStart, err = hookScripts(flags.StringSlice("post-start-script")); err != nil {
+		return opts, err
+	}
+	if opts.PreRemove, err = hookScripts(flags.StringSlice("pre-remove-script")); err != nil {
+		return opts, err
+	}
+
+	return opts, nil
+}
+
+func hookScripts(paths []string) ([]string, error) {
+	scripts := []string{}
+	for _, p := range paths {
+		script, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+		fi, err := os.Stat(script)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading script: %s", err)
+		}
+		if fi.IsDir() {
+			return nil, fmt.Errorf("Script %s is a directory", script)
+		}
+		scripts = append(scripts, script)
+	}
+	return scripts, nil
+}
+
+// runHook runs the scripts of a hook on the host one after the other and
+// stops at the first one that fails.
+func (h *Host) runHook(hook string, scripts []string) error {
+	for _, script := range scripts {
+		h.logger().Infof("Running %s script %s on %s...", hook, script, h.Name)
+		if err := h.runScript(hook, script); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runPreRemoveHook runs the pre-remove hook if the host is running. The
+// scripts cannot run on a host which is not, which must not stop it from
+// being removed.
+func (h *Host) runPreRemoveHook() error {
+	if len(h.Hooks.PreRemove) == 0 {
+		return nil
+	}
+
+	st, err := h.Driver.GetState()
+	if err != nil {
+		log.Warnf("Not running the pre-remove scripts of %s, whose state is unknown: %s", h.Name, err)
+		return nil
+	}
+	if st != state.Running {
+		log.Warnf("Not running the pre-remove scripts of %s, which is %s", h.Name, st)
+		return nil
+	}
+	return h.runHook("pre-remove", h.Hooks.PreRemove)
+}
+
+// runScript uploads the script to a temporary file on the host and runs it,
+// so scripts can be written in any language with a #! line. Its output is
+// streamed with the name of the host as a prefix.
+func (h *Host) runScript(hook string, script string) error {
+	f, err := os.Open(script)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cmd, err := h.Driver.GetSSHCommand("f=$(mktemp) && cat > $f && chmod +x $f && $f; s=$?; rm -f $f; exit $s")
+	if err != nil {
+		return err
+	}
+	h.logger().Debugf("executing: %v", strings.Join(cmd.Args, " "))
+
+	var mu sync.Mutex
+	stdout := newPrefixWriter(os.Stdout, h.Name+": ", &mu)
+	stderr := newPrefixWriter(os.Stderr, h.Name+": ", &mu)
+	cmd.Stdin = f
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err = cmd.Run()
+	stdout.Flush()
+	stderr.Flush()
+
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			if status.ExitStatus() == sshExitStatus {
+				return fmt.Errorf("Error running %s script %s: the SSH connection to %s failed", hook, script, h.Name)
+			}
+			return &HookError{Hook: hook, Script: script, ExitCode: status.ExitStatus()}
+		}
+	}
+	return err
+}
+
+// prefixWriter writes every line written to it to w with a prefix. Writers
+// sharing a mutex never interleave their lines.
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	mu     *sync.Mutex
+	buf    bytes.Buffer
+}
+
+func newPrefixWriter(w io.Writer, prefix string, mu *sync.Mutex) *prefixWriter {
+	return &prefixWriter{w: w, prefix: prefix, mu: mu}
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf.Write(b)
+	for {
+		i := bytes.IndexByte(p.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		if err := p.writeLine(p.buf.Next(i + 1)); err != nil {
+			return len(b), err
+		}
+	}
+}
+
+// Flush writes out a last line which was not terminated by a newline.
+func (p *prefixWriter) Flush() error {
+	if p.buf.Len() == 0 {
+		return nil
+	}
+	return p.writeLine(append(p.buf.Next(p.buf.Len()), '\n'))
+}
+
+func (p *prefixWriter) writeLine(line []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err := fmt.Fprintf(p.w, "%s%s", p.prefix, line)
+	return err
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/docker/machine/state"
+)
+
+// localDriver runs SSH commands in a local shell.
+type localDriver struct {
+	FakeDriver
+}
+
+func (d *localDriver) GetSSHCommand(args ...string) (*exec.Cmd, error) {
+	return exec.Command("sh", "-c", args[0]), nil
+}
+
+func writeScript(t *testing.T, dir string, name string, contents string) string {
+	script := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(script, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return script
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := newPrefixWriter(&out, "dev: ", &sync.Mutex{})
+
+	w.Write([]byte("first\nsec"))
+	w.Write([]byte("ond\nlast"))
+	w.Flush()
+
+	expected := "dev: first\ndev: second\ndev: last\n"
+	if out.String() != expected {
+		t.Fatalf("expected %q; received %q", expected, out.String())
+	}
+}
+
+func TestNewHookOptions(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	script := writeScript(t, tmpDir, "setup.sh", "#!/bin/sh\n")
+
+	opts, err := NewHookOptions(&DriverOptionsMock{
+		Data: map[string]interface{}{
+			"provision-script": []string{script},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opts.Provision) != 1 || opts.Provision[0] != script {
+		t.Fatalf("expected provision script %s; received %v", script, opts.Provision)
+	}
+
+	if _, err := NewHookOptions(&DriverOptionsMock{
+		Data: map[string]interface{}{
+			"pre-remove-script": []string{filepath.Join(tmpDir, "missing.sh")},
+		},
+	}); err == nil {
+		t.Fatal("expected error for a missing script")
+	}
+}
+
+func TestRunHook(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	marker := filepath.Join(tmpDir, "marker")
+	ok := writeScript(t, tmpDir, "ok.sh", "#!/bin/sh\ntouch "+marker+"\n")
+	failing := writeScript(t, tmpDir, "failing.sh", "#!/bin/sh\nexit 3\n")
+
+	host := &Host{Name: "test", Driver: &localDriver{}}
+
+	if err := host.runHook("provision", []string{ok}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatalf("expected script to run: %s", err)
+	}
+
+	err = host.runHook("provision", []string{failing, ok})
+	hookErr, isHookErr := err.(*HookError)
+	if !isHookErr {
+		t.Fatalf("expected a hook error; received %v", err)
+	}
+	if hookErr.ExitCode != 3 || hookErr.Script != failing {
+		t.Fatalf("expected %s to exit with status 3; received %s", failing, hookErr)
+	}
+	if exitCode(err) != 3 {
+		t.Fatalf("expected exit code 3; received %d", exitCode(err))
+	}
+}
+
+func TestRunHookSSHFailure(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// ssh exits with 255 when it cannot connect
+	script := writeScript(t, tmpDir, "unreachable.sh", "#!/bin/sh\nexit 255\n")
+	host := &Host{Name: "test", Driver: &localDriver{}}
+
+	err = host.runHook("provision", []string{script})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, isHookErr := err.(*HookError); isHookErr || exitCode(err) != 1 {
+		t.Fatalf("expected a failed SSH connection to be told apart from the script; received %s", err)
+	}
+}
+
+func TestRunPreRemoveHook(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	marker := filepath.Join(tmpDir, "marker")
+	script := writeScript(t, tmpDir, "pre-remove.sh", "#!/bin/sh\ntouch "+marker+"\n")
+
+	driver := &localDriver{FakeDriver{MockState: state.Stopped}}
+	host := &Host{Name: "test", Driver: driver, Hooks: HookOptions{PreRemove: []string{script}}}
+
+	if err := host.runPreRemoveHook(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Fatal("expected the pre-remove script not to run on a stopped host")
+	}
+
+	driver.MockState = state.Running
+	if err := host.runPreRemoveHook(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatalf("expected the pre-remove script to run on a running host: %s", err)
+	}
+}
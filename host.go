@@ -10,14 +10,27 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/machine/drivers"
+	"github.com/docker/machine/provision"
+	"github.com/docker/machine/state"
 	"github.com/docker/machine/utils"
+	"golang.org/x/net/context"
 )
 
+// dockerBackoff is how often the daemon is checked after it has been
+// started or reconfigured, and how long it may take to come back up.
+var dockerBackoff = utils.Backoff{
+	Interval: 2 * time.Second,
+	Jitter:   0.2,
+	Timeout:  60 * time.Second,
+}
+
 var (
 	validHostNameChars   = `[a-zA-Z0-9\-\.]`
 	validHostNamePattern = regexp.MustCompile(`^` + validHostNameChars + `+$`)
@@ -33,12 +46,22 @@ type Host struct {
 	ServerKeyPath  string
 	PrivateKeyPath string
 	ClientCertPath string
-	storePath      string
-}
-
-type DockerConfig struct {
-	EngineConfig     string
-	EngineConfigPath string
+	ClientKeyPath  string
+	CreatedAt      time.Time
+	Labels         map[string]string `json:",omitempty"`
+	UserData       string            `json:",omitempty"`
+	EngineOptions  EngineOptions
+	Hooks          HookOptions
+	LastKnownState state.State
+	LastKnownURL   string
+	StateUpdatedAt time.Time
+
+	// CreateIncomplete is set until all the steps of "machine create" have
+	// completed, CreateStep is the last one which did.
+	CreateIncomplete bool   `json:",omitempty"`
+	CreateStep       string `json:",omitempty"`
+
+	storePath string
 }
 
 type hostConfig struct {
@@ -72,6 +95,31 @@ func LoadHost(name string, storePath string) (*Host, error) {
 	return host, nil
 }
 
+// ParseLabels parses labels of the form "key=value". A label without a
+// value is stored with an empty one.
+func ParseLabels(labels []string) (map[string]string, error) {
+	parsed := map[string]string{}
+
+	for _, label := range labels {
+		kv := strings.SplitN(label, "=", 2)
+		if kv[0] == "" {
+			return nil, fmt.Errorf("Invalid label %q, labels must be of the form key=value", label)
+		}
+		// labels end up in the daemon options on the host, which are
+		// read by a shell or systemd
+		if strings.ContainsAny(label, " \t\n'\"\\$`") {
+			return nil, fmt.Errorf("Invalid label %q, labels cannot contain whitespace, quotes, backslashes, $ or `", label)
+		}
+		if len(kv) == 1 {
+			parsed[kv[0]] = ""
+		} else {
+			parsed[kv[0]] = kv[1]
+		}
+	}
+
+	return parsed, nil
+}
+
 func ValidateHostName(name string) (string, error) {
 	if !validHostNamePattern.MatchString(name) {
 		return name, ErrInvalidHostname
@@ -100,7 +148,62 @@ func GenerateClientCertificate(caCertPath, privateKeyPath string) error {
 	return nil
 }
 
-func (h *Host) ConfigureAuth() error {
+// adopt imports the host, which was created outside of machine, with
+// adopter and configures it.
+func (h *Host) adopt(ctx context.Context, adopter drivers.Adopter, flags drivers.DriverOptions) (err error) {
+	defer h.recordEvent(eventAdopt, time.Now(), &err)
+
+	if err := adopter.Adopt(ctx, flags); err != nil {
+		return err
+	}
+	utils.RegisterSecrets(h.Driver)
+
+	if err := os.MkdirAll(h.storePath, 0700); err != nil {
+		return err
+	}
+	h.CreatedAt = time.Now()
+
+	if err := h.SaveConfig(); err != nil {
+		return err
+	}
+
+	return h.ConfigureAuth(ctx)
+}
+
+// Provision installs Docker on the host if it is missing and applies the
+// configuration of the daemon again, with new certificates.
+func (h *Host) Provision(ctx context.Context) (err error) {
+	defer h.recordEvent(eventProvision, time.Now(), &err)
+	defer h.openOperationLog(eventProvision).close()
+
+	return h.ConfigureAuth(ctx)
+}
+
+func (h *Host) ConfigureAuth(ctx context.Context) error {
+	if err := h.installDocker(); err != nil {
+		return err
+	}
+	return h.configureTLS(ctx)
+}
+
+// installDocker installs Docker on the host with the provisioner for its
+// operating system.
+func (h *Host) installDocker() error {
+	if h.Driver.DriverName() == "none" {
+		return nil
+	}
+
+	provisioner, err := provision.DetectProvisioner(h.Driver)
+	if err != nil {
+		return err
+	}
+
+	return provisioner.InstallDocker()
+}
+
+// configureTLS generates a server certificate for the host, uploads it
+// with the CA and restarts Docker with TLS verification.
+func (h *Host) configureTLS(ctx context.Context) error {
 	d := h.Driver
 
 	if d.DriverName() == "none" {
@@ -118,21 +221,24 @@ func (h *Host) ConfigureAuth() error {
 	org := h.Name
 	bits := 2048
 
-	log.Debugf("generating server cert: %s", serverCertPath)
+	h.logger().Debugf("generating server cert: %s", serverCertPath)
 
 	if err := utils.GenerateCert([]string{ip}, serverCertPath, serverKeyPath, h.CaCertPath, h.PrivateKeyPath, org, bits); err != nil {
 		return fmt.Errorf("error generating server cert: %s", err)
 	}
 
-	if err := d.StopDocker(); err != nil {
+	provisioner, err := provision.DetectProvisioner(d)
+	if err != nil {
 		return err
 	}
 
-	cmd, err := d.GetSSHCommand(fmt.Sprintf("sudo mkdir -p %s", d.GetDockerConfigDir()))
-	if err != nil {
+	if err := provisioner.StopDocker(); err != nil {
 		return err
 	}
-	if err := cmd.Run(); err != nil {
+
+	dockerConfigDir := provisioner.GetDockerConfigDir()
+
+	if _, err := drivers.RunSSHCommand(d, fmt.Sprintf("sudo mkdir -p %s", dockerConfigDir)); err != nil {
 		return err
 	}
 
@@ -144,41 +250,32 @@ func (h *Host) ConfigureAuth() error {
 
 	// due to windows clients, we cannot use filepath.Join as the paths
 	// will be mucked on the linux hosts
-	machineCaCertPath := path.Join(d.GetDockerConfigDir(), "ca.pem")
+	machineCaCertPath := path.Join(dockerConfigDir, "ca.pem")
 
 	serverCert, err := ioutil.ReadFile(serverCertPath)
 	if err != nil {
 		return err
 	}
-	machineServerCertPath := path.Join(d.GetDockerConfigDir(), "server.pem")
+	machineServerCertPath := path.Join(dockerConfigDir, "server.pem")
 
 	serverKey, err := ioutil.ReadFile(serverKeyPath)
 	if err != nil {
 		return err
 	}
-	machineServerKeyPath := path.Join(d.GetDockerConfigDir(), "server-key.pem")
+	machineServerKeyPath := path.Join(dockerConfigDir, "server-key.pem")
 
-	cmd, err = d.GetSSHCommand(fmt.Sprintf("echo \"%s\" | sudo tee -a %s", string(caCert), machineCaCertPath))
-	if err != nil {
-		return err
-	}
-	if err := cmd.Run(); err != nil {
-		return err
-	}
+	// The key is sent over SSH, keep it out of the logs
+	utils.RegisterSecret(string(serverKey))
 
-	cmd, err = d.GetSSHCommand(fmt.Sprintf("echo \"%s\" | sudo tee -a %s", string(serverKey), machineServerKeyPath))
-	if err != nil {
-		return err
-	}
-	if err := cmd.Run(); err != nil {
+	if err := drivers.WriteSSHFile(d, machineCaCertPath, caCert); err != nil {
 		return err
 	}
 
-	cmd, err = d.GetSSHCommand(fmt.Sprintf("echo \"%s\" | sudo tee -a %s", string(serverCert), machineServerCertPath))
-	if err != nil {
+	if err := drivers.WriteSSHFile(d, machineServerKeyPath, serverKey); err != nil {
 		return err
 	}
-	if err := cmd.Run(); err != nil {
+
+	if err := drivers.WriteSSHFile(d, machineServerCertPath, serverCert); err != nil {
 		return err
 	}
 
@@ -200,92 +297,188 @@ func (h *Host) ConfigureAuth() error {
 		dockerPort = dPort
 	}
 
-	cfg := h.generateDockerConfig(dockerPort, machineCaCertPath, machineServerKeyPath, machineServerCertPath)
+	cfg := h.generateDockerConfig(provisioner, dockerPort, machineCaCertPath, machineServerKeyPath, machineServerCertPath)
 
-	cmd, err = d.GetSSHCommand(fmt.Sprintf("echo \"%s\" | sudo tee -a %s", cfg.EngineConfig, cfg.EngineConfigPath))
-	if err != nil {
+	if err := drivers.WriteSSHFile(d, cfg.EngineConfigPath, []byte(cfg.EngineConfig)); err != nil {
 		return err
 	}
-	if err := cmd.Run(); err != nil {
+
+	if err := provisioner.StartDocker(); err != nil {
 		return err
 	}
 
-	if err := d.StartDocker(); err != nil {
+	return h.waitForDocker(ctx)
+}
+
+// waitForDocker waits for the daemon of the host to answer the Docker API
+// over TLS with the client certificate, so a daemon that did not come back
+// up, or ignored its configuration, does not go unnoticed.
+func (h *Host) waitForDocker(ctx context.Context) error {
+	if h.ClientCertPath == "" {
+		return nil
+	}
+
+	dockerURL, err := h.Driver.GetURL()
+	if err != nil {
 		return err
 	}
 
-	return nil
-}
+	tlsConfig, err := utils.GetDockerTLSConfig(h.CaCertPath, h.ClientCertPath, h.ClientKeyPath)
+	if err != nil {
+		return err
+	}
 
-func (h *Host) generateDockerConfig(dockerPort int, caCertPath string, serverKeyPath string, serverCertPath string) *DockerConfig {
-	d := h.Driver
-	var (
-		daemonOpts    string
-		daemonOptsCfg string
-		daemonCfg     string
-	)
+	h.logger().Infof("Waiting for Docker on %s...", dockerURL)
 
-	// TODO @ehazlett: template?
-	defaultDaemonOpts := fmt.Sprintf(`--tlsverify \
---tlscacert=%s \
---tlskey=%s \
---tlscert=%s`, caCertPath, serverKeyPath, serverCertPath)
+	return utils.WaitForDocker(ctx, dockerURL, tlsConfig, dockerBackoff)
+}
 
-	switch d.DriverName() {
+// generateDockerConfig returns the daemon configuration of the host, which
+// is rendered by the provisioner for the OS of the host.
+func (h *Host) generateDockerConfig(provisioner provision.Provisioner, dockerPort int, caCertPath string, serverKeyPath string, serverCertPath string) *provision.DockerConfig {
+	args := []string{}
+	for _, key := range h.labelKeys() {
+		args = append(args, fmt.Sprintf("--label=%s=%s", key, h.Labels[key]))
+	}
+	args = append(args, h.EngineOptions.daemonArgs()...)
+
+	return provisioner.GenerateDockerConfig(provision.DockerOptions{
+		Port:           dockerPort,
+		CaCertPath:     caCertPath,
+		ServerCertPath: serverCertPath,
+		ServerKeyPath:  serverKeyPath,
+		Args:           args,
+		Env:            h.EngineOptions.Env,
+	})
+}
 
-	case "virtualbox", "vmwarefusion", "vmwarevsphere", "hyper-v":
-		daemonOpts = fmt.Sprintf("-H tcp://0.0.0.0:%d", dockerPort)
-		daemonOptsCfg = path.Join(d.GetDockerConfigDir(), "profile")
-		opts := fmt.Sprintf("%s %s", defaultDaemonOpts, daemonOpts)
-		daemonCfg = fmt.Sprintf(`EXTRA_ARGS='%s'
-CACERT=%s
-SERVERCERT=%s
-SERVERKEY=%s
-DOCKER_TLS=no`, opts, caCertPath, serverKeyPath, serverCertPath)
-	default:
-		daemonOpts = fmt.Sprintf("--host=unix:///var/run/docker.sock --host=tcp://0.0.0.0:%d", dockerPort)
-		daemonOptsCfg = "/etc/default/docker"
-		opts := fmt.Sprintf("%s %s", defaultDaemonOpts, daemonOpts)
-		daemonCfg = fmt.Sprintf("export DOCKER_OPTS='%s'", opts)
-	}
+// Create creates the host with its driver, installs and configures Docker
+// and runs the provision scripts. Each step is recorded in the host config
+// as it completes, so a failed create can be resumed with resumeCreate.
+func (h *Host) Create(ctx context.Context, name string) (err error) {
+	defer h.recordEvent(eventCreate, time.Now(), &err)
 
-	return &DockerConfig{
-		EngineConfig:     daemonCfg,
-		EngineConfigPath: daemonOptsCfg,
+	if _, err := ValidateHostName(name); err != nil {
+		return err
 	}
+
+	h.CreateIncomplete = true
+	h.CreateStep = ""
+
+	return h.runCreateSteps(ctx)
 }
 
-func (h *Host) Create(name string) error {
-	name, err := ValidateHostName(name)
-	if err != nil {
+func (h *Host) Start(ctx context.Context) (err error) {
+	defer h.recordEvent(eventStart, time.Now(), &err)
+
+	if err := h.Driver.Start(ctx); err != nil {
+		return err
+	}
+	if err := h.waitForDocker(ctx); err != nil {
 		return err
 	}
+	return h.runHook("post-start", h.Hooks.PostStart)
+}
+
+func (h *Host) Restart(ctx context.Context) (err error) {
+	defer h.recordEvent(eventRestart, time.Now(), &err)
 
-	if err := h.Driver.Create(); err != nil {
+	if err := h.Driver.Restart(); err != nil {
+		return err
+	}
+	if err := h.waitForDocker(ctx); err != nil {
 		return err
 	}
+	return h.runHook("post-start", h.Hooks.PostStart)
+}
 
-	if err := h.SaveConfig(); err != nil {
+func (h *Host) Stop(ctx context.Context) (err error) {
+	defer h.recordEvent(eventStop, time.Now(), &err)
+
+	return h.Driver.Stop(ctx)
+}
+
+func (h *Host) Kill() (err error) {
+	defer h.recordEvent(eventKill, time.Now(), &err)
+
+	return h.Driver.Kill()
+}
+
+func (h *Host) Upgrade() (err error) {
+	defer h.recordEvent(eventUpgrade, time.Now(), &err)
+
+	provisioner, err := provision.DetectProvisioner(h.Driver)
+	if err != nil {
 		return err
 	}
+	return provisioner.UpgradeDocker()
+}
 
-	return nil
+func (h *Host) Pause() (err error) {
+	defer h.recordEvent(eventPause, time.Now(), &err)
+
+	pauser, ok := h.Driver.(drivers.Pauser)
+	if !ok || !drivers.Supports(h.Driver, drivers.CapabilityPause) {
+		return drivers.NotSupported(h.Driver, drivers.CapabilityPause)
+	}
+	return pauser.Pause()
 }
 
-func (h *Host) Start() error {
-	return h.Driver.Start()
+func (h *Host) Unpause() (err error) {
+	defer h.recordEvent(eventUnpause, time.Now(), &err)
+
+	pauser, ok := h.Driver.(drivers.Pauser)
+	if !ok || !drivers.Supports(h.Driver, drivers.CapabilityPause) {
+		return drivers.NotSupported(h.Driver, drivers.CapabilityPause)
+	}
+	return pauser.Unpause()
 }
 
-func (h *Host) Stop() error {
-	return h.Driver.Stop()
+// Resize changes the resources of the host and saves the driver config,
+// which may keep track of them.
+func (h *Host) Resize(cpus int, memory int) (err error) {
+	defer h.recordEvent(eventResize, time.Now(), &err)
+
+	resizer, ok := h.Driver.(drivers.Resizer)
+	if !ok || !drivers.Supports(h.Driver, drivers.CapabilityResize) {
+		return drivers.NotSupported(h.Driver, drivers.CapabilityResize)
+	}
+	if err := resizer.Resize(cpus, memory); err != nil {
+		return err
+	}
+	return h.SaveConfig()
 }
 
-func (h *Host) Upgrade() error {
-	return h.Driver.Upgrade()
+func (h *Host) Snapshot(name string) (err error) {
+	defer h.recordEvent(eventSnapshot, time.Now(), &err)
+
+	snapshotter, ok := h.Driver.(drivers.Snapshotter)
+	if !ok || !drivers.Supports(h.Driver, drivers.CapabilitySnapshot) {
+		return drivers.NotSupported(h.Driver, drivers.CapabilitySnapshot)
+	}
+	return snapshotter.Snapshot(name)
 }
 
-func (h *Host) Remove(force bool) error {
-	if err := h.Driver.Remove(); err != nil {
+func (h *Host) RestoreSnapshot(name string) (err error) {
+	defer h.recordEvent(eventRestoreSnapshot, time.Now(), &err)
+
+	snapshotter, ok := h.Driver.(drivers.Snapshotter)
+	if !ok || !drivers.Supports(h.Driver, drivers.CapabilitySnapshot) {
+		return drivers.NotSupported(h.Driver, drivers.CapabilitySnapshot)
+	}
+	return snapshotter.RestoreSnapshot(name)
+}
+
+func (h *Host) Remove(ctx context.Context, force bool) (err error) {
+	defer h.recordEvent(eventRemove, time.Now(), &err)
+
+	if err := h.runPreRemoveHook(); err != nil {
+		if !force {
+			return err
+		}
+		log.Warnf("Removing %s anyway: %s", h.Name, err)
+	}
+	if err := h.Driver.Remove(ctx); err != nil {
 		if !force {
 			return err
 		}
@@ -293,6 +486,57 @@ func (h *Host) Remove(force bool) error {
 	return h.removeStorePath()
 }
 
+// updateLastKnownState saves st and url as the last known state of the
+// host, if they changed, and returns since when the host is in st as far as
+// machine knows.
+func (h *Host) updateLastKnownState(st state.State, url string) time.Time {
+	if h.LastKnownState == st && h.LastKnownURL == url {
+		return h.StateUpdatedAt
+	}
+
+	if h.LastKnownState != st || h.StateUpdatedAt.IsZero() {
+		h.StateUpdatedAt = time.Now()
+	}
+	h.LastKnownState = st
+	h.LastKnownURL = url
+
+	if err := h.saveLastKnownState(); err != nil {
+		log.Debugf("error saving state for host %s: %s", h.Name, err)
+	}
+	return h.StateUpdatedAt
+}
+
+// saveLastKnownState writes the last known state of the host to its config
+// as it is on disk, leaving the rest alone: another command may have saved
+// the host since it was loaded, e.g. while "ls" waited for its driver.
+func (h *Host) saveLastKnownState() error {
+	path := filepath.Join(h.storePath, "config.json")
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(data, &config); err != nil {
+		return err
+	}
+	fields := map[string]interface{}{
+		"LastKnownState": h.LastKnownState,
+		"LastKnownURL":   h.LastKnownURL,
+		"StateUpdatedAt": h.StateUpdatedAt,
+	}
+	for key, value := range fields {
+		if config[key], err = json.Marshal(value); err != nil {
+			return err
+		}
+	}
+
+	if data, err = json.Marshal(config); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
 func (h *Host) removeStorePath() error {
 	file, err := os.Stat(h.storePath)
 	if err != nil {
@@ -304,6 +548,16 @@ func (h *Host) removeStorePath() error {
 	return os.RemoveAll(h.storePath)
 }
 
+// labelKeys returns the keys of the host labels in a stable order.
+func (h *Host) labelKeys() []string {
+	keys := make([]string, 0, len(h.Labels))
+	for key := range h.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func (h *Host) GetURL() (string, error) {
 	return h.Driver.GetURL()
 }
@@ -330,6 +584,7 @@ func (h *Host) LoadConfig() error {
 	if err := json.Unmarshal(data, &h); err != nil {
 		return err
 	}
+	utils.RegisterSecrets(h.Driver)
 
 	return nil
 }
@@ -10,7 +10,10 @@ import (
 	"testing"
 
 	_ "github.com/docker/machine/drivers/none"
+	"github.com/docker/machine/provision"
+	"github.com/docker/machine/state"
 	"github.com/docker/machine/utils"
+	"golang.org/x/net/context"
 )
 
 const (
@@ -146,7 +149,7 @@ func TestGenerateClientCertificate(t *testing.T) {
 	}
 }
 
-func TestGenerateDockerConfigNonLocal(t *testing.T) {
+func TestGenerateDockerConfigDebian(t *testing.T) {
 	host, err := NewHost(hostTestName, hostTestDriverName, hostTestStorePath, hostTestCaCert, hostTestPrivateKey)
 	if err != nil {
 		t.Fatal(err)
@@ -158,7 +161,7 @@ func TestGenerateDockerConfigNonLocal(t *testing.T) {
 	serverCertPath := "/test/server-cert"
 	engineConfigPath := "/etc/default/docker"
 
-	dockerCfg := host.generateDockerConfig(dockerPort, caCertPath, serverKeyPath, serverCertPath)
+	dockerCfg := host.generateDockerConfig(provision.NewDebianProvisioner(host.Driver), dockerPort, caCertPath, serverKeyPath, serverCertPath)
 
 	if dockerCfg.EngineConfigPath != engineConfigPath {
 		t.Fatalf("expected engine path %s; received %s", engineConfigPath, dockerCfg.EngineConfigPath)
@@ -190,7 +193,7 @@ func TestMachinePort(t *testing.T) {
 	}
 	flags := getTestDriverFlags()
 
-	_, err = store.Create(hostTestName, hostTestDriverName, flags)
+	_, err = store.Create(context.Background(), hostTestName, hostTestDriverName, flags)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -199,7 +202,7 @@ func TestMachinePort(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	cfg := host.generateDockerConfig(dockerPort, "", "", "")
+	cfg := host.generateDockerConfig(provision.NewDebianProvisioner(host.Driver), dockerPort, "", "", "")
 
 	re := regexp.MustCompile("--host=tcp://.*:(.+)")
 	m := re.FindStringSubmatch(cfg.EngineConfig)
@@ -215,7 +218,7 @@ func TestMachinePort(t *testing.T) {
 		t.Errorf("expected url %s; received %s", bindUrl, url)
 	}
 
-	if err := store.Remove(hostTestName, true); err != nil {
+	if err := store.Remove(context.Background(), hostTestName, true); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -229,7 +232,7 @@ func TestMachineCustomPort(t *testing.T) {
 	}
 	flags := getTestDriverFlags()
 
-	_, err = store.Create(hostTestName, hostTestDriverName, flags)
+	_, err = store.Create(context.Background(), hostTestName, hostTestDriverName, flags)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -238,7 +241,7 @@ func TestMachineCustomPort(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	cfg := host.generateDockerConfig(dockerPort, "", "", "")
+	cfg := host.generateDockerConfig(provision.NewDebianProvisioner(host.Driver), dockerPort, "", "", "")
 
 	re := regexp.MustCompile("--host=tcp://.*:(.+)")
 	m := re.FindStringSubmatch(cfg.EngineConfig)
@@ -254,7 +257,7 @@ func TestMachineCustomPort(t *testing.T) {
 		t.Errorf("expected url %s; received %s", bindUrl, url)
 	}
 
-	if err := store.Remove(hostTestName, true); err != nil {
+	if err := store.Remove(context.Background(), hostTestName, true); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -266,7 +269,7 @@ func TestHostConfig(t *testing.T) {
 	}
 
 	flags := getTestDriverFlags()
-	host, err := store.Create(hostTestName, hostTestDriverName, flags)
+	host, err := store.Create(context.Background(), hostTestName, hostTestDriverName, flags)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -280,7 +283,82 @@ func TestHostConfig(t *testing.T) {
 	}
 
 	// cleanup
-	if err := store.Remove(hostTestName, true); err != nil {
+	if err := store.Remove(context.Background(), hostTestName, true); err != nil {
 		t.Fatal(err)
 	}
 }
+
+func TestUpdateLastKnownState(t *testing.T) {
+	store, err := getTestStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(store.Path)
+
+	host, err := store.Create(context.Background(), hostTestName, hostTestDriverName, getTestDriverFlags())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another command changes the host while this one has it loaded
+	other, err := store.Load(hostTestName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Labels = map[string]string{"team": "web"}
+	if err := other.SaveConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	host.updateLastKnownState(state.Stopped, "tcp://1.2.3.4:2376")
+
+	loaded, err := store.Load(hostTestName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Labels["team"] != "web" {
+		t.Fatalf("expected the change of the other command to be kept; received %v", loaded.Labels)
+	}
+	if loaded.LastKnownState != state.Stopped || loaded.LastKnownURL != "tcp://1.2.3.4:2376" || loaded.StateUpdatedAt.IsZero() {
+		t.Fatalf("expected the last known state to be saved; received %s %s %s", loaded.LastKnownState, loaded.LastKnownURL, loaded.StateUpdatedAt)
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	labels, err := ParseLabels([]string{"team=web", "expiry=2015-06-01", "temporary"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{"team": "web", "expiry": "2015-06-01", "temporary": ""}
+	if len(labels) != len(expected) {
+		t.Fatalf("expected %v; received %v", expected, labels)
+	}
+	for k, v := range expected {
+		if labels[k] != v {
+			t.Fatalf("expected label %s=%s; received %s", k, v, labels[k])
+		}
+	}
+
+	for _, label := range []string{"=web", "team=a b", "team='web'", "team=$(reboot)", "team=`reboot`"} {
+		if _, err := ParseLabels([]string{label}); err == nil {
+			t.Fatalf("expected error for label %q", label)
+		}
+	}
+}
+
+func TestGenerateDockerConfigLabels(t *testing.T) {
+	host, err := NewHost(hostTestName, hostTestDriverName, hostTestStorePath, hostTestCaCert, hostTestPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host.Labels = map[string]string{"team": "web", "project": "machine"}
+
+	dockerCfg := host.generateDockerConfig(provision.NewDebianProvisioner(host.Driver), 2376, "", "", "")
+
+	for _, label := range []string{"--label=team=web", "--label=project=machine"} {
+		if strings.Index(dockerCfg.EngineConfig, label) == -1 {
+			t.Fatalf("expected %s in engine config; received %s", label, dockerCfg.EngineConfig)
+		}
+	}
+}
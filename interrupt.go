@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/codegangsta/cli"
+	"golang.org/x/net/context"
+)
+
+// interruptExitCode is the status machine exits with when it is interrupted
+// a second time, as a shell would report for SIGINT.
+const interruptExitCode = 130
+
+// timeoutFlag bounds how long the commands which wait for the provider may
+// take.
+var timeoutFlag = cli.DurationFlag{
+	Name:  "timeout",
+	Usage: "Give up after this long, e.g. 10m, instead of waiting for the provider as long as it takes",
+}
+
+// commandContext returns the context for the operations of a command. It is
+// done once the --timeout of the command has passed or when machine is
+// interrupted, so the operation in progress gives up and cleans up after
+// itself. Interrupting machine again exits at once. The returned function
+// must be called once the command is done.
+func commandContext(c *cli.Context) (context.Context, context.CancelFunc) {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if timeout := c.Duration("timeout"); timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		var sig os.Signal
+		select {
+		case sig = <-interrupts:
+			cancel()
+		case <-ctx.Done():
+			// The command may still be cleaning up after its timeout, which
+			// the first interrupt must not cut short either
+			sig = <-interrupts
+		}
+		log.Warnf("Received %s, stopping. Interrupt again to exit at once.", sig)
+
+		<-interrupts
+		log.Warn("Exiting without cleaning up")
+		os.Exit(interruptExitCode)
+	}()
+
+	return ctx, func() {
+		signal.Stop(interrupts)
+		cancel()
+	}
+}
@@ -1,12 +1,183 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/docker/machine/drivers"
+	"github.com/docker/machine/utils"
 )
 
+// The values of --log-format.
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+var (
+	logMu sync.Mutex
+
+	// consoleLevel is the level of the entries written to standard error.
+	// The logger itself logs at the debug level while operation logs are
+	// open, so they get every entry.
+	consoleLevel = log.InfoLevel
+
+	logFormat = logFormatText
+
+	operationLogs = map[*operationLog]bool{}
+)
+
+func init() {
+	log.SetFormatter(&consoleFormatter{Formatter: &log.TextFormatter{}})
+	log.AddHook(utils.RedactHook{})
+	log.AddHook(operationLogHook{})
+	log.AddHook(utils.ProgressHook{})
+}
+
 func initLogging(lvl log.Level) {
+	logMu.Lock()
+	defer logMu.Unlock()
+
 	log.SetOutput(os.Stderr)
-	log.SetLevel(lvl)
+	consoleLevel = lvl
+	if len(operationLogs) == 0 {
+		log.SetLevel(lvl)
+	}
+}
+
+// setLogFormat makes machine log as text or as JSON, one entry per line.
+// Driver plugins are told through the environment.
+func setLogFormat(format string) error {
+	var formatter log.Formatter
+	switch format {
+	case "", logFormatText:
+		format = logFormatText
+		formatter = &log.TextFormatter{}
+	case logFormatJSON:
+		formatter = &log.JSONFormatter{}
+	default:
+		return fmt.Errorf("Invalid --log-format %q: expected text or json", format)
+	}
+
+	logMu.Lock()
+	defer logMu.Unlock()
+	logFormat = format
+	log.SetFormatter(&consoleFormatter{Formatter: formatter})
+	return os.Setenv("MACHINE_LOG_FORMAT", format)
+}
+
+// consoleFormatter formats the entries written to standard error, leaving
+// out those above the console level.
+type consoleFormatter struct {
+	log.Formatter
+}
+
+func (f *consoleFormatter) Format(entry *log.Entry) ([]byte, error) {
+	logMu.Lock()
+	level := consoleLevel
+	logMu.Unlock()
+
+	if entry.Level > level {
+		return nil, nil
+	}
+	return f.Formatter.Format(entry)
+}
+
+// logger returns the logger for the entries about h. They have the name of
+// the machine in their "machine" field, as those of its driver do, so they
+// only end up in the operation logs of h.
+func (h *Host) logger() *log.Entry {
+	return drivers.Logger(h.Driver).WithField("machine", h.Name)
+}
+
+// operationLog is the full debug log of an operation on a machine, such as
+// create.log, kept in the directory of the machine for post-mortems. It gets
+// the entries about its machine and those about no machine in particular,
+// which includes the output of the commands run over SSH.
+type operationLog struct {
+	machine   string
+	file      *os.File
+	formatter log.Formatter
+}
+
+// openOperationLog starts appending every entry logged, whatever the level
+// of the console, to the log of operation in the directory of the host,
+// until it is closed. Failing to open it is not an error of the operation.
+func (h *Host) openOperationLog(operation string) *operationLog {
+	path := filepath.Join(h.storePath, operation+".log")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		log.Warnf("Error opening the log of %s: %s", operation, err)
+		return nil
+	}
+
+	logMu.Lock()
+	l := &operationLog{machine: h.Name, file: file, formatter: &log.TextFormatter{DisableColors: true}}
+	if logFormat == logFormatJSON {
+		l.formatter = &log.JSONFormatter{}
+	}
+	operationLogs[l] = true
+	log.SetLevel(log.DebugLevel)
+	logMu.Unlock()
+
+	h.logger().Debugf("Starting %s of %s, logging to %s", operation, h.Name, path)
+	return l
+}
+
+// close stops the log and puts the logger back to the console level once
+// no operation log is open. It does nothing on a nil log, which failed to
+// open.
+func (l *operationLog) close() {
+	if l == nil {
+		return
+	}
+
+	logMu.Lock()
+	defer logMu.Unlock()
+	delete(operationLogs, l)
+	if len(operationLogs) == 0 {
+		log.SetLevel(consoleLevel)
+	}
+	l.file.Close()
+}
+
+// operationLogHook writes the entries to the open operation logs.
+type operationLogHook struct{}
+
+func (operationLogHook) Levels() []log.Level {
+	return utils.RedactHook{}.Levels()
+}
+
+func (operationLogHook) Fire(entry *log.Entry) error {
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	for l := range operationLogs {
+		if machine, ok := entry.Data["machine"]; ok && machine != l.machine {
+			continue
+		}
+
+		// Formatters add to the fields, which the console formats next
+		data := log.Fields{}
+		for k, v := range entry.Data {
+			data[k] = v
+		}
+		serialized, err := l.formatter.Format(&log.Entry{
+			Logger:  entry.Logger,
+			Data:    data,
+			Time:    entry.Time,
+			Level:   entry.Level,
+			Message: entry.Message,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := l.file.Write(serialized); err != nil {
+			return err
+		}
+	}
+	return nil
 }
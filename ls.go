@@ -0,0 +1,381 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/machine/state"
+	"github.com/docker/machine/utils"
+)
+
+// hostFilter holds the criteria given with "ls --filter". Values for the
+// same key are OR'ed, different keys are AND'ed.
+type hostFilter struct {
+	DriverName []string
+	State      []string
+	Name       []*regexp.Regexp
+	Label      []string
+}
+
+// hostListOptions controls how much information is collected for each host
+// by "ls".
+type hostListOptions struct {
+	Timeout       time.Duration
+	Parallel      int
+	Cached        bool
+	DockerVersion bool
+	DockerTLS     *tls.Config
+}
+
+// parseFilters parses filters of the form "key=value". Several filters can
+// be given in one string separated by commas.
+func parseFilters(filters []string) (hostFilter, error) {
+	filter := hostFilter{}
+
+	for _, f := range filters {
+		for _, term := range strings.Split(f, ",") {
+			if term == "" {
+				continue
+			}
+
+			kv := strings.SplitN(term, "=", 2)
+			if len(kv) != 2 {
+				return filter, fmt.Errorf("Invalid filter %q, filters must be of the form key=value", term)
+			}
+
+			key, value := strings.ToLower(kv[0]), kv[1]
+			switch key {
+			case "driver":
+				filter.DriverName = append(filter.DriverName, value)
+			case "state":
+				filter.State = append(filter.State, value)
+			case "name":
+				re, err := regexp.Compile(value)
+				if err != nil {
+					return filter, fmt.Errorf("Invalid name filter %q: %s", value, err)
+				}
+				filter.Name = append(filter.Name, re)
+			case "label":
+				filter.Label = append(filter.Label, value)
+			default:
+				return filter, fmt.Errorf("Unsupported filter key %q", key)
+			}
+		}
+	}
+
+	return filter, nil
+}
+
+// filterHosts returns the hosts matching the filters which do not require
+// contacting the host.
+func filterHosts(hosts []Host, filter hostFilter) []Host {
+	filtered := []Host{}
+
+	for _, host := range hosts {
+		if !matchDriver(host.DriverName, filter.DriverName) {
+			continue
+		}
+		if !matchName(host.Name, filter.Name) {
+			continue
+		}
+		if !matchLabels(host.Labels, filter.Label) {
+			continue
+		}
+		filtered = append(filtered, host)
+	}
+
+	return filtered
+}
+
+// filterHostListItems returns the items matching the state filter.
+func filterHostListItems(items []hostListItem, filter hostFilter) []hostListItem {
+	filtered := []hostListItem{}
+
+	for _, item := range items {
+		if !matchState(item.State, filter.State) {
+			continue
+		}
+		filtered = append(filtered, item)
+	}
+
+	return filtered
+}
+
+func matchDriver(driverName string, driverNames []string) bool {
+	if len(driverNames) == 0 {
+		return true
+	}
+	for _, n := range driverNames {
+		if strings.EqualFold(n, driverName) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchName(name string, patterns []*regexp.Regexp) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, re := range patterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchLabels matches labels given either as "key" or "key=value".
+func matchLabels(labels map[string]string, selectors []string) bool {
+	if len(selectors) == 0 {
+		return true
+	}
+	for _, selector := range selectors {
+		kv := strings.SplitN(selector, "=", 2)
+		value, ok := labels[kv[0]]
+		if ok && (len(kv) == 1 || kv[1] == value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchState(s state.State, states []string) bool {
+	if len(states) == 0 {
+		return true
+	}
+	for _, st := range states {
+		if strings.EqualFold(st, s.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// getHostListItems collects the list information for all hosts, querying
+// at most opts.Parallel hosts at the same time.
+func getHostListItems(hosts []Host, store Store, opts hostListOptions) []hostListItem {
+	items := []hostListItem{}
+	hostListItems := make(chan hostListItem, len(hosts))
+
+	parallel := opts.Parallel
+	if parallel <= 0 {
+		parallel = len(hosts)
+	}
+	sem := make(chan struct{}, parallel)
+
+	// A host which timed out holds its slot until its driver returns, so
+	// that hung hosts do not make more than parallel queries run at once.
+	// The hosts waiting for a slot give up once the listing has taken as
+	// long as it would have if every host had timed out.
+	var deadline chan struct{}
+	if opts.Timeout > 0 && !opts.Cached {
+		deadline = make(chan struct{})
+		rounds := (len(hosts) + parallel - 1) / parallel
+		timer := time.AfterFunc(opts.Timeout*time.Duration(rounds), func() { close(deadline) })
+		defer timer.Stop()
+	}
+
+	for _, host := range hosts {
+		go func(host Host) {
+			select {
+			case sem <- struct{}{}:
+			case <-deadline:
+				hostListItems <- timedOutHostListItem(host, fmt.Sprintf("timed out after %s waiting for other machines to answer", opts.Timeout))
+				return
+			}
+			release := func() { <-sem }
+
+			if opts.Cached {
+				hostListItems <- getCachedHostListItem(host, store)
+				release()
+			} else {
+				hostListItems <- getHostListItem(host, store, opts, release)
+			}
+		}(host)
+	}
+
+	for i := 0; i < len(hosts); i++ {
+		items = append(items, <-hostListItems)
+	}
+
+	return items
+}
+
+// getHostListItem collects the list information for a single host, giving
+// up after opts.Timeout so a single unreachable host does not stall the
+// whole listing. The state found is saved to the store for "ls --cached".
+// release is called once the driver has returned, even if it timed out.
+func getHostListItem(host Host, store Store, opts hostListOptions, release func()) hostListItem {
+	done := make(chan hostListItem, 1)
+
+	go func() {
+		defer release()
+
+		items := make(chan hostListItem, 1)
+		getHostState(host, store, items)
+		item := <-items
+
+		if opts.DockerVersion && item.State == state.Running && item.URL != "" {
+			version, err := utils.GetDockerVersion(item.URL, opts.DockerTLS, opts.Timeout)
+			if err != nil {
+				log.Debugf("error getting Docker version for host %s: %s", host.Name, err)
+			} else {
+				item.DockerVersion = version.Version
+			}
+		}
+
+		done <- item
+	}()
+
+	var timeout <-chan time.Time
+	if opts.Timeout > 0 {
+		timeout = time.After(opts.Timeout)
+	}
+
+	select {
+	case item := <-done:
+		if item.Error == "" {
+			saveLastKnownState(host, item)
+		}
+		return item
+	case <-timeout:
+		return timedOutHostListItem(host, fmt.Sprintf("timed out after %s", opts.Timeout))
+	}
+}
+
+// timedOutHostListItem returns the list information for a host whose state
+// could not be found in time, with the reason as its error.
+func timedOutHostListItem(host Host, reason string) hostListItem {
+	return hostListItem{
+		Name:       host.Name,
+		DriverName: host.DriverName,
+		State:      state.Timeout,
+		CreatedAt:  host.CreatedAt,
+		Error:      reason,
+	}
+}
+
+// getCachedHostListItem returns the list information for a host from the
+// state last saved to the store, without contacting the host.
+func getCachedHostListItem(host Host, store Store) hostListItem {
+	if host.CreateIncomplete {
+		return getIncompleteHostListItem(host, store)
+	}
+
+	isActive, err := store.IsActive(&host)
+	if err != nil {
+		log.Debugf("error determining whether host %q is active: %s",
+			host.Name, err)
+	}
+
+	return hostListItem{
+		Name:       host.Name,
+		Active:     isActive,
+		DriverName: host.DriverName,
+		State:      host.LastKnownState,
+		URL:        host.LastKnownURL,
+		IP:         getHostFromURL(host.LastKnownURL),
+		CreatedAt:  host.CreatedAt,
+		CertExpiry: getCertExpiry(host),
+	}
+}
+
+// getIncompleteHostListItem returns the list information for a host which
+// "machine create" did not finish, without contacting the host as it may
+// not exist on the provider.
+func getIncompleteHostListItem(host Host, store Store) hostListItem {
+	isActive, err := store.IsActive(&host)
+	if err != nil {
+		log.Debugf("error determining whether host %q is active: %s",
+			host.Name, err)
+	}
+
+	return hostListItem{
+		Name:       host.Name,
+		Active:     isActive,
+		DriverName: host.DriverName,
+		State:      state.Incomplete,
+		CreatedAt:  host.CreatedAt,
+		Error:      incompleteReason(&host),
+	}
+}
+
+// saveLastKnownState records the state found by "ls" in the host config.
+func saveLastKnownState(host Host, item hostListItem) {
+	host.updateLastKnownState(item.State, item.URL)
+}
+
+// getHostFromURL returns the address part of a Docker URL such as
+// tcp://1.2.3.4:2376, or an empty string for non-TCP URLs.
+func getHostFromURL(dockerURL string) string {
+	u, err := url.Parse(dockerURL)
+	if err != nil || u.Scheme != "tcp" {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		return u.Host
+	}
+	return host
+}
+
+// getCertExpiry returns the expiry of the server certificate generated for
+// the host, or the zero time if it has none.
+func getCertExpiry(host Host) time.Time {
+	expiry, err := utils.GetCertExpiry(filepath.Join(host.storePath, "server.pem"))
+	if err != nil {
+		return time.Time{}
+	}
+	return expiry
+}
+
+// printHostList writes items to w as a table, as JSON when format is
+// "json", or by executing format as a Go template for each item.
+func printHostList(w io.Writer, items []hostListItem, format string) error {
+	switch format {
+	case "":
+		fmt.Fprintln(w, "NAME\tACTIVE\tDRIVER\tSTATE\tURL\tERRORS")
+		for _, item := range items {
+			activeString := ""
+			if item.Active {
+				activeString = "*"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				item.Name, activeString, item.DriverName, item.State, item.URL, item.Error)
+		}
+		return nil
+	case "json":
+		data, err := json.MarshalIndent(items, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(data))
+		return nil
+	}
+
+	format = strings.NewReplacer(`\t`, "\t", `\n`, "\n").Replace(format)
+	tmpl, err := template.New("ls").Parse(format)
+	if err != nil {
+		return fmt.Errorf("Invalid format template: %s", err)
+	}
+
+	for _, item := range items {
+		if err := tmpl.Execute(w, item); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+
+	return nil
+}
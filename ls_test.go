@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/state"
+)
+
+func TestParseFilters(t *testing.T) {
+	filter, err := parseFilters([]string{"driver=virtualbox,state=Running", "name=^dev", "driver=none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(filter.DriverName) != 2 {
+		t.Fatalf("expected 2 driver filters; received %d", len(filter.DriverName))
+	}
+	if len(filter.State) != 1 || filter.State[0] != "Running" {
+		t.Fatalf("expected state filter Running; received %v", filter.State)
+	}
+	if len(filter.Name) != 1 {
+		t.Fatalf("expected 1 name filter; received %d", len(filter.Name))
+	}
+}
+
+func TestParseFiltersInvalid(t *testing.T) {
+	for _, f := range []string{"driver", "foo=bar", "name=("} {
+		if _, err := parseFilters([]string{f}); err == nil {
+			t.Fatalf("expected error for filter %q", f)
+		}
+	}
+}
+
+func TestFilterHosts(t *testing.T) {
+	hosts := []Host{
+		{Name: "dev", DriverName: "virtualbox"},
+		{Name: "dev-aws", DriverName: "amazonec2"},
+		{Name: "prod", DriverName: "amazonec2"},
+	}
+
+	filter, err := parseFilters([]string{"driver=amazonec2,name=^dev"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filtered := filterHosts(hosts, filter)
+	if len(filtered) != 1 || filtered[0].Name != "dev-aws" {
+		t.Fatalf("expected only dev-aws; received %v", filtered)
+	}
+}
+
+func TestFilterHostsLabels(t *testing.T) {
+	hosts := []Host{
+		{Name: "web", Labels: map[string]string{"team": "web", "expiry": "2015-06-01"}},
+		{Name: "db", Labels: map[string]string{"team": "db"}},
+		{Name: "none"},
+	}
+
+	filter, err := parseFilters([]string{"label=team=web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered := filterHosts(hosts, filter)
+	if len(filtered) != 1 || filtered[0].Name != "web" {
+		t.Fatalf("expected only web; received %v", filtered)
+	}
+
+	filter, err = parseFilters([]string{"label=team"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filtered := filterHosts(hosts, filter); len(filtered) != 2 {
+		t.Fatalf("expected 2 hosts with a team label; received %v", filtered)
+	}
+}
+
+func TestFilterHostListItems(t *testing.T) {
+	items := []hostListItem{
+		{Name: "foo", State: state.Running},
+		{Name: "bar", State: state.Stopped},
+	}
+
+	filter, err := parseFilters([]string{"state=stopped"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filtered := filterHostListItems(items, filter)
+	if len(filtered) != 1 || filtered[0].Name != "bar" {
+		t.Fatalf("expected only bar; received %v", filtered)
+	}
+}
+
+func TestPrintHostListTemplate(t *testing.T) {
+	items := []hostListItem{
+		{Name: "foo", State: state.Running, IP: "1.2.3.4"},
+		{Name: "bar", State: state.Stopped},
+	}
+
+	var buf bytes.Buffer
+	if err := printHostList(&buf, items, `{{.Name}} {{.State}} {{.IP}}`); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "foo Running 1.2.3.4\nbar Stopped \n"
+	if buf.String() != expected {
+		t.Fatalf("expected %q; received %q", expected, buf.String())
+	}
+}
+
+func TestGetHostFromURL(t *testing.T) {
+	if ip := getHostFromURL("tcp://1.2.3.4:2376"); ip != "1.2.3.4" {
+		t.Fatalf("expected 1.2.3.4; received %s", ip)
+	}
+	if ip := getHostFromURL("unix:///var/run/docker.sock"); ip != "" {
+		t.Fatalf("expected no IP for unix socket; received %s", ip)
+	}
+}
+
+type slowDriver struct {
+	FakeDriver
+}
+
+func (d *slowDriver) GetState() (state.State, error) {
+	time.Sleep(time.Second)
+	return state.Running, nil
+}
+
+func TestGetHostListItemsTimeout(t *testing.T) {
+	storePath, err := ioutil.TempDir("", ".docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storePath)
+
+	store := NewStore(storePath, "", "")
+	hosts := []Host{
+		{Name: "fast", DriverName: "fakedriver", Driver: &FakeDriver{MockState: state.Running}, storePath: storePath},
+		{Name: "slow", DriverName: "fakedriver", Driver: &slowDriver{}, storePath: storePath},
+	}
+
+	items := getHostListItems(hosts, *store, hostListOptions{
+		Timeout:  50 * time.Millisecond,
+		Parallel: 2,
+	})
+	if len(items) != 2 {
+		t.Fatalf("expected 2 items; received %d", len(items))
+	}
+
+	for _, item := range items {
+		switch item.Name {
+		case "fast":
+			if item.State != state.Running || item.Error != "" {
+				t.Fatalf("expected fast host to be running without error; received %v", item)
+			}
+		case "slow":
+			if item.State != state.Timeout || !strings.Contains(item.Error, "timed out") {
+				t.Fatalf("expected slow host to time out; received %v", item)
+			}
+		}
+	}
+}
+
+// countingDriver is a hung driver which counts the calls running at once.
+type countingDriver struct {
+	FakeDriver
+	mu      *sync.Mutex
+	running *int
+	maxSeen *int
+}
+
+func (d *countingDriver) GetState() (state.State, error) {
+	d.mu.Lock()
+	*d.running++
+	if *d.running > *d.maxSeen {
+		*d.maxSeen = *d.running
+	}
+	d.mu.Unlock()
+
+	time.Sleep(200 * time.Millisecond)
+
+	d.mu.Lock()
+	*d.running--
+	d.mu.Unlock()
+	return state.Running, nil
+}
+
+func TestGetHostListItemsHungHostsHoldSlots(t *testing.T) {
+	storePath, err := ioutil.TempDir("", ".docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storePath)
+
+	var (
+		mu               sync.Mutex
+		running, maxSeen int
+	)
+	hosts := []Host{}
+	for _, name := range []string{"a", "b", "c"} {
+		driver := &countingDriver{mu: &mu, running: &running, maxSeen: &maxSeen}
+		hosts = append(hosts, Host{Name: name, DriverName: "fakedriver", Driver: driver, storePath: storePath})
+	}
+
+	store := NewStore(storePath, "", "")
+	items := getHostListItems(hosts, *store, hostListOptions{
+		Timeout:  50 * time.Millisecond,
+		Parallel: 1,
+	})
+	for _, item := range items {
+		if item.State != state.Timeout {
+			t.Fatalf("expected %s to time out; received %v", item.Name, item)
+		}
+	}
+
+	// Let the hung calls return before checking how many ran at once
+	time.Sleep(500 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if maxSeen != 1 {
+		t.Fatalf("expected the hung hosts to hold their slot; %d ran at once", maxSeen)
+	}
+}
+
+func TestGetCachedHostListItem(t *testing.T) {
+	storePath, err := ioutil.TempDir("", ".docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storePath)
+
+	store := NewStore(storePath, "", "")
+	host := Host{
+		Name:           "foo",
+		DriverName:     "fakedriver",
+		Driver:         &slowDriver{},
+		LastKnownState: state.Stopped,
+		LastKnownURL:   "tcp://1.2.3.4:2376",
+		storePath:      storePath,
+	}
+
+	item := getCachedHostListItem(host, *store)
+	if item.State != state.Stopped {
+		t.Fatalf("expected cached state Stopped; received %s", item.State)
+	}
+	if item.IP != "1.2.3.4" {
+		t.Fatalf("expected cached IP 1.2.3.4; received %s", item.IP)
+	}
+}
+
+func TestPrintHostListErrors(t *testing.T) {
+	items := []hostListItem{
+		{Name: "foo", State: state.Error, Error: "timed out after 10s"},
+	}
+
+	var buf bytes.Buffer
+	if err := printHostList(&buf, items, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), "ERRORS") || !strings.Contains(buf.String(), "timed out after 10s") {
+		t.Fatalf("expected error column in output; received %q", buf.String())
+	}
+}
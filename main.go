@@ -32,6 +32,12 @@ func main() {
 			Name:  "debug, D",
 			Usage: "Enable debug mode",
 		},
+		cli.StringFlag{
+			EnvVar: "MACHINE_LOG_FORMAT",
+			Name:   "log-format",
+			Usage:  "Log format: text or json, one entry per line",
+			Value:  logFormatText,
+		},
 		cli.StringFlag{
 			EnvVar: "MACHINE_STORAGE_PATH",
 			Name:   "storage-path",
@@ -63,5 +69,9 @@ func main() {
 		},
 	}
 
+	app.Before = func(c *cli.Context) error {
+		return setLogFormat(c.GlobalString("log-format"))
+	}
+
 	app.Run(os.Args)
 }
@@ -0,0 +1,506 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/state"
+)
+
+// runMainEnv makes the test binary run machine instead of the tests, so
+// commands which exit can be tested in a process of their own.
+const runMainEnv = "MACHINE_TEST_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// fakeMachine runs machine against hosts of the fake driver, which are
+// simulated by the fake SSH and Docker servers.
+type fakeMachine struct {
+	t      *testing.T
+	dir    string
+	ssh    *fakedriver.SSHServer
+	docker *fakedriver.DockerServer
+}
+
+func newFakeMachine(t *testing.T) *fakeMachine {
+	if _, err := exec.LookPath("ssh"); err != nil {
+		t.Skip("ssh is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshServer, err := fakedriver.NewSSHServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dockerServer, err := fakedriver.NewDockerServer(sshServer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &fakeMachine{t: t, dir: dir, ssh: sshServer, docker: dockerServer}
+}
+
+func (m *fakeMachine) close() {
+	m.docker.Close()
+	m.ssh.Close()
+	os.RemoveAll(m.dir)
+}
+
+// run runs machine with args and returns its output.
+func (m *fakeMachine) run(args ...string) (string, error) {
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), runMainEnv+"=1", "MACHINE_DIR="+m.dir)
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
+
+// mustRun runs machine with args and fails the test if it fails.
+func (m *fakeMachine) mustRun(args ...string) string {
+	output, err := m.run(args...)
+	if err != nil {
+		m.t.Fatalf("machine %s failed: %s\n%s", strings.Join(args, " "), err, output)
+	}
+	return output
+}
+
+// create creates the host name with the fake driver.
+func (m *fakeMachine) create(name string, args ...string) (string, error) {
+	args = append([]string{
+		"create", "-d", "fake",
+		"--fake-ssh-port", fmt.Sprint(m.ssh.Port),
+		"--fake-docker-port", fmt.Sprint(m.docker.Port),
+	}, args...)
+	return m.run(append(args, name)...)
+}
+
+// load loads the host name from the store.
+func (m *fakeMachine) load(name string) *Host {
+	store := NewStore(filepath.Join(m.dir, ".docker", "machines"), "", "")
+	host, err := store.Load(name)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	return host
+}
+
+// calls returns the operations run on the host name by the fake driver.
+func (m *fakeMachine) calls(name string) []string {
+	calls, err := m.load(name).Driver.(*fakedriver.Driver).Calls()
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	return calls
+}
+
+func TestFakeCreate(t *testing.T) {
+	m := newFakeMachine(t)
+	defer m.close()
+
+	output, err := m.create("foo", "--label", "env=test")
+	if err != nil {
+		t.Fatalf("create failed: %s\n%s", err, output)
+	}
+	if !strings.Contains(output, "The fake driver cannot add labels to the machine on the provider") {
+		t.Fatalf("expected a warning that the labels are not added on the provider; received %s", output)
+	}
+	for _, step := range []string{createStepInfrastructure, createStepSSH, createStepDocker, createStepCerts} {
+		if !strings.Contains(output, fmt.Sprintf("Creating foo: %s: done", step)) {
+			t.Fatalf("expected the progress of step %s; received %s", step, output)
+		}
+	}
+
+	profile, ok := m.ssh.File("/var/lib/boot2docker/profile")
+	if !ok {
+		t.Fatal("expected the daemon to be configured")
+	}
+	if !strings.Contains(profile, "--label=env=test") {
+		t.Fatalf("expected the label in the daemon configuration; received %s", profile)
+	}
+
+	host := m.load("foo")
+	if host.CreateIncomplete {
+		t.Fatal("expected create to be complete")
+	}
+
+	url := fmt.Sprintf("tcp://127.0.0.1:%d", m.docker.Port)
+	output = m.mustRun("url", "foo")
+	if strings.TrimSpace(output) != url {
+		t.Fatalf("expected URL %s; received %s", url, output)
+	}
+
+	output = m.mustRun("env", "foo")
+	if !strings.Contains(output, "export DOCKER_HOST="+url) {
+		t.Fatalf("expected DOCKER_HOST in the environment; received %s", output)
+	}
+
+	output = m.mustRun("ls")
+	if !strings.Contains(output, "foo") || !strings.Contains(output, "Running") {
+		t.Fatalf("expected foo to be running; received %s", output)
+	}
+
+	output = m.mustRun("ssh", "foo", "cat", "/etc/os-release")
+	if output != fakedriver.Boot2DockerOSRelease {
+		t.Fatalf("unexpected output of ssh: %s", output)
+	}
+}
+
+func TestFakeStartStop(t *testing.T) {
+	m := newFakeMachine(t)
+	defer m.close()
+
+	if output, err := m.create("foo"); err != nil {
+		t.Fatalf("create failed: %s\n%s", err, output)
+	}
+
+	m.mustRun("stop", "foo")
+	if st, _ := m.load("foo").Driver.GetState(); st != state.Stopped {
+		t.Fatalf("expected state Stopped; received %s", st)
+	}
+	if output := m.mustRun("ls"); !strings.Contains(output, "Stopped") {
+		t.Fatalf("expected foo to be stopped; received %s", output)
+	}
+	if _, err := m.run("stop", "foo"); err == nil {
+		t.Fatal("expected stopping a stopped host to fail")
+	}
+	if _, err := m.run("ip", "foo"); err == nil {
+		t.Fatal("expected a stopped host to have no IP")
+	}
+
+	m.mustRun("start", "foo")
+	if st, _ := m.load("foo").Driver.GetState(); st != state.Running {
+		t.Fatalf("expected state Running; received %s", st)
+	}
+
+	m.mustRun("rm", "foo")
+	if _, err := os.Stat(filepath.Join(m.dir, ".docker", "machines", "foo")); !os.IsNotExist(err) {
+		t.Fatal("expected foo to be removed")
+	}
+}
+
+func TestFakeBulkLoadFailure(t *testing.T) {
+	m := newFakeMachine(t)
+	defer m.close()
+
+	if output, err := m.create("foo"); err != nil {
+		t.Fatalf("create failed: %s\n%s", err, output)
+	}
+
+	output, err := m.run("rm", "foo", "missing")
+	if err == nil {
+		t.Fatal("expected rm to fail for the missing machine")
+	}
+	if !strings.Contains(output, "Error loading machine missing") {
+		t.Fatalf("expected the missing machine to be reported; received %s", output)
+	}
+	if _, err := os.Stat(filepath.Join(m.dir, ".docker", "machines", "foo")); !os.IsNotExist(err) {
+		t.Fatalf("expected foo to be removed anyway; received %s", output)
+	}
+}
+
+func TestFakeEvents(t *testing.T) {
+	m := newFakeMachine(t)
+	defer m.close()
+
+	if output, err := m.create("foo"); err != nil {
+		t.Fatalf("create failed: %s\n%s", err, output)
+	}
+	m.mustRun("stop", "foo")
+	if _, err := m.run("stop", "foo"); err == nil {
+		t.Fatal("expected stopping a stopped host to fail")
+	}
+
+	output := m.mustRun("events", "--format", "{{.Machine}} {{.Action}} {{.Result}}", "foo")
+	expected := "foo create success\nfoo stop success\nfoo stop error\n"
+	if output != expected {
+		t.Fatalf("expected the events of foo %q; received %q", expected, output)
+	}
+
+	output = m.mustRun("events", "--format", "json", "--since", "1h")
+	if !strings.Contains(output, `"Action":"stop","Result":"error","Error":`) {
+		t.Fatalf("expected the error of the second stop; received %s", output)
+	}
+	if output := m.mustRun("events", "--since", "2000-01-01T00:00:00Z", "bar"); strings.Contains(output, "foo") {
+		t.Fatalf("expected no events of other machines; received %s", output)
+	}
+}
+
+func TestFakeStatus(t *testing.T) {
+	m := newFakeMachine(t)
+	defer m.close()
+
+	if output, err := m.create("foo"); err != nil {
+		t.Fatalf("create failed: %s\n%s", err, output)
+	}
+
+	output := m.mustRun("status", "--format", "{{.State}} {{.ProviderState}} {{.DockerReachable}}", "foo")
+	if output != "Running running true\n" {
+		t.Fatalf("expected foo to be running with Docker reachable; received %q", output)
+	}
+
+	m.docker.Close()
+	output = m.mustRun("status", "foo")
+	if !strings.Contains(output, "Down, although the machine is running") {
+		t.Fatalf("expected Docker to be reported down; received %s", output)
+	}
+
+	m.mustRun("stop", "foo")
+	output = m.mustRun("status", "--format", "json", "foo")
+	if !strings.Contains(output, `"State": "Stopped"`) || !strings.Contains(output, `"DockerReachable": false`) {
+		t.Fatalf("expected foo to be stopped; received %s", output)
+	}
+}
+
+func TestFakeWait(t *testing.T) {
+	m := newFakeMachine(t)
+	defer m.close()
+
+	if output, err := m.create("foo"); err != nil {
+		t.Fatalf("create failed: %s\n%s", err, output)
+	}
+	m.mustRun("wait", "--docker", "foo")
+
+	m.mustRun("stop", "foo")
+	m.mustRun("wait", "--state", "stopped", "foo")
+	output, err := m.run("wait", "--timeout", "1s", "foo")
+	if err == nil {
+		t.Fatal("expected waiting for a stopped host to be running to time out")
+	}
+	if !strings.Contains(output, "foo is Stopped") {
+		t.Fatalf("expected the state of foo in the error; received %s", output)
+	}
+
+	m.mustRun("start", "foo")
+	m.docker.Close()
+	if _, err := m.run("wait", "--docker", "--timeout", "1s", "foo"); err == nil {
+		t.Fatal("expected waiting for Docker to time out once it is down")
+	}
+}
+
+func TestFakeOperationLogs(t *testing.T) {
+	m := newFakeMachine(t)
+	defer m.close()
+
+	output, err := m.create("foo", "--fake-password", "s3cret")
+	if err != nil {
+		t.Fatalf("create failed: %s\n%s", err, output)
+	}
+	if strings.Contains(output, "Creating fake host") {
+		t.Fatalf("expected no debug output without --debug; received %s", output)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(m.dir, ".docker", "machines", "foo", "create.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	createLog := string(data)
+	if !strings.Contains(createLog, "Creating fake host") || !strings.Contains(createLog, "cat /etc/os-release") {
+		t.Fatalf("expected the debug log and the SSH commands in create.log; received %s", createLog)
+	}
+	if strings.Contains(createLog, "s3cret") || !strings.Contains(createLog, "[REDACTED]") {
+		t.Fatalf("expected the password to be redacted; received %s", createLog)
+	}
+	if strings.Contains(createLog, "BEGIN RSA PRIVATE KEY") {
+		t.Fatalf("expected the server key to be redacted; received %s", createLog)
+	}
+
+	output = m.mustRun("--log-format", "json", "provision", "foo")
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if !strings.HasPrefix(line, "{") {
+			t.Fatalf("expected JSON log entries; received %s", output)
+		}
+	}
+	data, err = ioutil.ReadFile(filepath.Join(m.dir, ".docker", "machines", "foo", "provision.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"command":"cat /etc/os-release"`) {
+		t.Fatalf("expected the SSH commands in provision.log as JSON; received %s", data)
+	}
+}
+
+func TestFakeParallelOperationLogs(t *testing.T) {
+	m := newFakeMachine(t)
+	defer m.close()
+
+	path := filepath.Join(m.dir, "machines.json")
+	options := fmt.Sprintf(`"driver": "fake", "options": {"fake-ssh-port": %d, "fake-docker-port": %d}`, m.ssh.Port, m.docker.Port)
+	contents := fmt.Sprintf(`{"machines": [{"name": "foo", %s}, {"name": "bar", %s}]}`, options, options)
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	m.mustRun("create", "--file", path, "--parallel", "2")
+
+	for name, other := range map[string]string{"foo": "bar", "bar": "foo"} {
+		data, err := ioutil.ReadFile(filepath.Join(m.dir, ".docker", "machines", name, "create.log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		createLog := string(data)
+		if strings.Contains(createLog, fmt.Sprintf("machine=%q", other)) {
+			t.Fatalf("expected only the entries of %s in its create.log; received %s", name, createLog)
+		}
+		for _, line := range strings.Split(createLog, "\n") {
+			if strings.Contains(line, "SSH command") && !strings.Contains(line, fmt.Sprintf("machine=%q", name)) {
+				t.Fatalf("expected the SSH commands of %s to be tagged with it; received %s", name, line)
+			}
+		}
+		if !strings.Contains(createLog, "Creating fake host") || !strings.Contains(createLog, "cat /etc/os-release") {
+			t.Fatalf("expected the debug log of %s in its create.log; received %s", name, createLog)
+		}
+	}
+}
+
+func TestFakeAdopt(t *testing.T) {
+	m := newFakeMachine(t)
+	defer m.close()
+
+	args := []string{
+		"adopt", "-d", "fake",
+		"--fake-ssh-port", fmt.Sprint(m.ssh.Port),
+		"--fake-docker-port", fmt.Sprint(m.docker.Port),
+	}
+	hostPath := filepath.Join(m.dir, ".docker", "machines", "foo")
+
+	if _, err := m.run(append(args, "foo")...); err == nil {
+		t.Fatal("expected adopt to fail without --instance-id")
+	}
+	if _, err := os.Stat(hostPath); !os.IsNotExist(err) {
+		t.Fatalf("expected no machine directory after invalid flags; received %v", err)
+	}
+
+	if _, err := m.run(append(args, "--instance-id", "i-1", "--fake-fail-on", fakedriver.OpAdopt, "foo")...); err == nil {
+		t.Fatal("expected adopt to fail")
+	}
+	if _, err := os.Stat(hostPath); !os.IsNotExist(err) {
+		t.Fatalf("expected the machine directory to be removed after adopt failed; received %v", err)
+	}
+
+	start := time.Now()
+	if _, err := m.run(append(args, "--instance-id", "i-1", "--fake-latency", "5000", "--timeout", "200ms", "foo")...); err == nil {
+		t.Fatal("expected adopt to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Fatalf("expected adopt to stop waiting at the timeout; took %s", elapsed)
+	}
+	if _, err := os.Stat(hostPath); !os.IsNotExist(err) {
+		t.Fatalf("expected the machine directory to be removed after adopt timed out; received %v", err)
+	}
+
+	m.mustRun(append(args, "--instance-id", "i-1", "foo")...)
+	if output := m.mustRun("ls"); !strings.Contains(output, "Running") {
+		t.Fatalf("expected foo to be adopted and running; received %s", output)
+	}
+	if calls := m.calls("foo"); len(calls) == 0 || calls[0] != fakedriver.OpAdopt {
+		t.Fatalf("expected the host to be adopted; received %v", calls)
+	}
+}
+
+func TestFakeCreateFailures(t *testing.T) {
+	m := newFakeMachine(t)
+	defer m.close()
+
+	if _, err := m.create("infra", "--fake-fail-on", fakedriver.OpCreate); err == nil {
+		t.Fatal("expected create to fail")
+	}
+	host := m.load("infra")
+	if !host.CreateIncomplete || host.CreateStep != "" {
+		t.Fatalf("expected create to stop before the first step; received %s", host.CreateStep)
+	}
+	if calls := m.calls("infra"); len(calls) != 1 || calls[0] != fakedriver.OpCreate {
+		t.Fatalf("expected only create to be called; received %v", calls)
+	}
+	if output := m.mustRun("ls"); !strings.Contains(output, "Incomplete") {
+		t.Fatalf("expected infra to be incomplete; received %s", output)
+	}
+
+	m.ssh.Handle("docker start", "", 1)
+	if _, err := m.create("docker"); err == nil {
+		t.Fatal("expected create to fail")
+	}
+	if host := m.load("docker"); host.CreateStep != createStepDocker {
+		t.Fatalf("expected create to stop after step %s; received %s", createStepDocker, host.CreateStep)
+	}
+}
+
+func TestFakeCreateTimeout(t *testing.T) {
+	m := newFakeMachine(t)
+	defer m.close()
+
+	output, err := m.create("foo", "--fake-latency", "500", "--timeout", "200ms")
+	if err == nil {
+		t.Fatal("expected create to time out")
+	}
+	if !strings.Contains(output, "Creating foo was interrupted, removing it") {
+		t.Fatalf("expected foo to be removed; received %s", output)
+	}
+	if _, err := os.Stat(filepath.Join(m.dir, ".docker", "machines", "foo")); !os.IsNotExist(err) {
+		t.Fatal("expected foo to be removed")
+	}
+}
+
+func TestFakeLsErrors(t *testing.T) {
+	m := newFakeMachine(t)
+	defer m.close()
+
+	if output, err := m.create("foo", "--fake-fail-on", fakedriver.OpGetState); err != nil {
+		t.Fatalf("create failed: %s\n%s", err, output)
+	}
+
+	output := m.mustRun("ls")
+	if !strings.Contains(output, "Error") {
+		t.Fatalf("expected the state of foo to be an error; received %s", output)
+	}
+	if calls := m.calls("foo"); len(calls) != 1 || calls[0] != fakedriver.OpCreate {
+		t.Fatalf("expected create to be recorded; received %v", calls)
+	}
+}
+
+func TestFakeCapabilities(t *testing.T) {
+	m := newFakeMachine(t)
+	defer m.close()
+
+	if output, err := m.create("foo"); err != nil {
+		t.Fatalf("create failed: %s\n%s", err, output)
+	}
+
+	output := m.mustRun("inspect", "foo")
+	if !strings.Contains(output, `"Capabilities": [`) || !strings.Contains(output, `"pause"`) {
+		t.Fatalf("expected inspect to list pause; received %s", output)
+	}
+	if strings.Contains(output, `"resize"`) {
+		t.Fatalf("expected inspect not to list resize; received %s", output)
+	}
+
+	m.mustRun("pause", "foo")
+	if output := m.mustRun("ls"); !strings.Contains(output, "Paused") {
+		t.Fatalf("expected foo to be paused; received %s", output)
+	}
+	m.mustRun("unpause", "foo")
+	if st, _ := m.load("foo").Driver.GetState(); st != state.Running {
+		t.Fatalf("expected state Running; received %s", st)
+	}
+
+	output, err := m.run("resize", "--memory", "2048", "foo")
+	if err == nil {
+		t.Fatal("expected resize to fail")
+	}
+	if !strings.Contains(output, "The fake driver does not support resize") {
+		t.Fatalf("expected resize to be reported as not supported; received %s", output)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/docker/machine/drivers"
+	"github.com/docker/machine/utils"
+)
+
+// printPlan prints the resolved configuration of a host checked with
+// "machine create --dry-run" and the resources its driver would create or
+// use. Resources are nil for drivers which cannot plan on the provider.
+// The secrets in the configuration, such as passwords, are redacted.
+func printPlan(w io.Writer, host *Host, resources []drivers.PlannedResource) error {
+	config, err := redactedJSON(host)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Configuration of %s:\n%s\n\n", host.Name, config)
+
+	if resources == nil {
+		fmt.Fprintf(w, "The %s driver cannot check the configuration against the provider, only local checks were run.\n", host.DriverName)
+		return nil
+	}
+	if len(resources) == 0 {
+		fmt.Fprintln(w, "No resources would be created.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 5, 1, 3, ' ', 0)
+	fmt.Fprintln(tw, "ACTION\tTYPE\tNAME\tDETAIL")
+	for _, r := range resources {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Action, r.Type, r.Name, r.Detail)
+	}
+	return tw.Flush()
+}
+
+// redactedJSON returns v as indented JSON with the registered secrets
+// redacted from its strings. They are redacted before being encoded again,
+// as JSON may escape some of their characters.
+func redactedJSON(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(redactValue(value), "", "    ")
+}
+
+func redactValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case string:
+		return utils.Redact(value)
+	case map[string]interface{}:
+		for k, v := range value {
+			value[k] = redactValue(v)
+		}
+	case []interface{}:
+		for i, v := range value {
+			value[i] = redactValue(v)
+		}
+	}
+	return value
+}
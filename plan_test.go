@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers"
+	"github.com/docker/machine/utils"
+)
+
+func TestPrintPlan(t *testing.T) {
+	host, err := NewHost("test", "none", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host.Labels = map[string]string{"token": "plan&s3cret"}
+	utils.RegisterSecret("plan&s3cret")
+
+	var out bytes.Buffer
+	if err := printPlan(&out, host, []drivers.PlannedResource{
+		{Action: "create", Type: "key pair", Name: "test"},
+		{Action: "use", Type: "subnet", Name: "subnet-1234", Detail: "in us-east-1a"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if !strings.Contains(out.String(), `"DriverName": "none"`) {
+		t.Fatalf("expected the configuration of the host; received %q", out.String())
+	}
+	if strings.Contains(out.String(), "s3cret") || !strings.Contains(out.String(), `"token": "[REDACTED]"`) {
+		t.Fatalf("expected the secrets to be redacted; received %q", out.String())
+	}
+	last := lines[len(lines)-1]
+	if !strings.HasPrefix(last, "use") || !strings.HasSuffix(last, "in us-east-1a") {
+		t.Fatalf("unexpected line for the subnet: %q", last)
+	}
+
+	out.Reset()
+	if err := printPlan(&out, host, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "only local checks were run") {
+		t.Fatalf("expected a note for a driver which cannot plan; received %q", out.String())
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/docker/machine/drivers"
+	"github.com/docker/machine/utils"
+)
+
+// Profile is a named set of "machine create" options saved with "machine
+// profile save" and used with "machine create --profile".
+type Profile struct {
+	Name    string `json:"-"`
+	Driver  string
+	Options map[string]interface{}
+}
+
+// createOptionFlags returns the flags of "machine create" which can be
+// kept in a profile.
+func createOptionFlags() []cli.Flag {
+	return append(drivers.GetCreateFlags(), machineCreateFlags...)
+}
+
+// flagNameAndEnv returns the name of a flag and the environment variables
+// it is read from.
+func flagNameAndEnv(f cli.Flag) (string, []string) {
+	var name, envVar string
+
+	switch f := f.(type) {
+	case cli.StringFlag:
+		name, envVar = f.Name, f.EnvVar
+	case cli.IntFlag:
+		name, envVar = f.Name, f.EnvVar
+	case cli.BoolFlag:
+		name, envVar = f.Name, f.EnvVar
+	case cli.BoolTFlag:
+		name, envVar = f.Name, f.EnvVar
+	case cli.StringSliceFlag:
+		name, envVar = f.Name, f.EnvVar
+	}
+
+	envVars := []string{}
+	for _, e := range strings.Split(envVar, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			envVars = append(envVars, e)
+		}
+	}
+
+	return strings.TrimSpace(strings.Split(name, ",")[0]), envVars
+}
+
+// NewProfile creates a profile from the options given explicitly on the
+// command line. Options left at their default are not saved, so they keep
+// following the defaults and the environment.
+func NewProfile(name string, c *cli.Context) (*Profile, error) {
+	if _, err := ValidateHostName(name); err != nil {
+		return nil, fmt.Errorf("Invalid profile name %q", name)
+	}
+
+	profile := &Profile{
+		Name:    name,
+		Options: map[string]interface{}{},
+	}
+
+	if c.IsSet("driver") {
+		profile.Driver = c.String("driver")
+	}
+
+	for _, f := range createOptionFlags() {
+		key, _ := flagNameAndEnv(f)
+		if !c.IsSet(key) {
+			continue
+		}
+
+		switch f.(type) {
+		case cli.StringFlag:
+			value := c.String(key)
+			if isMachineFilePathOption(key) {
+				abs, err := filepath.Abs(value)
+				if err != nil {
+					return nil, err
+				}
+				value = abs
+			}
+			profile.Options[key] = value
+		case cli.IntFlag:
+			profile.Options[key] = c.Int(key)
+		case cli.BoolFlag, cli.BoolTFlag:
+			profile.Options[key] = c.Bool(key)
+		case cli.StringSliceFlag:
+			values := c.StringSlice(key)
+			if isMachineFilePathOption(key) {
+				for i, v := range values {
+					abs, err := filepath.Abs(v)
+					if err != nil {
+						return nil, err
+					}
+					values[i] = abs
+				}
+			}
+			profile.Options[key] = values
+		}
+	}
+
+	return profile, nil
+}
+
+func profilePath(name string) string {
+	return filepath.Join(utils.GetMachineProfileDir(), name+".json")
+}
+
+// SaveProfile writes the profile to the profile directory, replacing a
+// profile with the same name.
+func SaveProfile(profile *Profile) error {
+	data, err := json.MarshalIndent(profile, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(utils.GetMachineProfileDir(), 0700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(profilePath(profile.Name), data, 0600)
+}
+
+// LoadProfile reads the profile with the given name.
+func LoadProfile(name string) (*Profile, error) {
+	data, err := ioutil.ReadFile(profilePath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("Profile %s does not exist", name)
+		}
+		return nil, err
+	}
+
+	profile := &Profile{Name: name}
+	if err := json.Unmarshal(data, profile); err != nil {
+		return nil, fmt.Errorf("Error reading profile %s: %s", name, err)
+	}
+
+	return profile, nil
+}
+
+// RemoveProfile removes the profile with the given name.
+func RemoveProfile(name string) error {
+	if err := os.Remove(profilePath(name)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("Profile %s does not exist", name)
+		}
+		return err
+	}
+	return nil
+}
+
+// ListProfiles returns the names of the saved profiles.
+func ListProfiles() ([]string, error) {
+	files, err := ioutil.ReadDir(utils.GetMachineProfileDir())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, f := range files {
+		if !f.IsDir() && filepath.Ext(f.Name()) == ".json" {
+			names = append(names, strings.TrimSuffix(f.Name(), ".json"))
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// profileOptions resolves the options of "machine create --profile". An
+// option given on the command line wins over its environment variable,
+// which wins over the profile, which wins over the default of the flag.
+type profileOptions struct {
+	flags   drivers.DriverOptions
+	isSet   func(key string) bool
+	envVars map[string][]string
+	profile *Profile
+}
+
+func newProfileOptions(c *cli.Context, profile *Profile) *profileOptions {
+	envVars := map[string][]string{}
+	for _, f := range createOptionFlags() {
+		key, vars := flagNameAndEnv(f)
+		envVars[key] = vars
+	}
+
+	return &profileOptions{
+		flags:   c,
+		isSet:   c.IsSet,
+		envVars: envVars,
+		profile: profile,
+	}
+}
+
+// profileValue returns the value of the option from the profile, unless it
+// is given on the command line or in the environment.
+func (o *profileOptions) profileValue(key string) (interface{}, bool) {
+	if o.isSet(key) {
+		return nil, false
+	}
+	for _, env := range o.envVars[key] {
+		if os.Getenv(env) != "" {
+			return nil, false
+		}
+	}
+
+	v, ok := o.profile.Options[key]
+	return v, ok
+}
+
+func (o *profileOptions) String(key string) string {
+	if v, ok := o.profileValue(key); ok {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return o.flags.String(key)
+}
+
+func (o *profileOptions) Int(key string) int {
+	if v, ok := o.profileValue(key); ok {
+		// numbers are read back from JSON as float64
+		if f, ok := v.(float64); ok {
+			return int(f)
+		}
+	}
+	return o.flags.Int(key)
+}
+
+func (o *profileOptions) Bool(key string) bool {
+	if v, ok := o.profileValue(key); ok {
+		if b, ok := v.(bool); ok {
+			return b
+		}
+	}
+	return o.flags.Bool(key)
+}
+
+func (o *profileOptions) StringSlice(key string) []string {
+	if v, ok := o.profileValue(key); ok {
+		if items, ok := v.([]interface{}); ok {
+			values := []string{}
+			for _, item := range items {
+				if s, ok := item.(string); ok {
+					values = append(values, s)
+				}
+			}
+			return values
+		}
+	}
+	return o.flags.StringSlice(key)
+}
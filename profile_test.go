@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/codegangsta/cli"
+)
+
+// newCreateContext parses args with the flags of "machine create".
+func newCreateContext(t *testing.T, args ...string) *cli.Context {
+	set := newFlagSet("create", append(createOptionFlags(), cli.StringFlag{
+		Name:  "driver, d",
+		Value: "none",
+	}))
+	if err := set.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return cli.NewContext(nil, set, nil)
+}
+
+func TestNewProfile(t *testing.T) {
+	c := newCreateContext(t, "--driver", "virtualbox", "--virtualbox-memory", "2048", "--label", "team=web", "dev")
+
+	profile, err := NewProfile("vbox", c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if profile.Driver != "virtualbox" {
+		t.Fatalf("expected driver virtualbox; received %s", profile.Driver)
+	}
+	if len(profile.Options) != 2 {
+		t.Fatalf("expected only the given options; received %v", profile.Options)
+	}
+	if profile.Options["virtualbox-memory"] != 2048 {
+		t.Fatalf("expected memory 2048; received %v", profile.Options["virtualbox-memory"])
+	}
+
+	if _, err := NewProfile("not valid", c); err == nil {
+		t.Fatal("expected error for an invalid profile name")
+	}
+}
+
+func TestProfileOptions(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	os.Setenv("MACHINE_DIR", tmpDir)
+	defer os.Setenv("MACHINE_DIR", "")
+
+	saved, err := NewProfile("vbox", newCreateContext(t,
+		"--driver", "virtualbox",
+		"--virtualbox-memory", "2048",
+		"--virtualbox-disk-size", "30000",
+		"--virtualbox-boot2docker-url", "http://profile/b2d.iso",
+		"--label", "team=web",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveProfile(saved); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := ListProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "vbox" {
+		t.Fatalf("expected profile vbox; received %v", names)
+	}
+
+	profile, err := LoadProfile("vbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("VIRTUALBOX_BOOT2DOCKER_URL", "http://env/b2d.iso")
+	defer os.Setenv("VIRTUALBOX_BOOT2DOCKER_URL", "")
+
+	opts := newProfileOptions(newCreateContext(t, "--virtualbox-disk-size", "40000", "dev"), profile)
+
+	if opts.Int("virtualbox-memory") != 2048 {
+		t.Fatalf("expected memory from the profile; received %d", opts.Int("virtualbox-memory"))
+	}
+	if opts.Int("virtualbox-disk-size") != 40000 {
+		t.Fatalf("expected disk size from the command line; received %d", opts.Int("virtualbox-disk-size"))
+	}
+	if url := opts.String("virtualbox-boot2docker-url"); url != "http://env/b2d.iso" {
+		t.Fatalf("expected boot2docker URL from the environment; received %s", url)
+	}
+	if labels := opts.StringSlice("label"); len(labels) != 1 || labels[0] != "team=web" {
+		t.Fatalf("expected label from the profile; received %v", labels)
+	}
+	if driver := opts.String("engine-storage-driver"); driver != "" {
+		t.Fatalf("expected the default storage driver; received %s", driver)
+	}
+
+	if err := RemoveProfile("vbox"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadProfile("vbox"); err == nil {
+		t.Fatal("expected error for a removed profile")
+	}
+}
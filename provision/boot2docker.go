@@ -0,0 +1,81 @@
+package provision
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/docker/machine/drivers"
+)
+
+func init() {
+	Register("boot2docker", &RegisteredProvisioner{
+		New: NewBoot2DockerProvisioner,
+	})
+}
+
+// Boot2DockerProvisioner provisions boot2docker hosts. Docker is part of the
+// boot2docker image so it is upgraded by replacing the image.
+type Boot2DockerProvisioner struct {
+	Driver drivers.Driver
+}
+
+func NewBoot2DockerProvisioner(d drivers.Driver) Provisioner {
+	return &Boot2DockerProvisioner{Driver: d}
+}
+
+func (p *Boot2DockerProvisioner) CompatibleWithHost(osRelease *OsRelease) bool {
+	return osRelease.Id == "boot2docker"
+}
+
+func (p *Boot2DockerProvisioner) SetServiceManager(m ServiceManager) {
+}
+
+func (p *Boot2DockerProvisioner) InstallDocker() error {
+	return nil
+}
+
+func (p *Boot2DockerProvisioner) UpgradeDocker() error {
+	upgrader, ok := p.Driver.(drivers.Upgrader)
+	if !ok || !drivers.Supports(p.Driver, drivers.CapabilityUpgrade) {
+		return drivers.NotSupported(p.Driver, drivers.CapabilityUpgrade)
+	}
+	return upgrader.Upgrade()
+}
+
+func (p *Boot2DockerProvisioner) StartDocker() error {
+	drivers.Logger(p.Driver).Debug("Starting Docker...")
+	return runSSHCommand(p.Driver, Boot2DockerInit.startCommand())
+}
+
+func (p *Boot2DockerProvisioner) StopDocker() error {
+	drivers.Logger(p.Driver).Debug("Stopping Docker...")
+	return runSSHCommand(p.Driver, Boot2DockerInit.stopCommand())
+}
+
+func (p *Boot2DockerProvisioner) GetDockerConfigDir() string {
+	return "/var/lib/boot2docker"
+}
+
+// GenerateDockerConfig writes the daemon options to the boot2docker profile,
+// which is sourced by its init script. TLS is set up by machine, so the
+// boot2docker TLS setup is disabled.
+func (p *Boot2DockerProvisioner) GenerateDockerConfig(opts DockerOptions) *DockerConfig {
+	args := append(opts.tlsArgs(), opts.Args...)
+	daemonOpts := fmt.Sprintf("%s -H tcp://0.0.0.0:%d", joinArgs(args), opts.Port)
+
+	cfg := fmt.Sprintf(`EXTRA_ARGS='%s'
+CACERT=%s
+SERVERCERT=%s
+SERVERKEY=%s
+DOCKER_TLS=no
+`, daemonOpts, opts.CaCertPath, opts.ServerCertPath, opts.ServerKeyPath)
+
+	for _, env := range opts.Env {
+		cfg += fmt.Sprintf("export %s\n", env)
+	}
+
+	return &DockerConfig{
+		EngineConfig:     cfg,
+		EngineConfigPath: path.Join(p.GetDockerConfigDir(), "profile"),
+	}
+}
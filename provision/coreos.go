@@ -0,0 +1,71 @@
+package provision
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/drivers"
+)
+
+func init() {
+	Register("coreos", &RegisteredProvisioner{
+		New: NewCoreOSProvisioner,
+	})
+}
+
+// CoreOSProvisioner provisions CoreOS hosts. Docker ships with CoreOS and is
+// updated together with the OS.
+type CoreOSProvisioner struct {
+	Driver drivers.Driver
+}
+
+func NewCoreOSProvisioner(d drivers.Driver) Provisioner {
+	return &CoreOSProvisioner{Driver: d}
+}
+
+func (p *CoreOSProvisioner) CompatibleWithHost(osRelease *OsRelease) bool {
+	return osRelease.Id == "coreos"
+}
+
+func (p *CoreOSProvisioner) SetServiceManager(m ServiceManager) {
+}
+
+func (p *CoreOSProvisioner) InstallDocker() error {
+	return nil
+}
+
+func (p *CoreOSProvisioner) UpgradeDocker() error {
+	return fmt.Errorf("Docker on CoreOS is upgraded together with the OS")
+}
+
+func (p *CoreOSProvisioner) StartDocker() error {
+	drivers.Logger(p.Driver).Debug("Starting Docker...")
+	return runSSHCommand(p.Driver, Systemd.startCommand())
+}
+
+func (p *CoreOSProvisioner) StopDocker() error {
+	drivers.Logger(p.Driver).Debug("Stopping Docker...")
+	return runSSHCommand(p.Driver, Systemd.stopCommand())
+}
+
+func (p *CoreOSProvisioner) GetDockerConfigDir() string {
+	return "/etc/docker"
+}
+
+// GenerateDockerConfig writes the daemon options as a systemd drop-in for
+// the docker service, which passes $DOCKER_OPTS to the daemon.
+func (p *CoreOSProvisioner) GenerateDockerConfig(opts DockerOptions) *DockerConfig {
+	args := append(opts.tlsArgs(), opts.Args...)
+	args = append(args, fmt.Sprintf("--host=tcp://0.0.0.0:%d", opts.Port))
+
+	cfg := "[Service]\n"
+	for _, env := range opts.Env {
+		cfg += fmt.Sprintf("Environment=%s\n", env)
+	}
+	cfg += fmt.Sprintf("Environment='DOCKER_OPTS=%s'\n", strings.Join(args, " "))
+
+	return &DockerConfig{
+		EngineConfig:     cfg,
+		EngineConfigPath: systemdDropInPath,
+	}
+}
@@ -0,0 +1,80 @@
+package provision
+
+import (
+	"fmt"
+
+	"github.com/docker/machine/drivers"
+)
+
+func init() {
+	Register("debian", &RegisteredProvisioner{
+		New: NewDebianProvisioner,
+	})
+}
+
+// DebianProvisioner provisions Debian and Ubuntu hosts.
+type DebianProvisioner struct {
+	Driver         drivers.Driver
+	ServiceManager ServiceManager
+}
+
+func NewDebianProvisioner(d drivers.Driver) Provisioner {
+	return &DebianProvisioner{Driver: d}
+}
+
+func (p *DebianProvisioner) CompatibleWithHost(osRelease *OsRelease) bool {
+	return osRelease.Is("debian", "ubuntu")
+}
+
+func (p *DebianProvisioner) SetServiceManager(m ServiceManager) {
+	p.ServiceManager = m
+}
+
+func (p *DebianProvisioner) InstallDocker() error {
+	drivers.Logger(p.Driver).Debug("Installing Docker...")
+	return runSSHCommand(p.Driver, "if ! type docker >/dev/null 2>&1; then "+
+		"(type curl >/dev/null 2>&1 || (sudo apt-get update && sudo apt-get install -y curl)) && "+
+		"curl -sSL https://get.docker.com | sudo sh -; fi")
+}
+
+func (p *DebianProvisioner) UpgradeDocker() error {
+	drivers.Logger(p.Driver).Debug("Upgrading Docker...")
+	return runSSHCommand(p.Driver, "sudo apt-get update && sudo apt-get install -y --upgrade lxc-docker")
+}
+
+func (p *DebianProvisioner) StartDocker() error {
+	drivers.Logger(p.Driver).Debug("Starting Docker...")
+	return runSSHCommand(p.Driver, p.ServiceManager.startCommand())
+}
+
+func (p *DebianProvisioner) StopDocker() error {
+	drivers.Logger(p.Driver).Debug("Stopping Docker...")
+	return runSSHCommand(p.Driver, p.ServiceManager.stopCommand())
+}
+
+func (p *DebianProvisioner) GetDockerConfigDir() string {
+	return "/etc/docker"
+}
+
+// GenerateDockerConfig writes the daemon options to /etc/default/docker,
+// which is sourced by the upstart and sysvinit scripts of the Docker
+// packages, or to a drop-in for the docker unit under systemd.
+func (p *DebianProvisioner) GenerateDockerConfig(opts DockerOptions) *DockerConfig {
+	if p.ServiceManager == Systemd {
+		return systemdDropIn(opts)
+	}
+
+	args := append(opts.tlsArgs(), opts.Args...)
+	daemonOpts := fmt.Sprintf("%s --host=unix:///var/run/docker.sock --host=tcp://0.0.0.0:%d", joinArgs(args), opts.Port)
+
+	cfg := ""
+	for _, env := range opts.Env {
+		cfg += fmt.Sprintf("export %s\n", env)
+	}
+	cfg += fmt.Sprintf("export DOCKER_OPTS='%s'\n", daemonOpts)
+
+	return &DockerConfig{
+		EngineConfig:     cfg,
+		EngineConfigPath: "/etc/default/docker",
+	}
+}
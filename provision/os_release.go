@@ -0,0 +1,63 @@
+package provision
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+)
+
+// OsRelease holds the fields of /etc/os-release which are used to pick a
+// provisioner. See os-release(5).
+type OsRelease struct {
+	Id        string
+	IdLike    []string
+	Name      string
+	VersionId string
+}
+
+// NewOsRelease parses the contents of /etc/os-release.
+func NewOsRelease(contents []byte) *OsRelease {
+	osRelease := &OsRelease{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value := strings.Trim(kv[1], `"'`)
+
+		switch kv[0] {
+		case "ID":
+			osRelease.Id = value
+		case "ID_LIKE":
+			osRelease.IdLike = strings.Fields(value)
+		case "NAME":
+			osRelease.Name = value
+		case "VERSION_ID":
+			osRelease.VersionId = value
+		}
+	}
+
+	return osRelease
+}
+
+// Is returns whether the OS is one of ids or is derived from one of them.
+func (o *OsRelease) Is(ids ...string) bool {
+	for _, id := range ids {
+		if o.Id == id {
+			return true
+		}
+		for _, like := range o.IdLike {
+			if like == id {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package provision
+
+import "testing"
+
+func TestNewOsRelease(t *testing.T) {
+	osRelease := NewOsRelease([]byte(`NAME="Ubuntu"
+VERSION="14.04.2 LTS, Trusty Tahr"
+ID=ubuntu
+ID_LIKE=debian
+# comment
+VERSION_ID="14.04"
+`))
+
+	if osRelease.Id != "ubuntu" {
+		t.Fatalf("expected ID ubuntu; received %s", osRelease.Id)
+	}
+	if osRelease.Name != "Ubuntu" {
+		t.Fatalf("expected NAME Ubuntu; received %s", osRelease.Name)
+	}
+	if osRelease.VersionId != "14.04" {
+		t.Fatalf("expected VERSION_ID 14.04; received %s", osRelease.VersionId)
+	}
+	if !osRelease.Is("debian") {
+		t.Fatal("expected ubuntu to be like debian")
+	}
+	if osRelease.Is("fedora") {
+		t.Fatal("expected ubuntu not to be like fedora")
+	}
+}
+
+func TestProvisionerCompatibility(t *testing.T) {
+	expected := map[string]string{
+		"ID=boot2docker":                         "boot2docker",
+		"ID=ubuntu\nID_LIKE=debian":              "debian",
+		"ID=debian":                              "debian",
+		"ID=\"centos\"\nID_LIKE=\"rhel fedora\"": "redhat",
+		"ID=fedora":                              "redhat",
+		"ID=coreos":                              "coreos",
+	}
+
+	for contents, name := range expected {
+		osRelease := NewOsRelease([]byte(contents))
+
+		compatible := []string{}
+		for n, p := range provisioners {
+			if p.New(nil).CompatibleWithHost(osRelease) {
+				compatible = append(compatible, n)
+			}
+		}
+
+		if len(compatible) != 1 || compatible[0] != name {
+			t.Fatalf("expected only %s to be compatible with %q; received %v", name, contents, compatible)
+		}
+	}
+}
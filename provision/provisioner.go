@@ -0,0 +1,157 @@
+package provision
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/machine/drivers"
+)
+
+// Provisioner knows how to install, configure and control Docker on a
+// particular guest OS. Drivers only create the infrastructure; everything
+// that happens on the host afterwards goes through a provisioner.
+type Provisioner interface {
+	// CompatibleWithHost returns whether the provisioner can handle the OS
+	// described by osRelease
+	CompatibleWithHost(osRelease *OsRelease) bool
+
+	// SetServiceManager sets the init system detected on the host.
+	// Provisioners for OSes which always use the same one ignore it.
+	SetServiceManager(m ServiceManager)
+
+	// InstallDocker installs Docker on the host if it is not installed yet
+	InstallDocker() error
+
+	// UpgradeDocker upgrades Docker on the host to the latest version
+	UpgradeDocker() error
+
+	// StartDocker starts the Docker daemon on the host
+	StartDocker() error
+
+	// StopDocker stops the Docker daemon on the host
+	StopDocker() error
+
+	// GetDockerConfigDir returns the directory the TLS certificates for the
+	// daemon are stored in on the host
+	GetDockerConfigDir() string
+
+	// GenerateDockerConfig returns the daemon configuration for opts and the
+	// path it must be written to on the host
+	GenerateDockerConfig(opts DockerOptions) *DockerConfig
+}
+
+// RegisteredProvisioner is used to register a provisioner with the Register
+// function. New returns a provisioner which runs its commands on the host
+// of the given driver.
+type RegisteredProvisioner struct {
+	New func(d drivers.Driver) Provisioner
+}
+
+// DockerOptions are the settings the Docker daemon is configured with.
+type DockerOptions struct {
+	Port           int
+	CaCertPath     string
+	ServerCertPath string
+	ServerKeyPath  string
+
+	// Args are extra daemon arguments, e.g. labels and engine options
+	Args []string
+
+	// Env are the environment variables of the daemon as KEY=value
+	Env []string
+}
+
+// DockerConfig is the daemon configuration file for a host.
+type DockerConfig struct {
+	EngineConfig     string
+	EngineConfigPath string
+}
+
+var (
+	ErrDetectionFailed = errors.New("unable to detect the OS of the host")
+)
+
+// provisioners is initialized in its declaration as the provisioners of
+// this package register themselves from init functions.
+var (
+	provisioners = make(map[string]*RegisteredProvisioner)
+)
+
+// Register a provisioner
+func Register(name string, registeredProvisioner *RegisteredProvisioner) error {
+	if _, exists := provisioners[name]; exists {
+		return fmt.Errorf("Name already registered %s", name)
+	}
+
+	provisioners[name] = registeredProvisioner
+	return nil
+}
+
+// GetProvisioner returns the provisioner registered as name for the host of
+// driver d.
+func GetProvisioner(name string, d drivers.Driver) (Provisioner, error) {
+	provisioner, exists := provisioners[name]
+	if !exists {
+		return nil, fmt.Errorf("provision: Unknown provisioner %q", name)
+	}
+	return provisioner.New(d), nil
+}
+
+// DetectProvisioner reads /etc/os-release on the host of driver d and
+// returns the first provisioner compatible with it.
+func DetectProvisioner(d drivers.Driver) (Provisioner, error) {
+	out, err := drivers.RunSSHCommand(d, "cat /etc/os-release")
+	if err != nil {
+		drivers.Logger(d).Debugf("error reading /etc/os-release: %s", err)
+		return nil, ErrDetectionFailed
+	}
+
+	osRelease := NewOsRelease(out)
+
+	names := make([]string, 0, len(provisioners))
+	for name := range provisioners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		provisioner := provisioners[name].New(d)
+		if !provisioner.CompatibleWithHost(osRelease) {
+			continue
+		}
+
+		m, err := DetectServiceManager(d)
+		if err != nil {
+			return nil, err
+		}
+		drivers.Logger(d).Debugf("found compatible provisioner %s for %s using %s", name, osRelease.Id, m)
+
+		provisioner.SetServiceManager(m)
+		return provisioner, nil
+	}
+
+	return nil, fmt.Errorf("no provisioner found for %q (ID=%s)", osRelease.Name, osRelease.Id)
+}
+
+// tlsArgs returns the daemon arguments which enable TLS verification.
+func (o DockerOptions) tlsArgs() []string {
+	return []string{
+		"--tlsverify",
+		fmt.Sprintf("--tlscacert=%s", o.CaCertPath),
+		fmt.Sprintf("--tlskey=%s", o.ServerKeyPath),
+		fmt.Sprintf("--tlscert=%s", o.ServerCertPath),
+	}
+}
+
+// joinArgs joins daemon arguments, one per line.
+func joinArgs(args []string) string {
+	return strings.Join(args, " \\\n")
+}
+
+// runSSHCommand runs command on the host of driver d.
+func runSSHCommand(d drivers.Driver, command string) error {
+	_, err := drivers.RunSSHCommand(d, command)
+	return err
+}
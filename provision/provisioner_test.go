@@ -0,0 +1,107 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+)
+
+var testDockerOptions = DockerOptions{
+	Port:           2376,
+	CaCertPath:     "/test/ca.pem",
+	ServerCertPath: "/test/server.pem",
+	ServerKeyPath:  "/test/server-key.pem",
+	Args:           []string{"--label=team=web"},
+	Env:            []string{"HTTP_PROXY=http://proxy:3128"},
+}
+
+func TestGenerateDockerConfig(t *testing.T) {
+	expected := map[string]struct {
+		path     string
+		contents []string
+	}{
+		"boot2docker": {
+			"/var/lib/boot2docker/profile",
+			[]string{"EXTRA_ARGS='", "-H tcp://0.0.0.0:2376'", "DOCKER_TLS=no", "export HTTP_PROXY=http://proxy:3128"},
+		},
+		"debian": {
+			"/etc/default/docker",
+			[]string{"export DOCKER_OPTS='", "--host=tcp://0.0.0.0:2376'", "export HTTP_PROXY=http://proxy:3128"},
+		},
+		"redhat": {
+			"/etc/sysconfig/docker",
+			[]string{"OPTIONS='", "--host=tcp://0.0.0.0:2376'", "HTTP_PROXY=http://proxy:3128"},
+		},
+		"coreos": {
+			"/etc/systemd/system/docker.service.d/10-machine.conf",
+			[]string{"[Service]", "--host=tcp://0.0.0.0:2376'", "Environment=HTTP_PROXY=http://proxy:3128"},
+		},
+	}
+
+	for name, e := range expected {
+		p, err := GetProvisioner(name, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cfg := p.GenerateDockerConfig(testDockerOptions)
+
+		if cfg.EngineConfigPath != e.path {
+			t.Fatalf("%s: expected engine config path %s; received %s", name, e.path, cfg.EngineConfigPath)
+		}
+
+		contents := append(e.contents,
+			"--tlsverify",
+			"--tlscacert=/test/ca.pem",
+			"--tlscert=/test/server.pem",
+			"--tlskey=/test/server-key.pem",
+			"--label=team=web",
+		)
+		for _, c := range contents {
+			if !strings.Contains(cfg.EngineConfig, c) {
+				t.Fatalf("%s: expected %q in engine config; received %s", name, c, cfg.EngineConfig)
+			}
+		}
+	}
+}
+
+func TestGetProvisionerUnknown(t *testing.T) {
+	if _, err := GetProvisioner("bogus", nil); err == nil {
+		t.Fatal("expected error for unknown provisioner")
+	}
+}
+
+func TestGenerateDockerConfigSystemd(t *testing.T) {
+	for _, name := range []string{"debian", "redhat"} {
+		p, err := GetProvisioner(name, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.SetServiceManager(Systemd)
+
+		cfg := p.GenerateDockerConfig(testDockerOptions)
+
+		if cfg.EngineConfigPath != systemdDropInPath {
+			t.Fatalf("%s: expected drop-in %s; received %s", name, systemdDropInPath, cfg.EngineConfigPath)
+		}
+		for _, c := range []string{"[Service]", "Environment=HTTP_PROXY=http://proxy:3128", "ExecStart=\n", "--tlsverify", "--host=tcp://0.0.0.0:2376"} {
+			if !strings.Contains(cfg.EngineConfig, c) {
+				t.Fatalf("%s: expected %q in drop-in; received %s", name, c, cfg.EngineConfig)
+			}
+		}
+	}
+}
+
+func TestServiceManagerCommands(t *testing.T) {
+	expected := map[ServiceManager]string{
+		Systemd:         "systemctl start docker",
+		Upstart:         "service docker start",
+		SysVInit:        "service docker start",
+		Boot2DockerInit: "/etc/init.d/docker start",
+	}
+
+	for m, cmd := range expected {
+		if !strings.Contains(m.startCommand(), cmd) {
+			t.Fatalf("%s: expected start command to contain %q; received %q", m, cmd, m.startCommand())
+		}
+	}
+}
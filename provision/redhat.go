@@ -0,0 +1,78 @@
+package provision
+
+import (
+	"fmt"
+
+	"github.com/docker/machine/drivers"
+)
+
+func init() {
+	Register("redhat", &RegisteredProvisioner{
+		New: NewRedHatProvisioner,
+	})
+}
+
+// RedHatProvisioner provisions CentOS, RHEL and Fedora hosts.
+type RedHatProvisioner struct {
+	Driver         drivers.Driver
+	ServiceManager ServiceManager
+}
+
+func NewRedHatProvisioner(d drivers.Driver) Provisioner {
+	return &RedHatProvisioner{Driver: d}
+}
+
+func (p *RedHatProvisioner) CompatibleWithHost(osRelease *OsRelease) bool {
+	return osRelease.Is("rhel", "centos", "fedora")
+}
+
+func (p *RedHatProvisioner) SetServiceManager(m ServiceManager) {
+	p.ServiceManager = m
+}
+
+func (p *RedHatProvisioner) InstallDocker() error {
+	drivers.Logger(p.Driver).Debug("Installing Docker...")
+	return runSSHCommand(p.Driver, "if ! type docker >/dev/null 2>&1; then curl -sSL https://get.docker.com | sudo sh -; fi")
+}
+
+func (p *RedHatProvisioner) UpgradeDocker() error {
+	drivers.Logger(p.Driver).Debug("Upgrading Docker...")
+	return runSSHCommand(p.Driver, "sudo yum -y upgrade docker")
+}
+
+func (p *RedHatProvisioner) StartDocker() error {
+	drivers.Logger(p.Driver).Debug("Starting Docker...")
+	return runSSHCommand(p.Driver, p.ServiceManager.startCommand())
+}
+
+func (p *RedHatProvisioner) StopDocker() error {
+	drivers.Logger(p.Driver).Debug("Stopping Docker...")
+	return runSSHCommand(p.Driver, p.ServiceManager.stopCommand())
+}
+
+func (p *RedHatProvisioner) GetDockerConfigDir() string {
+	return "/etc/docker"
+}
+
+// GenerateDockerConfig writes the daemon options to /etc/sysconfig/docker,
+// which is read by the sysvinit script of the Docker packages, or to a
+// drop-in for the docker unit under systemd.
+func (p *RedHatProvisioner) GenerateDockerConfig(opts DockerOptions) *DockerConfig {
+	if p.ServiceManager == Systemd {
+		return systemdDropIn(opts)
+	}
+
+	args := append(opts.tlsArgs(), opts.Args...)
+	daemonOpts := fmt.Sprintf("%s --host=unix:///var/run/docker.sock --host=tcp://0.0.0.0:%d", joinArgs(args), opts.Port)
+
+	cfg := ""
+	for _, env := range opts.Env {
+		cfg += fmt.Sprintf("%s\n", env)
+	}
+	cfg += fmt.Sprintf("OPTIONS='%s'\n", daemonOpts)
+
+	return &DockerConfig{
+		EngineConfig:     cfg,
+		EngineConfigPath: "/etc/sysconfig/docker",
+	}
+}
@@ -0,0 +1,88 @@
+package provision
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/drivers"
+)
+
+// ServiceManager is the init system which runs the Docker daemon on a host.
+type ServiceManager string
+
+const (
+	Systemd         ServiceManager = "systemd"
+	Upstart         ServiceManager = "upstart"
+	SysVInit        ServiceManager = "sysvinit"
+	Boot2DockerInit ServiceManager = "boot2docker"
+)
+
+// systemdDropInPath is where the daemon options are written on systemd
+// hosts. Distribution defaults files such as /etc/default/docker are not
+// read by the docker unit.
+const systemdDropInPath = "/etc/systemd/system/docker.service.d/10-machine.conf"
+
+// DetectServiceManager finds out which init system the host of driver d
+// runs.
+func DetectServiceManager(d drivers.Driver) (ServiceManager, error) {
+	out, err := drivers.RunSSHCommand(d, "if [ -d /run/systemd/system ]; then echo systemd; "+
+		"elif /sbin/initctl version 2>/dev/null | grep -q upstart; then echo upstart; "+
+		"else echo sysvinit; fi")
+	if err != nil {
+		return "", fmt.Errorf("unable to detect the service manager of the host: %s", err)
+	}
+
+	switch m := ServiceManager(strings.TrimSpace(string(out))); m {
+	case Systemd, Upstart, SysVInit:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown service manager %q", m)
+	}
+}
+
+// startCommand returns the command which starts the docker service.
+func (m ServiceManager) startCommand() string {
+	switch m {
+	case Systemd:
+		return "sudo systemctl daemon-reload && sudo systemctl start docker"
+	case Boot2DockerInit:
+		return "sudo /etc/init.d/docker start"
+	default:
+		return "sudo service docker start"
+	}
+}
+
+// stopCommand returns the command which stops the docker service if it is
+// running.
+func (m ServiceManager) stopCommand() string {
+	switch m {
+	case Systemd:
+		return "sudo systemctl stop docker"
+	case Boot2DockerInit:
+		return "if [ -e /var/run/docker.pid ]; then sudo /etc/init.d/docker stop ; fi"
+	default:
+		return "if [ -e /var/run/docker.pid ]; then sudo service docker stop ; fi"
+	}
+}
+
+// systemdDropIn returns a drop-in for the docker unit which replaces the
+// daemon command line with one using opts.
+func systemdDropIn(opts DockerOptions) *DockerConfig {
+	args := append(opts.tlsArgs(), opts.Args...)
+	args = append(args,
+		"--host=unix:///var/run/docker.sock",
+		fmt.Sprintf("--host=tcp://0.0.0.0:%d", opts.Port),
+	)
+
+	cfg := "[Service]\n"
+	for _, env := range opts.Env {
+		cfg += fmt.Sprintf("Environment=%s\n", env)
+	}
+	cfg += "ExecStart=\n"
+	cfg += fmt.Sprintf("ExecStart=/usr/bin/docker -d %s\n", strings.Join(args, " "))
+
+	return &DockerConfig{
+		EngineConfig:     cfg,
+		EngineConfigPath: systemdDropInPath,
+	}
+}
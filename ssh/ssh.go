@@ -6,8 +6,11 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/docker/machine/utils"
+	"golang.org/x/net/context"
 )
 
 func GetSSHCommand(host string, port int, user string, sshKey string, args ...string) *exec.Cmd {
@@ -30,8 +33,6 @@ func GetSSHCommand(host string, port int, user string, sshKey string, args ...st
 		cmd.Stdout = os.Stdout
 	}
 
-	log.Debugf("executing: %v", strings.Join(cmd.Args, " "))
-
 	return cmd
 }
 
@@ -61,17 +62,26 @@ func GenerateSSHKey(path string) error {
 	return nil
 }
 
-func WaitForTCP(addr string) error {
-	for {
-		conn, err := net.Dial("tcp", addr)
-		if err != nil {
-			continue
-		}
-		defer conn.Close()
-		if _, err = conn.Read(make([]byte, 1)); err != nil {
-			continue
-		}
-		break
+// tcpDialTimeout bounds each attempt of WaitForTCP to connect and read.
+const tcpDialTimeout = 5 * time.Second
+
+// WaitForTCP waits until the server at addr accepts a connection and sends
+// something, e.g. the banner of an SSH server. It gives up when ctx is done
+// or after ten minutes, with a *utils.TimeoutError.
+func WaitForTCP(ctx context.Context, addr string) error {
+	return utils.WaitFor(ctx, addr, utils.DefaultBackoff, func() error {
+		return checkTCP(addr)
+	})
+}
+
+func checkTCP(addr string) error {
+	conn, err := net.DialTimeout("tcp", addr, tcpDialTimeout)
+	if err != nil {
+		return err
 	}
-	return nil
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(tcpDialTimeout))
+	_, err = conn.Read(make([]byte, 1))
+	return err
 }
@@ -2,9 +2,14 @@ package ssh
 
 import (
 	"io/ioutil"
+	"net"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/docker/machine/utils"
+	"golang.org/x/net/context"
 )
 
 func TestGenerateSSHKey(t *testing.T) {
@@ -26,3 +31,46 @@ func TestGenerateSSHKey(t *testing.T) {
 	// cleanup
 	_ = os.RemoveAll(tmpDir)
 }
+
+func TestWaitForTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("SSH-2.0-test\r\n"))
+			conn.Close()
+		}
+	}()
+
+	if err := WaitForTCP(context.Background(), l.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWaitForTCPCancel(t *testing.T) {
+	// Nothing listens on a port which was just closed
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := WaitForTCP(ctx, addr); !utils.IsTimeout(err) {
+		t.Fatalf("expected waiting for a closed port to time out, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("expected WaitForTCP to give up once the context is done")
+	}
+}
@@ -1,5 +1,10 @@
 package state
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 // State represents the state of a host
 type State int
 
@@ -12,6 +17,14 @@ const (
 	Stopping
 	Starting
 	Error
+	Incomplete
+
+	// Unknown is the state of a host whose driver cannot tell it, e.g. as
+	// its provider reports a state the driver does not know.
+	Unknown
+
+	// Timeout is the state of a host which did not answer in time.
+	Timeout
 )
 
 var states = []string{
@@ -23,6 +36,9 @@ var states = []string{
 	"Stopping",
 	"Starting",
 	"Error",
+	"Incomplete",
+	"Unknown",
+	"Timeout",
 }
 
 // Given a State type, returns its string representation
@@ -33,3 +49,23 @@ func (s State) String() string {
 		return ""
 	}
 }
+
+// MarshalJSON encodes the state using its string representation
+func (s State) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// UnmarshalJSON decodes a state from its string representation
+func (s *State) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	for i, name := range states {
+		if name == str {
+			*s = State(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown state %q", str)
+}
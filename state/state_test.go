@@ -1,6 +1,7 @@
 package state
 
 import (
+	"encoding/json"
 	"testing"
 )
 
@@ -15,3 +16,42 @@ func TestDaemonCreate(t *testing.T) {
 		t.Fatal("Error state should be 'Error'")
 	}
 }
+
+func TestStateMarshalJSON(t *testing.T) {
+	b, err := json.Marshal(Stopped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `"Stopped"` {
+		t.Fatalf("expected \"Stopped\"; received %s", b)
+	}
+}
+
+func TestStateUnmarshalJSON(t *testing.T) {
+	var s State
+	if err := json.Unmarshal([]byte(`"Stopped"`), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s != Stopped {
+		t.Fatalf("expected Stopped; received %s", s)
+	}
+	if err := json.Unmarshal([]byte(`"Bogus"`), &s); err == nil {
+		t.Fatal("expected error for unknown state")
+	}
+}
+
+func TestStateUnknownAndTimeout(t *testing.T) {
+	for _, s := range []State{Unknown, Timeout} {
+		b, err := json.Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded State
+		if err := json.Unmarshal(b, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded != s || decoded == Error {
+			t.Fatalf("expected %s; received %s", s, decoded)
+		}
+	}
+}
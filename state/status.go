@@ -0,0 +1,33 @@
+package state
+
+import (
+	"time"
+)
+
+// Status is the state of a host in detail, as found at CheckedAt.
+type Status struct {
+	State State
+
+	// ProviderState is the state of the host as its provider reports it,
+	// e.g. "pending", for drivers which can tell it.
+	ProviderState string `json:",omitempty"`
+
+	// Reason explains the state, e.g. the error which made it Error.
+	Reason string `json:",omitempty"`
+
+	// Since is when the host was first found in State, if known.
+	Since time.Time
+
+	CheckedAt time.Time
+
+	// DockerReachable is whether the Docker daemon of the host answered,
+	// DockerReason why not.
+	DockerReachable bool
+	DockerReason    string `json:",omitempty"`
+}
+
+// DockerDown returns whether the host is running but its Docker daemon
+// does not answer.
+func (s Status) DockerDown() bool {
+	return s.State == Running && !s.DockerReachable
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"text/template"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/machine/drivers"
+	"github.com/docker/machine/state"
+	"github.com/docker/machine/utils"
+)
+
+// hostStatus is the status of a host as shown by "machine status".
+type hostStatus struct {
+	Name       string
+	DriverName string
+	URL        string
+	state.Status
+}
+
+// stateFromError returns the state of a host whose driver failed to get it
+// with err.
+func stateFromError(err error) state.State {
+	switch {
+	case drivers.IsNotSupported(err):
+		return state.Unknown
+	case isTimeout(err):
+		return state.Timeout
+	}
+	return state.Error
+}
+
+// isTimeout returns whether err is a wait or a network operation which
+// timed out.
+func isTimeout(err error) bool {
+	if utils.IsTimeout(err) {
+		return true
+	}
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
+
+// incompleteReason explains why a host is Incomplete.
+func incompleteReason(host *Host) string {
+	reason := "create failed before any step completed"
+	if host.CreateStep != "" {
+		reason = fmt.Sprintf("create failed after step %s", host.CreateStep)
+	}
+	return reason + ", resume with create --resume"
+}
+
+// getHostStatus finds the status of host, giving up on its driver and on
+// its Docker daemon after timeout. The daemon is only checked if the host is
+// running. A state which could be found is saved to the store as with "ls".
+func getHostStatus(host *Host, timeout time.Duration) hostStatus {
+	status := hostStatus{
+		Name:       host.Name,
+		DriverName: host.DriverName,
+		Status:     state.Status{CheckedAt: time.Now()},
+	}
+
+	if host.CreateIncomplete {
+		status.State = state.Incomplete
+		status.Reason = incompleteReason(host)
+		return status
+	}
+
+	done := make(chan hostStatus, 1)
+	go func() {
+		s := status
+		st, err := host.Driver.GetState()
+		if err != nil {
+			s.State = stateFromError(err)
+			s.Reason = err.Error()
+		} else {
+			s.State = st
+		}
+
+		if stater, ok := host.Driver.(drivers.ProviderStater); ok && drivers.Supports(host.Driver, drivers.CapabilityProviderState) {
+			if providerState, err := stater.GetProviderState(); err == nil {
+				s.ProviderState = providerState
+			} else {
+				log.Debugf("error getting the provider state of %s: %s", host.Name, err)
+			}
+		}
+
+		if url, err := host.GetURL(); err == nil {
+			s.URL = url
+		}
+		done <- s
+	}()
+
+	select {
+	case status = <-done:
+	case <-time.After(timeout):
+		status.State = state.Timeout
+		status.Reason = fmt.Sprintf("the %s driver did not answer within %s", host.DriverName, timeout)
+		return status
+	}
+
+	if status.Reason == "" {
+		status.Since = host.updateLastKnownState(status.State, status.URL)
+	}
+
+	if status.State == state.Running {
+		if err := pingHostDocker(host, status.URL, timeout); err != nil {
+			status.DockerReason = err.Error()
+		} else {
+			status.DockerReachable = true
+		}
+	}
+
+	return status
+}
+
+// pingHostDocker checks that the Docker daemon of host answers at
+// dockerURL over TLS with the client certificate.
+func pingHostDocker(host *Host, dockerURL string, timeout time.Duration) error {
+	if dockerURL == "" {
+		return fmt.Errorf("the machine has no URL")
+	}
+	if host.ClientCertPath == "" {
+		return fmt.Errorf("there is no client certificate to connect with")
+	}
+	tlsConfig, err := utils.GetDockerTLSConfig(host.CaCertPath, host.ClientCertPath, host.ClientKeyPath)
+	if err != nil {
+		return err
+	}
+	return utils.PingDocker(dockerURL, tlsConfig, timeout)
+}
+
+// printHostStatus writes status to w in detail, as JSON when format is
+// "json", or by executing format as a Go template.
+func printHostStatus(w io.Writer, status hostStatus, format string) error {
+	switch format {
+	case "":
+		st := status.State.String()
+		if st == "" {
+			st = "None"
+		}
+		fmt.Fprintf(w, "Name:\t%s\n", status.Name)
+		fmt.Fprintf(w, "Driver:\t%s\n", status.DriverName)
+		fmt.Fprintf(w, "State:\t%s\n", st)
+		if status.ProviderState != "" {
+			fmt.Fprintf(w, "Provider state:\t%s\n", status.ProviderState)
+		}
+		if status.Reason != "" {
+			fmt.Fprintf(w, "Reason:\t%s\n", status.Reason)
+		}
+		if !status.Since.IsZero() {
+			fmt.Fprintf(w, "Since:\t%s\n", status.Since.Local().Format(time.RFC3339))
+		}
+		fmt.Fprintf(w, "Docker:\t%s\n", dockerStatusString(status.Status))
+		if status.URL != "" {
+			fmt.Fprintf(w, "URL:\t%s\n", status.URL)
+		}
+		fmt.Fprintf(w, "Checked:\t%s\n", status.CheckedAt.Local().Format(time.RFC3339))
+		return nil
+	case "json":
+		data, err := json.MarshalIndent(status, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(data))
+		return nil
+	}
+
+	format = strings.NewReplacer(`\t`, "\t", `\n`, "\n").Replace(format)
+	tmpl, err := template.New("status").Parse(format)
+	if err != nil {
+		return fmt.Errorf("Invalid format template: %s", err)
+	}
+	if err := tmpl.Execute(w, status); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+	return nil
+}
+
+// dockerStatusString describes whether the Docker daemon of a host answers.
+func dockerStatusString(status state.Status) string {
+	switch {
+	case status.DockerReachable:
+		return "Running"
+	case status.DockerDown():
+		return fmt.Sprintf("Down, although the machine is running: %s", status.DockerReason)
+	}
+	return "Not checked, as the machine is not running"
+}
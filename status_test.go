@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers"
+	"github.com/docker/machine/state"
+	"github.com/docker/machine/utils"
+)
+
+func TestStateFromError(t *testing.T) {
+	cases := []struct {
+		err      error
+		expected state.State
+	}{
+		{&drivers.ErrNotSupported{Driver: "fake", Operation: "state"}, state.Unknown},
+		{&utils.TimeoutError{What: "the instance"}, state.Timeout},
+		{errors.New("instance not found"), state.Error},
+	}
+	for _, c := range cases {
+		if st := stateFromError(c.err); st != c.expected {
+			t.Fatalf("expected %s for %q; received %s", c.expected, c.err, st)
+		}
+	}
+}
+
+func TestPrintHostStatus(t *testing.T) {
+	status := hostStatus{
+		Name:       "foo",
+		DriverName: "fake",
+		Status: state.Status{
+			State:         state.Running,
+			ProviderState: "running",
+			DockerReason:  "connection refused",
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := printHostStatus(&buf, status, ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"State:\tRunning\n", "Provider state:\trunning\n", "Docker:\tDown, although the machine is running: connection refused\n"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Fatalf("expected %q in the status; received %s", line, buf.String())
+		}
+	}
+
+	buf.Reset()
+	status.State = state.Stopped
+	if err := printHostStatus(&buf, status, "{{.Name}} {{.State}}"); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "foo Stopped\n" {
+		t.Fatalf("expected the template to be executed; received %q", buf.String())
+	}
+}
@@ -6,17 +6,28 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/machine/drivers"
 	"github.com/docker/machine/utils"
+	"golang.org/x/net/context"
 )
 
+// cleanupTimeout bounds the removal of a host whose creation was
+// interrupted, which cannot use the context of the create as it is done.
+const cleanupTimeout = 5 * time.Minute
+
 // Store persists hosts on the filesystem
 type Store struct {
 	Path           string
 	CaCertPath     string
 	PrivateKeyPath string
+
+	// ClientCertPath and ClientKeyPath are used to connect to the Docker
+	// daemon of the hosts, e.g. to check that it is up after provisioning
+	ClientCertPath string
+	ClientKeyPath  string
 }
 
 func NewStore(rootPath string, caCert string, privateKey string) *Store {
@@ -27,7 +38,83 @@ func NewStore(rootPath string, caCert string, privateKey string) *Store {
 	return &Store{Path: rootPath, CaCertPath: caCert, PrivateKeyPath: privateKey}
 }
 
-func (s *Store) Create(name string, driverName string, flags drivers.DriverOptions) (*Host, error) {
+// Create creates the host name. If ctx is done before the host is created,
+// e.g. as machine was interrupted, whatever was created is removed again.
+func (s *Store) Create(ctx context.Context, name string, driverName string, flags drivers.DriverOptions) (*Host, error) {
+	host, err := s.newHost(name, driverName, flags)
+	if err != nil {
+		return host, err
+	}
+
+	hostPath := filepath.Join(s.Path, name)
+
+	if err := os.MkdirAll(hostPath, 0700); err != nil {
+		return nil, err
+	}
+
+	host.CreatedAt = time.Now()
+
+	if err := host.SaveConfig(); err != nil {
+		return host, err
+	}
+
+	if err := host.Create(ctx, name); err != nil {
+		if ctx.Err() != nil {
+			return s.cleanUpCreate(host, err)
+		}
+		return host, err
+	}
+
+	return host, nil
+}
+
+// cleanUpCreate removes host, whose creation failed with err once its
+// context was done. The host is only returned if it could not be removed,
+// so it can still be resumed or removed later.
+func (s *Store) cleanUpCreate(host *Host, err error) (*Host, error) {
+	log.Warnf("Creating %s was interrupted, removing it...", host.Name)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+
+	start := time.Now()
+	removeErr := host.Driver.Remove(ctx)
+	host.recordEvent(eventRemove, start, &removeErr)
+	if removeErr != nil {
+		log.Errorf("Error removing %s: %s", host.Name, removeErr)
+		return host, err
+	}
+	if removeErr := host.removeStorePath(); removeErr != nil {
+		log.Errorf("Error removing %s: %s", host.Name, removeErr)
+		return host, err
+	}
+
+	return nil, err
+}
+
+// Resume continues creating a host for which "machine create" failed, from
+// the step after the last one which completed.
+func (s *Store) Resume(ctx context.Context, name string) (*Host, error) {
+	host, err := s.Load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !host.CreateIncomplete {
+		return host, fmt.Errorf("Machine %s has already been created", name)
+	}
+
+	if err := host.resumeCreate(ctx); err != nil {
+		return host, err
+	}
+
+	return host, nil
+}
+
+// newHost sets up a host which does not exist yet from the flags of "machine
+// create" and runs the pre-create check of its driver, without touching the
+// store or the provider.
+func (s *Store) newHost(name string, driverName string, flags drivers.DriverOptions) (*Host, error) {
 	exists, err := s.Exists(name)
 	if err != nil {
 		return nil, err
@@ -42,36 +129,142 @@ func (s *Store) Create(name string, driverName string, flags drivers.DriverOptio
 	if err != nil {
 		return host, err
 	}
+	s.setClientCert(host)
 	if flags != nil {
 		if err := host.Driver.SetConfigFromFlags(flags); err != nil {
 			return host, err
 		}
+		utils.RegisterSecrets(host.Driver)
+
+		labels, err := ParseLabels(flags.StringSlice("label"))
+		if err != nil {
+			return host, err
+		}
+		if len(labels) > 0 {
+			host.Labels = labels
+			if labeler, ok := host.Driver.(drivers.Labeler); ok && drivers.Supports(host.Driver, drivers.CapabilityLabels) {
+				labeler.SetLabels(labels)
+			} else {
+				log.Warnf("The %s driver cannot add labels to the machine on the provider, they are only stored with it and passed to Docker", host.DriverName)
+			}
+		}
+
+		if userDataPath := flags.String("user-data"); userDataPath != "" {
+			// The user data is not saved with the driver, so the path is
+			// kept for resuming a create which failed before the host was
+			// made on the provider.
+			userDataPath, err := filepath.Abs(userDataPath)
+			if err != nil {
+				return host, err
+			}
+			if err := setUserData(host.Driver, userDataPath); err != nil {
+				return host, err
+			}
+			host.UserData = userDataPath
+		}
+
+		engineOptions, err := NewEngineOptions(flags)
+		if err != nil {
+			return host, err
+		}
+		host.EngineOptions = engineOptions
+
+		hooks, err := NewHookOptions(flags)
+		if err != nil {
+			return host, err
+		}
+		host.Hooks = hooks
 	}
 
 	if err := host.Driver.PreCreateCheck(); err != nil {
 		return nil, err
 	}
 
-	if err := os.MkdirAll(hostPath, 0700); err != nil {
+	return host, nil
+}
+
+// Plan checks that a host could be created with the flags of "machine
+// create" and returns the provider resources its driver would make, for
+// "machine create --dry-run". Nothing is saved to the store. The resources
+// are nil if the driver cannot plan on the provider.
+func (s *Store) Plan(name string, driverName string, flags drivers.DriverOptions) (*Host, []drivers.PlannedResource, error) {
+	host, err := s.newHost(name, driverName, flags)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	planner, ok := host.Driver.(drivers.Planner)
+	if !ok {
+		return host, nil, nil
+	}
+
+	resources, err := planner.Plan()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return host, resources, nil
+}
+
+// setUserData passes the contents of the file at path to the driver as user
+// data, failing on drivers which have no way to hand it to the provider.
+func setUserData(d drivers.Driver, path string) error {
+	setter, ok := d.(drivers.UserDataSetter)
+	if !ok {
+		return fmt.Errorf("The %s driver does not support --user-data", d.DriverName())
+	}
+
+	userData, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Error reading user data: %s", err)
+	}
+	setter.SetUserData(userData)
+
+	return nil
+}
+
+// Adopt imports a host which was created outside of machine into the store
+// and configures TLS for its Docker daemon.
+func (s *Store) Adopt(ctx context.Context, name string, driverName string, flags drivers.DriverOptions) (*Host, error) {
+	if _, err := ValidateHostName(name); err != nil {
 		return nil, err
 	}
 
-	if err := host.SaveConfig(); err != nil {
-		return host, err
+	exists, err := s.Exists(name)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("Machine %s already exists", name)
 	}
 
-	if err := host.Create(name); err != nil {
+	hostPath := filepath.Join(s.Path, name)
+
+	host, err := NewHost(name, driverName, hostPath, s.CaCertPath, s.PrivateKeyPath)
+	if err != nil {
 		return host, err
 	}
+	s.setClientCert(host)
 
-	if err := host.ConfigureAuth(); err != nil {
-		return host, err
+	adopter, ok := host.Driver.(drivers.Adopter)
+	if !ok {
+		return nil, drivers.ErrAdoptNotSupported
+	}
+
+	// The driver checks its flags and looks the host up before it writes
+	// to the machine directory, which does not exist until then. Whatever
+	// it wrote is removed if adopting fails, so the name can be used again.
+	if err := host.adopt(ctx, adopter, flags); err != nil {
+		if rmErr := os.RemoveAll(hostPath); rmErr != nil {
+			log.Warnf("Error removing %s after adopt failed: %s", hostPath, rmErr)
+		}
+		return nil, err
 	}
 
 	return host, nil
 }
 
-func (s *Store) Remove(name string, force bool) error {
+func (s *Store) Remove(ctx context.Context, name string, force bool) error {
 	active, err := s.GetActive()
 	if err != nil {
 		return err
@@ -87,7 +280,7 @@ func (s *Store) Remove(name string, force bool) error {
 	if err != nil {
 		return err
 	}
-	return host.Remove(force)
+	return host.Remove(ctx, force)
 }
 
 func (s *Store) List() ([]Host, error) {
@@ -124,7 +317,21 @@ func (s *Store) Exists(name string) (bool, error) {
 
 func (s *Store) Load(name string) (*Host, error) {
 	hostPath := filepath.Join(s.Path, name)
-	return LoadHost(name, hostPath)
+	host, err := LoadHost(name, hostPath)
+	if err != nil {
+		return nil, err
+	}
+	s.setClientCert(host)
+	return host, nil
+}
+
+// setClientCert sets the client certificate of the store on host, unless
+// the store was created without one.
+func (s *Store) setClientCert(host *Host) {
+	if s.ClientCertPath != "" {
+		host.ClientCertPath = s.ClientCertPath
+		host.ClientKeyPath = s.ClientKeyPath
+	}
 }
 
 func (s *Store) GetActive() (*Host, error) {
@@ -159,6 +366,11 @@ func (s *Store) RemoveActive() error {
 	return os.Remove(s.activePath())
 }
 
+// eventLogPath returns the path to the event log of the store
+func (s *Store) eventLogPath() string {
+	return filepath.Join(s.Path, eventLogName)
+}
+
 // activePath returns the path to the file that stores the name of the
 // active host
 func (s *Store) activePath() string {
@@ -5,8 +5,10 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/docker/machine/drivers"
 	_ "github.com/docker/machine/drivers/none"
 	"github.com/docker/machine/utils"
+	"golang.org/x/net/context"
 )
 
 type DriverOptionsMock struct {
@@ -14,7 +16,10 @@ type DriverOptionsMock struct {
 }
 
 func (d DriverOptionsMock) String(key string) string {
-	return d.Data[key].(string)
+	if v, ok := d.Data[key].(string); ok {
+		return v
+	}
+	return ""
 }
 
 func (d DriverOptionsMock) Int(key string) int {
@@ -25,6 +30,13 @@ func (d DriverOptionsMock) Bool(key string) bool {
 	return d.Data[key].(bool)
 }
 
+func (d DriverOptionsMock) StringSlice(key string) []string {
+	if v, ok := d.Data[key].([]string); ok {
+		return v
+	}
+	return nil
+}
+
 func clearHosts() error {
 	return os.RemoveAll(utils.GetMachineDir())
 }
@@ -42,19 +54,110 @@ func TestStoreCreate(t *testing.T) {
 
 	store := NewStore("", "", "")
 
-	host, err := store.Create("test", "none", flags)
+	host, err := store.Create(context.Background(), "test", "none", flags)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if host.Name != "test" {
 		t.Fatal("Host name is incorrect")
 	}
+	if host.CreateIncomplete {
+		t.Fatal("expected create to be complete")
+	}
 	path := filepath.Join(utils.GetMachineDir(), "test")
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		t.Fatalf("Host path doesn't exist: %s", path)
 	}
 }
 
+func TestStoreCreateLabels(t *testing.T) {
+	if err := clearHosts(); err != nil {
+		t.Fatal(err)
+	}
+
+	flags := &DriverOptionsMock{
+		Data: map[string]interface{}{
+			"url":   "unix:///var/run/docker.sock",
+			"label": []string{"team=web"},
+		},
+	}
+
+	store := NewStore("", "", "")
+
+	if _, err := store.Create(context.Background(), "test", "none", flags); err != nil {
+		t.Fatal(err)
+	}
+
+	host, err := store.Load("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host.Labels["team"] != "web" {
+		t.Fatalf("expected label team=web; received %v", host.Labels)
+	}
+}
+
+func TestStoreCreateUserDataUnsupported(t *testing.T) {
+	if err := clearHosts(); err != nil {
+		t.Fatal(err)
+	}
+
+	flags := &DriverOptionsMock{
+		Data: map[string]interface{}{
+			"url":       "unix:///var/run/docker.sock",
+			"user-data": "cloud-config.yml",
+		},
+	}
+
+	store := NewStore("", "", "")
+
+	if _, err := store.Create(context.Background(), "test", "none", flags); err == nil {
+		t.Fatal("expected error for a driver without user data support")
+	}
+
+	exists, err := store.Exists("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatal("expected the machine not to be created")
+	}
+}
+
+func TestStorePlan(t *testing.T) {
+	if err := clearHosts(); err != nil {
+		t.Fatal(err)
+	}
+
+	flags := &DriverOptionsMock{
+		Data: map[string]interface{}{
+			"url":   "unix:///var/run/docker.sock",
+			"label": []string{"team=web"},
+		},
+	}
+
+	store := NewStore("", "", "")
+
+	host, resources, err := store.Plan("test", "none", flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host.Labels["team"] != "web" {
+		t.Fatalf("expected label team=web; received %v", host.Labels)
+	}
+	if resources != nil {
+		t.Fatalf("expected no resources for a driver which cannot plan; received %v", resources)
+	}
+
+	exists, err := store.Exists("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatal("expected the machine not to be created")
+	}
+}
+
 func TestStoreRemove(t *testing.T) {
 	if err := clearHosts(); err != nil {
 		t.Fatal(err)
@@ -67,7 +170,7 @@ func TestStoreRemove(t *testing.T) {
 	}
 
 	store := NewStore("", "", "")
-	_, err := store.Create("test", "none", flags)
+	_, err := store.Create(context.Background(), "test", "none", flags)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -75,7 +178,7 @@ func TestStoreRemove(t *testing.T) {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		t.Fatalf("Host path doesn't exist: %s", path)
 	}
-	err = store.Remove("test", false)
+	err = store.Remove(context.Background(), "test", false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -96,7 +199,7 @@ func TestStoreList(t *testing.T) {
 	}
 
 	store := NewStore("", "", "")
-	_, err := store.Create("test", "none", flags)
+	_, err := store.Create(context.Background(), "test", "none", flags)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -125,7 +228,7 @@ func TestStoreExists(t *testing.T) {
 	if exists {
 		t.Fatal("Exists returned true when it should have been false")
 	}
-	_, err = store.Create("test", "none", flags)
+	_, err = store.Create(context.Background(), "test", "none", flags)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -151,7 +254,7 @@ func TestStoreLoad(t *testing.T) {
 	}
 
 	store := NewStore("", "", "")
-	_, err := store.Create("test", "none", flags)
+	_, err := store.Create(context.Background(), "test", "none", flags)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -194,7 +297,7 @@ func TestStoreGetSetActive(t *testing.T) {
 	}
 
 	// Set normal host
-	originalHost, err := store.Create("test", "none", flags)
+	originalHost, err := store.Create(context.Background(), "test", "none", flags)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -232,3 +335,23 @@ func TestStoreGetSetActive(t *testing.T) {
 		t.Fatalf("Active host %s is not nil", host.Name)
 	}
 }
+
+func TestStoreAdoptNotSupported(t *testing.T) {
+	if err := clearHosts(); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore("", "", "")
+
+	if _, err := store.Adopt(context.Background(), "test", "none", &DriverOptionsMock{}); err != drivers.ErrAdoptNotSupported {
+		t.Fatalf("expected %q; received %v", drivers.ErrAdoptNotSupported, err)
+	}
+
+	exists, err := store.Exists("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatal("Adopt should not leave a host behind when the driver does not support it")
+	}
+}
@@ -96,8 +96,12 @@ func (b *B2dUtils) DownloadISO(dir, file, url string) error {
 		return err
 	}
 	defer os.Remove(f.Name())
-	if _, err := io.Copy(f, rsp.Body); err != nil {
-		// TODO: display download progress?
+
+	progress := NewProgress()
+	progress.Step("Downloading "+file, rsp.ContentLength)
+	_, err = io.Copy(f, ProgressReader(rsp.Body, progress))
+	progress.Done(err)
+	if err != nil {
 		return err
 	}
 	if err := f.Close(); err != nil {
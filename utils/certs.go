@@ -7,6 +7,8 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"fmt"
+	"io/ioutil"
 	"math/big"
 	"net"
 	"os"
@@ -148,3 +150,24 @@ func GenerateCert(hosts []string, certFile, keyFile, caFile, caKeyFile, org stri
 
 	return nil
 }
+
+// GetCertExpiry returns the time after which the PEM encoded certificate
+// stored in certFile is no longer valid.
+func GetCertExpiry(certFile string) (time.Time, error) {
+	data, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return time.Time{}, fmt.Errorf("no certificate found in %s", certFile)
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return cert.NotAfter, nil
+}
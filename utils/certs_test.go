@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestGenerateCACertificate(t *testing.T) {
@@ -74,3 +75,32 @@ func TestGenerateCert(t *testing.T) {
 	// cleanup
 	_ = os.RemoveAll(tmpDir)
 }
+
+func TestGetCertExpiry(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caCertPath := filepath.Join(tmpDir, "ca.pem")
+	caKeyPath := filepath.Join(tmpDir, "key.pem")
+	if err := GenerateCACertificate(caCertPath, caKeyPath, "test-org", 2048); err != nil {
+		t.Fatal(err)
+	}
+
+	expiry, err := GetCertExpiry(caCertPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expiry.Before(time.Now().Add(24 * time.Hour * 1000)) {
+		t.Fatalf("expected certificate to expire in about 1080 days; expires %s", expiry)
+	}
+
+	if _, err := GetCertExpiry(caKeyPath); err == nil {
+		t.Fatal("expected error reading expiry from a private key")
+	}
+
+	// cleanup
+	_ = os.RemoveAll(tmpDir)
+}
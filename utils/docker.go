@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// dockerRequestTimeout bounds each request made while waiting for the
+// daemon.
+const dockerRequestTimeout = 10 * time.Second
+
+// DockerVersion is the response of the /version endpoint of the Docker
+// remote API.
+type DockerVersion struct {
+	Version       string
+	ApiVersion    string
+	GitCommit     string
+	GoVersion     string
+	Os            string
+	Arch          string
+	KernelVersion string
+}
+
+// GetDockerTLSConfig returns a TLS configuration that authenticates with
+// the given client certificate and verifies the daemon against the CA.
+func GetDockerTLSConfig(caCertPath, clientCertPath, clientKeyPath string) (*tls.Config, error) {
+	caCert, err := ioutil.ReadFile(caCertPath)
+	if err != nil {
+		return nil, err
+	}
+
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("unable to parse CA certificate %s", caCertPath)
+	}
+
+	keyPair, err := tls.LoadX509KeyPair(clientCertPath, clientKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		RootCAs:      certPool,
+		Certificates: []tls.Certificate{keyPair},
+	}, nil
+}
+
+// getDockerClient returns an HTTP client and the base URL to reach the
+// Docker daemon listening at dockerURL (e.g. tcp://1.2.3.4:2376).
+func getDockerClient(dockerURL string, tlsConfig *tls.Config, timeout time.Duration) (*http.Client, string, error) {
+	u, err := url.Parse(dockerURL)
+	if err != nil {
+		return nil, "", err
+	}
+	if u.Scheme != "tcp" {
+		return nil, "", fmt.Errorf("unsupported Docker URL scheme %q", u.Scheme)
+	}
+
+	scheme := "http"
+	transport := &http.Transport{
+		Dial: func(network, addr string) (net.Conn, error) {
+			return net.DialTimeout(network, addr, timeout)
+		},
+	}
+	if tlsConfig != nil {
+		scheme = "https"
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}
+
+	return client, fmt.Sprintf("%s://%s", scheme, u.Host), nil
+}
+
+// GetDockerVersion queries the version of the Docker daemon listening at
+// dockerURL. A nil tlsConfig talks to the daemon without TLS.
+func GetDockerVersion(dockerURL string, tlsConfig *tls.Config, timeout time.Duration) (*DockerVersion, error) {
+	client, baseURL, err := getDockerClient(dockerURL, tlsConfig, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, err := client.Get(baseURL + "/version")
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response from %s/version: %s", baseURL, rsp.Status)
+	}
+
+	var version DockerVersion
+	if err := json.NewDecoder(rsp.Body).Decode(&version); err != nil {
+		return nil, err
+	}
+
+	return &version, nil
+}
+
+// PingDocker calls the /_ping endpoint of the Docker daemon listening at
+// dockerURL.
+func PingDocker(dockerURL string, tlsConfig *tls.Config, timeout time.Duration) error {
+	client, baseURL, err := getDockerClient(dockerURL, tlsConfig, timeout)
+	if err != nil {
+		return err
+	}
+
+	rsp, err := client.Get(baseURL + "/_ping")
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response from %s/_ping: %s", baseURL, rsp.Status)
+	}
+
+	return nil
+}
+
+// WaitForDocker waits until the Docker daemon at dockerURL answers /_ping
+// and /version, retrying as b says. The returned error names the last check
+// that failed.
+func WaitForDocker(ctx context.Context, dockerURL string, tlsConfig *tls.Config, b Backoff) error {
+	return WaitFor(ctx, "Docker at "+dockerURL, b, func() error {
+		if err := PingDocker(dockerURL, tlsConfig, dockerRequestTimeout); err != nil {
+			return err
+		}
+		_, err := GetDockerVersion(dockerURL, tlsConfig, dockerRequestTimeout)
+		return err
+	})
+}
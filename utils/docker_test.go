@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// newTestDockerTLS generates a CA, a server certificate for 127.0.0.1 and a
+// client certificate in dir and returns a listener serving a minimal Docker
+// API which requires the client certificate.
+func newTestDockerTLS(t *testing.T, dir string) net.Listener {
+	caCertPath := filepath.Join(dir, "ca.pem")
+	caKeyPath := filepath.Join(dir, "ca-key.pem")
+	if err := GenerateCACertificate(caCertPath, caKeyPath, "test-org", 2048); err != nil {
+		t.Fatal(err)
+	}
+
+	serverCertPath := filepath.Join(dir, "server.pem")
+	serverKeyPath := filepath.Join(dir, "server-key.pem")
+	if err := GenerateCert([]string{"127.0.0.1"}, serverCertPath, serverKeyPath, caCertPath, caKeyPath, "test-org", 2048); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := GenerateCert([]string{""}, filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), caCertPath, caKeyPath, "test-org", 2048); err != nil {
+		t.Fatal(err)
+	}
+
+	caCert, err := ioutil.ReadFile(caCertPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPool := x509.NewCertPool()
+	certPool.AppendCertsFromPEM(caCert)
+
+	keyPair, err := tls.LoadX509KeyPair(serverCertPath, serverKeyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{keyPair},
+		ClientCAs:    certPool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/_ping", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "OK")
+	})
+	mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"Version":"1.5.0","ApiVersion":"1.17"}`)
+	})
+	go http.Serve(l, mux)
+
+	return l
+}
+
+func TestWaitForDocker(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	l := newTestDockerTLS(t, tmpDir)
+	defer l.Close()
+
+	tlsConfig, err := GetDockerTLSConfig(filepath.Join(tmpDir, "ca.pem"),
+		filepath.Join(tmpDir, "cert.pem"), filepath.Join(tmpDir, "key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dockerURL := fmt.Sprintf("tcp://%s", l.Addr())
+	if err := WaitForDocker(context.Background(), dockerURL, tlsConfig, Backoff{Interval: 100 * time.Millisecond, Timeout: time.Second}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWaitForDockerWrongCA(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	l := newTestDockerTLS(t, tmpDir)
+	defer l.Close()
+
+	otherCaCertPath := filepath.Join(tmpDir, "other-ca.pem")
+	if err := GenerateCACertificate(otherCaCertPath, filepath.Join(tmpDir, "other-ca-key.pem"), "test-org", 2048); err != nil {
+		t.Fatal(err)
+	}
+
+	tlsConfig, err := GetDockerTLSConfig(otherCaCertPath,
+		filepath.Join(tmpDir, "cert.pem"), filepath.Join(tmpDir, "key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dockerURL := fmt.Sprintf("tcp://%s", l.Addr())
+	if err := WaitForDocker(context.Background(), dockerURL, tlsConfig, Backoff{Interval: 100 * time.Millisecond, Timeout: time.Second}); err == nil {
+		t.Fatal("expected error for a daemon with a certificate from another CA")
+	}
+}
+
+func TestWaitForDockerNotListening(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dockerURL := fmt.Sprintf("tcp://%s", l.Addr())
+	l.Close()
+
+	err = WaitForDocker(context.Background(), dockerURL, nil, Backoff{Interval: 100 * time.Millisecond, Timeout: 300 * time.Millisecond})
+	if err == nil {
+		t.Fatal("expected error for a daemon that is not listening")
+	}
+	if !strings.Contains(err.Error(), dockerURL) {
+		t.Fatalf("expected error to name %s; received %s", dockerURL, err)
+	}
+}
@@ -0,0 +1,310 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/docker/pkg/term"
+)
+
+// Progress is told how a long operation, such as a download, is going, so
+// the user is not left waiting without feedback. The operation is made of
+// steps, run one after the other.
+type Progress interface {
+	// Step starts the step name, of total bytes, or of an unknown size if
+	// total is negative. A step in progress is done first.
+	Step(name string, total int64)
+
+	// Add reports n more bytes of the current step as done.
+	Add(n int64)
+
+	// Done ends the current step, which failed if err is not nil.
+	Done(err error)
+}
+
+const (
+	// barInterval is how often the progress bar is drawn.
+	barInterval = 200 * time.Millisecond
+
+	// progressLogInterval is how often the progress is logged when there is
+	// no terminal to draw the bar on.
+	progressLogInterval = 10 * time.Second
+
+	barWidth = 30
+)
+
+// NewProgress returns the Progress shown to the user: a bar on standard
+// error if it is a terminal, or else log entries every few seconds, with the
+// progress in their fields so they are JSON events with --log-format json.
+func NewProgress() Progress {
+	return NewLoggerProgress(log.WithFields(log.Fields{}))
+}
+
+// NewLoggerProgress is NewProgress logging with logger, e.g. one with the
+// name of the machine the operation is for in its fields.
+func NewLoggerProgress(logger *log.Entry) Progress {
+	if os.Getenv("MACHINE_LOG_FORMAT") != "json" && term.IsTerminal(os.Stderr.Fd()) {
+		b := &bar{w: os.Stderr}
+		return &barProgress{tickerProgress: newTickerProgress(barInterval, logger, b.show), bar: b}
+	}
+	return newTickerProgress(progressLogInterval, logger, func(s progressStatus, final bool, err error) {
+		logProgress(logger, s, final, err)
+	})
+}
+
+// ProgressReader returns a reader of r which adds what is read to p.
+func ProgressReader(r io.Reader, p Progress) io.Reader {
+	return &progressReader{r: r, p: p}
+}
+
+type progressReader struct {
+	r io.Reader
+	p Progress
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.p.Add(int64(n))
+	return n, err
+}
+
+// progressStatus is how the current step is going.
+type progressStatus struct {
+	Name    string
+	Total   int64
+	Done    int64
+	Elapsed time.Duration
+}
+
+func (s progressStatus) String() string {
+	elapsed := s.Elapsed - s.Elapsed%time.Second
+	switch {
+	case s.Total > 0:
+		return fmt.Sprintf("%3d%% %s / %s %s", s.Done*100/s.Total, formatBytes(s.Done), formatBytes(s.Total), elapsed)
+	case s.Done > 0:
+		return fmt.Sprintf("%s %s", formatBytes(s.Done), elapsed)
+	}
+	return elapsed.String()
+}
+
+// formatBytes returns n in the largest unit it has at least one of.
+func formatBytes(n int64) string {
+	units := []string{"B", "KB", "MB", "GB"}
+	value := float64(n)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d B", n)
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
+
+// tickerProgress shows the current step every interval while it runs, and
+// once more when it is done.
+type tickerProgress struct {
+	interval time.Duration
+	logger   *log.Entry
+	show     func(s progressStatus, final bool, err error)
+
+	mu     sync.Mutex
+	status progressStatus
+	start  time.Time
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func newTickerProgress(interval time.Duration, logger *log.Entry, show func(s progressStatus, final bool, err error)) *tickerProgress {
+	return &tickerProgress{interval: interval, logger: logger, show: show}
+}
+
+func (p *tickerProgress) Step(name string, total int64) {
+	p.Done(nil)
+
+	p.mu.Lock()
+	p.status = progressStatus{Name: name, Total: total}
+	p.start = time.Now()
+	p.stop = make(chan struct{})
+	p.mu.Unlock()
+
+	p.logger.WithFields(log.Fields{"step": name, "total": total}).Debugf("%s...", name)
+
+	p.wg.Add(1)
+	go p.tick(p.stop)
+}
+
+func (p *tickerProgress) tick(stop chan struct{}) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.show(p.current(), false, nil)
+		}
+	}
+}
+
+func (p *tickerProgress) current() progressStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	status := p.status
+	status.Elapsed = time.Since(p.start)
+	return status
+}
+
+func (p *tickerProgress) Add(n int64) {
+	p.mu.Lock()
+	p.status.Done += n
+	p.mu.Unlock()
+}
+
+func (p *tickerProgress) Done(err error) {
+	p.mu.Lock()
+	stop := p.stop
+	p.stop = nil
+	p.mu.Unlock()
+	if stop == nil {
+		return
+	}
+
+	close(stop)
+	p.wg.Wait()
+	p.show(p.current(), true, err)
+}
+
+// logProgress logs the status of a step with logger.
+func logProgress(logger *log.Entry, s progressStatus, final bool, err error) {
+	entry := logger.WithFields(log.Fields{
+		"step":    s.Name,
+		"bytes":   s.Done,
+		"total":   s.Total,
+		"elapsed": s.Elapsed.Seconds(),
+		"done":    final,
+	})
+	switch {
+	case err != nil:
+		entry.Infof("%s: failed after %s", s.Name, s)
+	case final:
+		entry.Infof("%s: done, %s", s.Name, s)
+	default:
+		entry.Infof("%s: %s", s.Name, s)
+	}
+}
+
+var (
+	barsMu sync.Mutex
+
+	// bars are the bars being shown, innermost last. Only the innermost
+	// one is drawn, e.g. the download of an ISO during a create step.
+	bars []*bar
+)
+
+// barProgress shows its steps with a bar, which takes over the line of the
+// bars shown already until the step is done.
+type barProgress struct {
+	*tickerProgress
+	bar *bar
+}
+
+func (p *barProgress) Step(name string, total int64) {
+	p.Done(nil)
+
+	barsMu.Lock()
+	if len(bars) > 0 {
+		bars[len(bars)-1].clear()
+	}
+	bars = append(bars, p.bar)
+	barsMu.Unlock()
+
+	p.tickerProgress.Step(name, total)
+}
+
+// bar draws the status of steps as a progress bar on a line of a terminal.
+type bar struct {
+	w io.Writer
+
+	// drawn is the length of the line last drawn, if it was not cleared.
+	drawn int
+}
+
+func (b *bar) show(s progressStatus, final bool, err error) {
+	barsMu.Lock()
+	defer barsMu.Unlock()
+
+	i := len(bars) - 1
+	for i >= 0 && bars[i] != b {
+		i--
+	}
+	if i == -1 {
+		return
+	}
+	top := i == len(bars)-1
+	if final {
+		bars = append(bars[:i], bars[i+1:]...)
+	}
+	if !top {
+		return
+	}
+
+	line := s.Name + " "
+	if s.Total > 0 {
+		filled := int(s.Done * barWidth / s.Total)
+		if filled > barWidth {
+			filled = barWidth
+		}
+		line += "[" + strings.Repeat("=", filled) + strings.Repeat(" ", barWidth-filled) + "] "
+	}
+	line += s.String()
+	switch {
+	case err != nil:
+		line += " failed"
+	case final:
+		line += " done"
+	}
+
+	b.clear()
+	fmt.Fprint(b.w, line)
+	b.drawn = len(line)
+
+	if final {
+		fmt.Fprintln(b.w)
+		b.drawn = 0
+	}
+}
+
+// clear blanks the line of the bar, so the cursor is at its start.
+func (b *bar) clear() {
+	if b.drawn > 0 {
+		fmt.Fprint(b.w, "\r"+strings.Repeat(" ", b.drawn)+"\r")
+		b.drawn = 0
+	}
+}
+
+// ProgressHook is a logrus hook which clears the progress bar before each
+// entry, so the entry does not end up on the line of the bar. The bar is
+// drawn again below it.
+type ProgressHook struct{}
+
+func (ProgressHook) Levels() []log.Level {
+	return RedactHook{}.Levels()
+}
+
+func (ProgressHook) Fire(entry *log.Entry) error {
+	barsMu.Lock()
+	defer barsMu.Unlock()
+	if len(bars) > 0 {
+		bars[len(bars)-1].clear()
+	}
+	return nil
+}
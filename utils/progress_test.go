@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+func TestProgressReader(t *testing.T) {
+	var statuses []progressStatus
+	p := newTickerProgress(time.Hour, log.WithFields(log.Fields{}), func(s progressStatus, final bool, err error) {
+		if final {
+			statuses = append(statuses, s)
+		}
+	})
+
+	p.Step("Downloading test.iso", 2048)
+	if _, err := ioutil.ReadAll(ProgressReader(strings.NewReader(strings.Repeat("x", 2048)), p)); err != nil {
+		t.Fatal(err)
+	}
+	p.Step("Uploading test.iso", -1)
+	p.Done(nil)
+	p.Done(nil)
+
+	if len(statuses) != 2 {
+		t.Fatalf("expected each step to be shown done once; received %+v", statuses)
+	}
+	if statuses[0].Done != 2048 || statuses[0].String()[:4] != "100%" {
+		t.Fatalf("expected the download to be complete; received %s", statuses[0])
+	}
+	if statuses[1].Name != "Uploading test.iso" || statuses[1].Done != 0 {
+		t.Fatalf("expected the upload to be a step of its own; received %+v", statuses[1])
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	var buf bytes.Buffer
+	outerBar, innerBar := &bar{w: &buf}, &bar{w: &buf}
+	outer := &barProgress{tickerProgress: newTickerProgress(time.Hour, log.WithFields(log.Fields{}), outerBar.show), bar: outerBar}
+	inner := &barProgress{tickerProgress: newTickerProgress(time.Hour, log.WithFields(log.Fields{}), innerBar.show), bar: innerBar}
+
+	outer.Step("Creating test", -1)
+	inner.Step("Downloading test.iso", 1024)
+	inner.Add(512)
+	outer.show(outer.current(), false, nil)
+	if buf.Len() != 0 {
+		t.Fatalf("expected only the innermost bar to be drawn; received %q", buf.String())
+	}
+	inner.show(inner.current(), false, nil)
+	if !strings.Contains(buf.String(), "Downloading test.iso [===============               ]  50% 512 B / 1.0 KB") {
+		t.Fatalf("expected the download to be halfway; received %q", buf.String())
+	}
+
+	inner.Done(nil)
+	outer.Done(errors.New("test failure"))
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) != 3 || !strings.HasSuffix(lines[0], " done") || !strings.HasSuffix(lines[1], " failed") {
+		t.Fatalf("expected a line for each step; received %q", buf.String())
+	}
+	if len(bars) != 0 {
+		t.Fatalf("expected no bar to be left; received %d", len(bars))
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, expected := range map[int64]string{
+		512:              "512 B",
+		1536:             "1.5 KB",
+		40 * 1024 * 1024: "40.0 MB",
+	} {
+		if s := formatBytes(n); s != expected {
+			t.Fatalf("expected %s for %d; received %s", expected, n, s)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// redacted replaces the secrets in the logs.
+const redacted = "[REDACTED]"
+
+// secretFieldRegexp matches the names of the driver fields which hold
+// secrets, e.g. Password, SecretKey or AccessToken. Paths to files which
+// hold secrets, e.g. PrivateKeyPath, are not secrets themselves.
+var secretFieldRegexp = regexp.MustCompile(`(?i)(password|secret|token|apikey|accesskey)`)
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+// RegisterSecret makes Redact, and so the logs, hide secret from now on.
+func RegisterSecret(secret string) {
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return
+	}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, s := range secrets {
+		if s == secret {
+			return
+		}
+	}
+	secrets = append(secrets, secret)
+}
+
+// RegisterSecrets registers the values of the string fields of the struct v
+// points to whose names say they are secrets, such as the Password of a
+// driver. Embedded structs are searched too.
+func RegisterSecrets(v interface{}) {
+	registerSecrets(reflect.ValueOf(v))
+}
+
+func registerSecrets(value reflect.Value) {
+	value = reflect.Indirect(value)
+	if value.Kind() != reflect.Struct {
+		return
+	}
+
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		switch {
+		case field.Anonymous:
+			registerSecrets(value.Field(i))
+		case field.Type.Kind() == reflect.String && secretFieldRegexp.MatchString(field.Name) && !strings.HasSuffix(field.Name, "Path"):
+			RegisterSecret(value.Field(i).String())
+		}
+	}
+}
+
+// Redact returns s with the registered secrets replaced.
+func Redact(s string) string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	for _, secret := range secrets {
+		s = strings.Replace(s, secret, redacted, -1)
+	}
+	return s
+}
+
+// RedactHook is a logrus hook which redacts the message and the fields of
+// every entry before it is written anywhere.
+type RedactHook struct{}
+
+func (RedactHook) Levels() []log.Level {
+	return []log.Level{
+		log.PanicLevel,
+		log.FatalLevel,
+		log.ErrorLevel,
+		log.WarnLevel,
+		log.InfoLevel,
+		log.DebugLevel,
+	}
+}
+
+func (RedactHook) Fire(entry *log.Entry) error {
+	entry.Message = Redact(entry.Message)
+	for k, v := range entry.Data {
+		switch v := v.(type) {
+		case string:
+			entry.Data[k] = Redact(v)
+		case error, fmt.Stringer:
+			entry.Data[k] = Redact(fmt.Sprint(v))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+type testDriverBase struct {
+	SessionToken string
+}
+
+type testDriver struct {
+	testDriverBase
+	User           string
+	Password       string
+	SecretKey      string
+	PrivateKeyPath string
+}
+
+func TestRegisterSecrets(t *testing.T) {
+	RegisterSecrets(&testDriver{
+		testDriverBase: testDriverBase{SessionToken: "test-token"},
+		User:           "test-user",
+		Password:       "test-password",
+		SecretKey:      "test-secret-key",
+		PrivateKeyPath: "/test/id_rsa",
+	})
+
+	redactedS := Redact("test-user test-password test-secret-key test-token /test/id_rsa")
+	if redactedS != "test-user [REDACTED] [REDACTED] [REDACTED] /test/id_rsa" {
+		t.Fatalf("expected only the secrets to be redacted; received %q", redactedS)
+	}
+}
+
+func TestRedactHook(t *testing.T) {
+	RegisterSecret("hook-secret")
+
+	entry := log.WithFields(log.Fields{
+		"password": "hook-secret",
+		"error":    errors.New("bad password hook-secret"),
+		"attempt":  1,
+	})
+	entry.Message = "logged in with hook-secret"
+	if err := (RedactHook{}).Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Message != "logged in with [REDACTED]" {
+		t.Fatalf("expected the message to be redacted; received %q", entry.Message)
+	}
+	if entry.Data["password"] != "[REDACTED]" || entry.Data["error"] != "bad password [REDACTED]" {
+		t.Fatalf("expected the fields to be redacted; received %v", entry.Data)
+	}
+	if entry.Data["attempt"] != 1 {
+		t.Fatalf("expected other fields to be kept; received %v", entry.Data)
+	}
+}
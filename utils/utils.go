@@ -34,6 +34,10 @@ func GetMachineClientCertDir() string {
 	return filepath.Join(GetMachineDir(), ".client")
 }
 
+func GetMachineProfileDir() string {
+	return filepath.Join(GetMachineDir(), ".profiles")
+}
+
 func GetUsername() string {
 	u := "unknown"
 	osUser := ""
@@ -88,6 +88,22 @@ func TestGetMachineClientCertDir(t *testing.T) {
 	os.Setenv("MACHINE_DIR", "")
 }
 
+func TestGetMachineProfileDir(t *testing.T) {
+	root := "/tmp"
+	os.Setenv("MACHINE_DIR", root)
+	profileDir := GetMachineProfileDir()
+
+	if strings.Index(profileDir, root) != 0 {
+		t.Fatalf("expected machine profile dir with prefix %s; received %s", root, profileDir)
+	}
+
+	_, filename := path.Split(profileDir)
+	if filename != ".profiles" {
+		t.Fatalf("expected machine profile dir \".profiles\"; received %s", filename)
+	}
+	os.Setenv("MACHINE_DIR", "")
+}
+
 func TestCopyFile(t *testing.T) {
 	testStr := "test-machine"
 
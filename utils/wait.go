@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// Backoff is how WaitFor spaces out its attempts and when it gives up. The
+// zero value retries every second for as long as the context allows.
+type Backoff struct {
+	// Interval is the wait after the first failed attempt, one second if
+	// zero.
+	Interval time.Duration
+
+	// MaxInterval caps the wait between attempts. If zero, the wait does
+	// not grow beyond Interval.
+	MaxInterval time.Duration
+
+	// Multiplier is the factor by which the wait grows after each failed
+	// attempt until it reaches MaxInterval, 2 if zero.
+	Multiplier float64
+
+	// Jitter randomizes each wait by up to this fraction of it, e.g. 0.2
+	// for plus or minus 20%, so that many waiters do not poll in step.
+	Jitter float64
+
+	// MaxAttempts is the number of attempts after which WaitFor gives up,
+	// or zero for no limit.
+	MaxAttempts int
+
+	// Timeout is how long WaitFor tries before it gives up, or zero for no
+	// limit besides the context.
+	Timeout time.Duration
+}
+
+// DefaultBackoff is how drivers poll their provider while they wait for a
+// host: every second at first, backing off to every ten seconds, for up to
+// ten minutes.
+var DefaultBackoff = Backoff{
+	Interval:    time.Second,
+	MaxInterval: 10 * time.Second,
+	Jitter:      0.2,
+	Timeout:     10 * time.Minute,
+}
+
+// interval returns the wait before the attempt after attempt, without
+// jitter.
+func (b Backoff) interval(attempt int) time.Duration {
+	interval := b.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	if b.MaxInterval <= interval {
+		return interval
+	}
+
+	multiplier := b.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	wait := float64(interval)
+	for i := 1; i < attempt; i++ {
+		wait *= multiplier
+		if wait >= float64(b.MaxInterval) {
+			return b.MaxInterval
+		}
+	}
+	return time.Duration(wait)
+}
+
+// jitter randomizes wait by up to b.Jitter of it.
+func (b Backoff) jitter(wait time.Duration) time.Duration {
+	if b.Jitter <= 0 {
+		return wait
+	}
+	delta := b.Jitter * float64(wait)
+	return wait + time.Duration(delta*(2*rand.Float64()-1))
+}
+
+// PermanentError is returned by the checks of WaitFor for failures which
+// retrying cannot fix, e.g. an instance which went into an error state.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+// Permanent marks err as a failure WaitFor must not retry.
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+// IsPermanent returns whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	_, ok := err.(*PermanentError)
+	return ok
+}
+
+// TimeoutError is returned by WaitFor when it gives up before the check
+// succeeds, because of the limits of its Backoff or because its context is
+// done.
+type TimeoutError struct {
+	// What is what WaitFor was waiting for.
+	What string
+
+	// Attempts is the number of times the check ran.
+	Attempts int
+
+	// Elapsed is how long WaitFor waited.
+	Elapsed time.Duration
+
+	// Err is the error of the last attempt, if any.
+	Err error
+
+	// Cause is the error of the context if it was done, e.g.
+	// context.Canceled.
+	Cause error
+}
+
+func (e *TimeoutError) Error() string {
+	elapsed := e.Elapsed - e.Elapsed%time.Millisecond
+	msg := fmt.Sprintf("Timed out waiting for %s after %d attempts in %s", e.What, e.Attempts, elapsed)
+	if e.Cause != nil {
+		msg = fmt.Sprintf("Gave up waiting for %s after %d attempts: %s", e.What, e.Attempts, e.Cause)
+	}
+	if e.Err != nil {
+		msg += fmt.Sprintf(" (last error: %s)", e.Err)
+	}
+	return msg
+}
+
+// IsTimeout returns whether err is WaitFor giving up.
+func IsTimeout(err error) bool {
+	_, ok := err.(*TimeoutError)
+	return ok
+}
+
+// WaitFor runs check until it returns nil, waiting between attempts as b
+// says. Errors returned by check are retried unless they are marked with
+// Permanent, in which case WaitFor returns the error inside. When the limits
+// of b are reached or ctx is done, WaitFor returns a *TimeoutError. what
+// describes what is being waited for, e.g. "SSH on 1.2.3.4:22", for the logs
+// and the error.
+func WaitFor(ctx context.Context, what string, b Backoff, check func() error) error {
+	start := time.Now()
+	var deadline <-chan time.Time
+	if b.Timeout > 0 {
+		timer := time.NewTimer(b.Timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := check()
+		if err == nil {
+			return nil
+		}
+		if permanent, ok := err.(*PermanentError); ok {
+			return permanent.Err
+		}
+
+		timeout := &TimeoutError{What: what, Attempts: attempt, Err: err}
+		if b.MaxAttempts > 0 && attempt >= b.MaxAttempts {
+			timeout.Elapsed = time.Since(start)
+			return timeout
+		}
+
+		wait := b.jitter(b.interval(attempt))
+		log.Debugf("Waiting for %s, attempt %d failed, retrying in %s: %s", what, attempt, wait, err)
+
+		select {
+		case <-ctx.Done():
+			timeout.Cause = ctx.Err()
+			timeout.Elapsed = time.Since(start)
+			return timeout
+		case <-deadline:
+			timeout.Elapsed = time.Since(start)
+			return timeout
+		case <-time.After(wait):
+		}
+	}
+}
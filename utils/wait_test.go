@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+var testBackoff = Backoff{Interval: time.Millisecond}
+
+func TestWaitFor(t *testing.T) {
+	attempts := 0
+	err := WaitFor(context.Background(), "test", testBackoff, func() error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts; received %d", attempts)
+	}
+}
+
+func TestWaitForPermanent(t *testing.T) {
+	permanent := errors.New("permanent")
+	attempts := 0
+	err := WaitFor(context.Background(), "test", testBackoff, func() error {
+		attempts++
+		return Permanent(permanent)
+	})
+	if err != permanent {
+		t.Fatalf("expected the permanent error; received %v", err)
+	}
+	if attempts != 1 {
+		t.Fatalf("expected a permanent error not to be retried; received %d attempts", attempts)
+	}
+}
+
+func TestWaitForMaxAttempts(t *testing.T) {
+	b := testBackoff
+	b.MaxAttempts = 4
+
+	attempts := 0
+	err := WaitFor(context.Background(), "test", b, func() error {
+		attempts++
+		return errors.New("not yet")
+	})
+	timeout, ok := err.(*TimeoutError)
+	if !ok {
+		t.Fatalf("expected a TimeoutError; received %v", err)
+	}
+	if attempts != 4 || timeout.Attempts != 4 {
+		t.Fatalf("expected 4 attempts; received %d, reported %d", attempts, timeout.Attempts)
+	}
+	if timeout.Err == nil || timeout.Err.Error() != "not yet" {
+		t.Fatalf("expected the last error; received %v", timeout.Err)
+	}
+}
+
+func TestWaitForTimeout(t *testing.T) {
+	b := testBackoff
+	b.Timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	err := WaitFor(context.Background(), "test", b, func() error {
+		return errors.New("not yet")
+	})
+	if !IsTimeout(err) {
+		t.Fatalf("expected a TimeoutError; received %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("expected WaitFor to give up after its timeout")
+	}
+}
+
+func TestWaitForCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := WaitFor(ctx, "test", Backoff{Interval: time.Hour}, func() error {
+		return errors.New("not yet")
+	})
+	timeout, ok := err.(*TimeoutError)
+	if !ok {
+		t.Fatalf("expected a TimeoutError; received %v", err)
+	}
+	if timeout.Cause != context.Canceled {
+		t.Fatalf("expected the cause to be the context; received %v", timeout.Cause)
+	}
+}
+
+func TestBackoffInterval(t *testing.T) {
+	b := Backoff{Interval: time.Second, MaxInterval: 5 * time.Second}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, e := range expected {
+		if interval := b.interval(i + 1); interval != e {
+			t.Fatalf("expected interval %s after attempt %d; received %s", e, i+1, interval)
+		}
+	}
+
+	b = Backoff{Interval: time.Second}
+	if interval := b.interval(10); interval != time.Second {
+		t.Fatalf("expected a constant interval without MaxInterval; received %s", interval)
+	}
+
+	b = Backoff{Interval: time.Second, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		if wait := b.jitter(time.Second); wait < 500*time.Millisecond || wait > 1500*time.Millisecond {
+			t.Fatalf("expected the jitter to be within half the interval; received %s", wait)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/drivers"
+	"github.com/docker/machine/state"
+	"github.com/docker/machine/utils"
+	"golang.org/x/net/context"
+)
+
+// waitBackoff is how "machine wait" polls the state of a machine until its
+// --timeout.
+var waitBackoff = utils.Backoff{
+	Interval:    time.Second,
+	MaxInterval: 5 * time.Second,
+	Jitter:      0.2,
+}
+
+// waitPingTimeout bounds each ping of the Docker daemon by "machine wait".
+const waitPingTimeout = 5 * time.Second
+
+// parseState returns the state named name, ignoring case.
+func parseState(name string) (state.State, error) {
+	for st := state.Running; st <= state.Timeout; st++ {
+		if strings.EqualFold(name, st.String()) {
+			return st, nil
+		}
+	}
+	return state.None, fmt.Errorf("Invalid state %q: expected e.g. Running or Stopped", name)
+}
+
+// waitForHost waits until host is in the state want and, if docker is set,
+// until its Docker daemon answers, or until ctx is done. It returns a
+// *utils.TimeoutError if it gave up.
+func waitForHost(ctx context.Context, host *Host, want state.State, docker bool) error {
+	what := fmt.Sprintf("%s to be %s", host.Name, want)
+	if docker {
+		what += " with Docker reachable"
+	}
+
+	return utils.WaitFor(ctx, what, waitBackoff, func() error {
+		st, err := host.Driver.GetState()
+		if err != nil {
+			if drivers.IsNotSupported(err) {
+				return utils.Permanent(err)
+			}
+			return err
+		}
+		if st != want {
+			if st == state.None {
+				return fmt.Errorf("%s does not exist", host.Name)
+			}
+			return fmt.Errorf("%s is %s", host.Name, st)
+		}
+		if !docker {
+			return nil
+		}
+
+		url, err := host.GetURL()
+		if err != nil {
+			return err
+		}
+		if err := pingHostDocker(host, url, waitPingTimeout); err != nil {
+			return fmt.Errorf("Docker is not reachable: %s", err)
+		}
+		return nil
+	})
+}
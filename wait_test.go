@@ -0,0 +1,21 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/docker/machine/state"
+)
+
+func TestParseState(t *testing.T) {
+	if st, err := parseState("running"); err != nil || st != state.Running {
+		t.Fatalf("expected Running; received %s, %v", st, err)
+	}
+	if st, err := parseState("Stopped"); err != nil || st != state.Stopped {
+		t.Fatalf("expected Stopped; received %s, %v", st, err)
+	}
+	for _, name := range []string{"", "up"} {
+		if _, err := parseState(name); err == nil {
+			t.Fatalf("expected an error for state %q", name)
+		}
+	}
+}